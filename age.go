@@ -0,0 +1,79 @@
+package ndb
+
+import (
+	"strconv"
+	"time"
+)
+
+// Return when the record was added, from its added= tuple, which
+// holds Unix seconds or an RFC 3339 time as expires= does.
+func (r Record) Added() (time.Time, bool) {
+	return r.timestamp("added")
+}
+
+// Return when the host was last seen on the network, from its seen=
+// tuple, which holds Unix seconds or an RFC 3339 time.
+func (r Record) Seen() (time.Time, bool) {
+	return r.timestamp("seen")
+}
+
+// Return the hosts not seen for maxage before now: records with an
+// ip=, other than ipnet records, whose seen= time is older, or whose
+// added= time is older and who have never been seen. Hosts with
+// neither tuple can't be judged and are left out.
+func (n *Ndb) Stale(maxage time.Duration, now time.Time) []HostRef {
+	var stale []HostRef
+
+	cutoff := now.Add(-maxage)
+
+	n.Walk(func(rec Record, pos Pos) bool {
+		if rec.find("ipnet") != nil || rec.find("ip") == nil {
+			return true
+		}
+
+		last, ok := rec.Seen()
+		if !ok {
+			last, ok = rec.Added()
+		}
+
+		if ok && last.Before(cutoff) {
+			stale = append(stale, HostRef{rec.Key(), pos})
+		}
+
+		return true
+	})
+
+	return stale
+}
+
+// Set seen= to now in every record of the database for which match
+// returns true, and reread the database. The files are edited in
+// place with EditFile, keeping comments and layout: an existing seen=
+// value is replaced, otherwise seen= is added to the end of the
+// record's last line.
+func (n *Ndb) MarkSeen(now time.Time, match func(Record) bool) error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	stamp := strconv.FormatInt(now.Unix(), 10)
+
+	for _, fname := range n.Files() {
+		err := EditFile(fname, func(f *File) error {
+			for _, r := range f.Records() {
+				if !match(r.Record()) {
+					continue
+				}
+				if err := r.Set("seen", stamp); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.Reopen()
+}
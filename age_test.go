@@ -0,0 +1,85 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	data := `ipnet=lab ip=10.0.0.0 ipmask=/24 added=100
+ip=10.0.0.1 sys=old seen=1000
+ip=10.0.0.2 sys=new added=1000 seen=2000000
+ip=10.0.0.3 sys=unseen added=1000
+ip=10.0.0.4 sys=unknown
+ip=10.0.0.5 sys=rfc seen=2020-01-01T00:00:00Z
+`
+
+	if err := ioutil.WriteFile(fname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := db.Stale(24*time.Hour, time.Unix(2000000, 0))
+
+	var names []string
+	for _, ref := range stale {
+		names = append(names, ref.Key.Val)
+	}
+
+	if len(names) != 2 || names[0] != "10.0.0.1" || names[1] != "10.0.0.3" {
+		t.Errorf("expected 10.0.0.1 and 10.0.0.3, got %v", names)
+	}
+
+	if stale := db.Stale(24*time.Hour, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)); len(stale) != 4 {
+		t.Errorf("expected 4 stale hosts in 2021, got %v", stale)
+	}
+
+	now := time.Unix(3000000, 0)
+	err = db.MarkSeen(now, func(rec Record) bool {
+		sys := rec.Search("sys")
+		return sys == "old" || sys == "unknown"
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sys := range []string{"old", "unknown"} {
+		if seen, ok := db.Search("sys", sys)[0].Seen(); !ok || !seen.Equal(now) {
+			t.Errorf("%s: expected seen %v, got %v", sys, now, seen)
+		}
+	}
+
+	if stale := db.Stale(24*time.Hour, now); len(stale) != 2 {
+		t.Errorf("expected 2 stale hosts after marking, got %v", stale)
+	}
+
+	if recs := db.Search("sys", "new"); len(recs) != 1 {
+		t.Errorf("records after a marked one were disturbed")
+	}
+
+	// only the marked records' text changes
+	want := strings.Replace(data, "sys=old seen=1000", "sys=old seen=3000000", 1)
+	want = strings.Replace(want, "sys=unknown", "sys=unknown seen=3000000", 1)
+	if got, _ := ioutil.ReadFile(fname); string(got) != want {
+		t.Errorf("got file %q want %q", got, want)
+	}
+}
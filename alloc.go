@@ -0,0 +1,121 @@
+package ndb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Find the lowest unassigned address on the network of the ipnet
+// record named ipnet, skipping addresses used by ip= tuples anywhere
+// in the database and any reserved ranges. Only IPv4 is supported.
+func (n *Ndb) AllocateIP(ipnet string, reserved ...IPRange) (net.IP, error) {
+	for _, nw := range n.Networks() {
+		if nw.Record.Search("ipnet") != ipnet {
+			continue
+		}
+
+		for _, r := range freeranges(nw) {
+			if ip := firstunreserved(r, reserved); ip != nil {
+				return ip, nil
+			}
+		}
+
+		return nil, fmt.Errorf("allocate: no free address on %s", ipnet)
+	}
+
+	return nil, fmt.Errorf("allocate: no ipnet %q", ipnet)
+}
+
+// Find the first address in r outside all of the reserved ranges.
+func firstunreserved(r IPRange, reserved []IPRange) net.IP {
+	first := binary.BigEndian.Uint32(r.First.To4())
+	last := binary.BigEndian.Uint32(r.Last.To4())
+
+	for a := uint64(first); a <= uint64(last); {
+		skip := false
+		for _, res := range reserved {
+			lo, hi := res.First.To4(), res.Last.To4()
+			if lo == nil || hi == nil {
+				continue
+			}
+			if rlo, rhi := binary.BigEndian.Uint32(lo), binary.BigEndian.Uint32(hi); a >= uint64(rlo) && a <= uint64(rhi) {
+				a = uint64(rhi) + 1
+				skip = true
+			}
+		}
+
+		if !skip {
+			return uint32ip(uint32(a))
+		}
+	}
+
+	return nil
+}
+
+// Allocate an address as AllocateIP does and append a host record to
+// the first file of the database, made of ip= followed by the tuples
+// of rec. The file is locked while the database is reread, the address
+// chosen and the record written, so concurrent allocators using this
+// method never hand out the same address. The database is reread
+// afterwards to include the new record. Nothing is written if a tuple
+// of rec can't be.
+func (n *Ndb) AllocateHost(ipnet string, rec Record, reserved ...IPRange) (net.IP, error) {
+	if err := n.writable(); err != nil {
+		return nil, err
+	}
+
+	if err := checkrecord(rec); err != nil {
+		return nil, err
+	}
+
+	lock, err := lockdb(n.filename)
+	if err != nil {
+		return nil, fmt.Errorf("allocate: %s", err)
+	}
+	defer unlockdb(lock)
+
+	if err := n.Reopen(); err != nil {
+		return nil, err
+	}
+
+	ip, err := n.AllocateIP(ipnet, reserved...)
+	if err != nil {
+		return nil, err
+	}
+
+	host := append(Record{Tuple{"ip", ip.String()}}, rec...)
+
+	if err := appendrecords(n.filename, RecordSet{host}); err != nil {
+		return nil, fmt.Errorf("allocate: %s", err)
+	}
+
+	if err := n.Reopen(); err != nil {
+		return nil, err
+	}
+
+	return ip, nil
+}
+
+// Append records to the end of the file fname, creating it if needed,
+// by replacing it with writefile. The caller holds the file's lock.
+func appendrecords(fname string, recs RecordSet) error {
+	data, err := readdb(fname)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(data)
+
+	// don't glue the record onto an unterminated last line
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	for _, rec := range recs {
+		buf.WriteString(formatrecord(rec))
+	}
+
+	return writefile(fname, buf.Bytes())
+}
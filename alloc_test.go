@@ -0,0 +1,124 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestAllocateIP(t *testing.T) {
+	db, err := Open("testndb/report")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip, err := db.AllocateIP("lab")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip.String() != "10.1.2.3" {
+		t.Errorf("expected 10.1.2.3 got %s", ip)
+	}
+
+	reserved := IPRange{net.ParseIP("10.1.2.3"), net.ParseIP("10.1.2.9")}
+	ip, err = db.AllocateIP("lab", reserved)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip.String() != "10.1.2.10" {
+		t.Errorf("expected 10.1.2.10 got %s", ip)
+	}
+
+	if _, err := db.AllocateIP("nonexistent"); err == nil {
+		t.Errorf("expected error for missing ipnet")
+	}
+
+	all := IPRange{net.ParseIP("10.1.2.0"), net.ParseIP("10.1.2.255")}
+
+	if _, err := db.AllocateIP("lab", all); err == nil {
+		t.Errorf("expected error for full network")
+	}
+}
+
+func TestAllocateHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	data := "ipnet=tiny ip=10.0.0.0 ipmask=255.255.255.248\nip=10.0.0.1 sys=gw"
+
+	if err := ioutil.WriteFile(fname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// race several allocators, each with their own view of the file
+	var wg sync.WaitGroup
+	ips := make([]string, 5)
+	errs := make([]error, 5)
+
+	for i := range ips {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := Open(fname)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			ip, err := db.AllocateHost("tiny", Record{Tuple{"sys", "host"}, Tuple{"info", "a b"}})
+			ips[i], errs[i] = ip.String(), err
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, ip := range ips {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if seen[ip] {
+			t.Errorf("address %s allocated twice", ip)
+		}
+		seen[ip] = true
+	}
+
+	db, err := Open(fname)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "host"); len(recs) != 5 {
+		t.Errorf("expected 5 host records, got %d", len(recs))
+	} else if recs[0].Search("info") != "a b" {
+		t.Errorf("quoted value not preserved: %+v", recs[0])
+	}
+
+	// a value that can't be written leaves the file alone
+	before, _ := ioutil.ReadFile(fname)
+	if _, err := db.AllocateHost("tiny", Record{Tuple{"sys", "bad"}, Tuple{"note", `say "hi" now`}}); err == nil {
+		t.Errorf("expected error for an unwritable value")
+	}
+	if after, _ := ioutil.ReadFile(fname); string(after) != string(before) {
+		t.Errorf("file written: %q", after)
+	}
+
+	// the network has 6 usable addresses
+	if _, err := db.AllocateHost("tiny", Record{Tuple{"sys", "full"}}); err == nil {
+		t.Errorf("expected error for full network")
+	}
+}
@@ -0,0 +1,78 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Take the advisory lock writers of the database file fname hold while
+// they change it: an exclusive lock on fname.lock beside it, which,
+// unlike a lock on fname itself, lasts while writefile replaces fname.
+// The lock file is left in place for the next writer.
+func lockdb(fname string) (*os.File, error) {
+	f, err := os.OpenFile(fname+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockfile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Release a lock taken by lockdb.
+func unlockdb(f *os.File) {
+	unlockfile(f)
+	f.Close()
+}
+
+// Read the database file fname for a writer holding its lock. A file
+// that doesn't exist yet reads as empty.
+func readdb(fname string) ([]byte, error) {
+	data, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+// Replace the file fname with data by writing a temporary file beside
+// it and renaming it over fname, so a reader, such as Reopen, sees the
+// old file or the new one and never part of either. The new file keeps
+// fname's permissions, or is made 0644.
+func writefile(fname string, data []byte) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(fname); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		if err = f.Chmod(mode); err == nil {
+			err = f.Sync()
+		}
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), fname)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
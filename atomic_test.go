@@ -0,0 +1,122 @@
+package ndb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+
+	if err := ioutil.WriteFile(fname, []byte("sys=a\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writefile(fname, []byte("sys=b\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(fname); string(data) != "sys=b\n" {
+		t.Errorf("read %q after write", data)
+	}
+
+	if fi, err := os.Stat(fname); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("mode not kept: %v %v", fi.Mode(), err)
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("temporary files left behind: %d files", len(files))
+	}
+
+	// readers see one whole version or the other
+	a := bytes.Repeat([]byte("sys=a ip=10.0.0.1\n"), 10000)
+	b := bytes.Repeat([]byte("sys=b ip=10.0.0.2\n"), 20000)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 50; i++ {
+			data := a
+			if i%2 == 1 {
+				data = b
+			}
+			if err := writefile(fname, data); err != nil {
+				t.Error(err)
+			}
+		}
+		close(done)
+	}()
+
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+
+		if data, err := ioutil.ReadFile(fname); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(data, a) && !bytes.Equal(data, b) && string(data) != "sys=b\n" {
+			t.Fatalf("read a partly written file of %d bytes", len(data))
+		}
+	}
+}
+
+func TestLockDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+
+	lock, err := lockdb(fname)
+	if err != nil {
+		t.Skipf("can't lock: %s", err)
+	}
+
+	locked := make(chan bool)
+	go func() {
+		second, err := lockdb(fname)
+		if err != nil {
+			t.Error(err)
+		} else {
+			unlockdb(second)
+		}
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second writer got the lock while it was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// replacing the file doesn't release the lock
+	if err := writefile(fname, []byte("sys=a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-locked:
+		t.Fatal("second writer got the lock after the file was replaced")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlockdb(lock)
+	<-locked
+}
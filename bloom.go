@@ -0,0 +1,113 @@
+package ndb
+
+import (
+	"hash/fnv"
+)
+
+// Keep a bloom filter of the values of attrs in each file, so searches
+// for values that aren't there skip the file without scanning its
+// records. This helps programs whose queries mostly miss, such as
+// servers answering random DNS probes. The filters are rebuilt
+// whenever a file is loaded, and use about two bytes per value.
+func WithBloom(attrs ...string) Option {
+	return func(o *options) {
+		if o.bloomattrs == nil {
+			o.bloomattrs = make(map[string]bool)
+		}
+		for _, attr := range attrs {
+			o.bloomattrs[attr] = true
+		}
+	}
+}
+
+// A bloom filter of attr=val strings.
+type bloom struct {
+	bits []uint64
+}
+
+// Hash functions per lookup. With 16 bits per value this gives
+// a false positive rate of about 0.05%.
+const bloomk = 11
+
+func newbloom(n int) *bloom {
+	return &bloom{make([]uint64, (n*16+63)/64+1)}
+}
+
+// Two independent hashes of attr=val, combined for each of the
+// bloomk bit positions.
+func bloomhash(attr, val string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(attr))
+	h.Write([]byte{0})
+	h.Write([]byte(val))
+	sum := h.Sum64()
+	return sum, sum>>32 | sum<<32 | 1
+}
+
+func (b *bloom) add(attr, val string) {
+	h1, h2 := bloomhash(attr, val)
+	nbits := uint64(len(b.bits) * 64)
+
+	for i := uint64(0); i < bloomk; i++ {
+		bit := (h1 + i*h2) % nbits
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Whether attr=val may have been added.
+func (b *bloom) has(attr, val string) bool {
+	h1, h2 := bloomhash(attr, val)
+	nbits := uint64(len(b.bits) * 64)
+
+	for i := uint64(0); i < bloomk; i++ {
+		bit := (h1 + i*h2) % nbits
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Build the bloom filter for a file's records, if any attributes
+// were given to WithBloom. Values are normalized first, so searches
+// through a normalizer find them.
+func (o *options) buildbloom(db *Ndb) {
+	if len(o.bloomattrs) == 0 {
+		db.bloom = nil
+		return
+	}
+
+	n := 0
+	for _, rec := range db.records {
+		for _, tuple := range rec {
+			if o.bloomattrs[tuple.Attr] {
+				n++
+			}
+		}
+	}
+
+	db.bloom = newbloom(n)
+
+	for _, rec := range db.records {
+		for _, tuple := range rec {
+			if o.bloomattrs[tuple.Attr] {
+				db.bloom.add(tuple.Attr, o.normalize(tuple.Attr, tuple.Val))
+			}
+		}
+	}
+}
+
+// Whether a search of db for attr=val can be skipped.
+func (db *Ndb) bloomskip(attr, val string) bool {
+	if db.bloom == nil || val == "" || !db.opts.bloomattrs[attr] {
+		return false
+	}
+
+	if db.bloom.has(attr, db.opts.normalize(attr, val)) {
+		return false
+	}
+
+	statBloomSkips.Add(1)
+	return true
+}
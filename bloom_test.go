@@ -0,0 +1,83 @@
+package ndb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBloom(t *testing.T) {
+	plain, err := Open("testndb/local")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open("testndb/local", WithBloom("sys", "dom", "ip"), WithNormalizer("dom", NormalizeDom))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no false negatives: every value is still found
+	for _, attr := range []string{"sys", "dom", "ip"} {
+		for _, val := range plain.Vals(attr) {
+			if a, b := len(plain.Search(attr, val)), len(db.Search(attr, val)); a != b {
+				t.Errorf("%s=%s: %d records without bloom, %d with", attr, val, a, b)
+			}
+		}
+	}
+
+	if recs := db.Search("dom", "a.root-servers.net."); len(recs) != 1 {
+		t.Errorf("expected normalized dom to pass the filter, got %v", recs)
+	}
+
+	before := statBloomSkips.Value()
+
+	for i := 0; i < 100; i++ {
+		if recs := db.Search("sys", fmt.Sprintf("missing%d", i)); recs != nil {
+			t.Fatalf("found %v", recs)
+		}
+	}
+
+	// each miss skips both files, barring the odd false positive
+	if skips := statBloomSkips.Value() - before; skips < 190 {
+		t.Errorf("expected about 200 skipped files, got %d", skips)
+	}
+}
+
+func TestBloomReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+
+	if err := ioutil.WriteFile(fname, []byte("sys=a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname, WithBloom("sys"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(fname, []byte("sys=a\nsys=b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "b"); len(recs) != 1 {
+		t.Errorf("filter not rebuilt on reload: %v", recs)
+	}
+}
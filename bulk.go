@@ -0,0 +1,105 @@
+package ndb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Attributes that AddHosts requires to be unique.
+var bulkUnique = []string{"sys", "dom", "ip", "ether"}
+
+// Make host records for names, with sys= the name and ip= addresses
+// counting up from start, followed by the tuples of tmpl. Only IPv4
+// is supported.
+func BulkHosts(tmpl Record, names []string, start net.IP) (RecordSet, error) {
+	ip4 := start.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("bulk: %s is not an IPv4 address", start)
+	}
+
+	a := uint64(binary.BigEndian.Uint32(ip4))
+
+	var hosts RecordSet
+
+	for i, name := range names {
+		if a+uint64(i) > 0xffffffff {
+			return nil, fmt.Errorf("bulk: addresses run past 255.255.255.255")
+		}
+
+		host := Record{Tuple{"sys", name}, Tuple{"ip", uint32ip(uint32(a + uint64(i))).String()}}
+		hosts = append(hosts, append(host, tmpl...))
+	}
+
+	return hosts, nil
+}
+
+// Append hosts to the file fname as a single change. Nothing is
+// written if a tuple of theirs can't be, or if any of their sys=,
+// dom=, ip= or ether= values is already in the database or repeated
+// among the hosts. The file is locked
+// while the database is reread, the hosts checked and written, and the
+// database is reread afterwards if fname is one of its files.
+func (n *Ndb) AddHosts(fname string, hosts RecordSet) error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		if err := checkrecord(host); err != nil {
+			return err
+		}
+	}
+
+	lock, err := lockdb(fname)
+	if err != nil {
+		return fmt.Errorf("bulk: %s", err)
+	}
+	defer unlockdb(lock)
+
+	if err := n.Reopen(); err != nil {
+		return err
+	}
+
+	if err := n.checkunique(hosts); err != nil {
+		return err
+	}
+
+	if err := appendrecords(fname, hosts); err != nil {
+		return fmt.Errorf("bulk: %s", err)
+	}
+
+	for _, file := range n.Files() {
+		if file == fname {
+			return n.Reopen()
+		}
+	}
+
+	return nil
+}
+
+// Check that no host reuses a unique value from the database
+// or from another host.
+func (n *Ndb) checkunique(hosts RecordSet) error {
+	seen := make(map[Tuple]bool)
+
+	for _, host := range hosts {
+		for _, attr := range bulkUnique {
+			for _, tuple := range host.find(attr) {
+				if tuple.Val == "" {
+					continue
+				}
+				if seen[tuple] {
+					return fmt.Errorf("bulk: %s=%s given twice", tuple.Attr, tuple.Val)
+				}
+				seen[tuple] = true
+
+				if n.Search(tuple.Attr, tuple.Val) != nil {
+					return fmt.Errorf("bulk: %s=%s already in use", tuple.Attr, tuple.Val)
+				}
+			}
+		}
+	}
+
+	return nil
+}
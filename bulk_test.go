@@ -0,0 +1,95 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBulkHosts(t *testing.T) {
+	tmpl := Record{Tuple{"dom", ""}, Tuple{"role", "web"}}
+	hosts, err := BulkHosts(tmpl, []string{"web01", "web02", "web03"}, net.ParseIP("10.0.0.254"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"10.0.0.254", "10.0.0.255", "10.0.1.0"}
+
+	if len(hosts) != len(expected) {
+		t.Fatalf("expected %d hosts, got %d", len(expected), len(hosts))
+	}
+
+	for i, host := range hosts {
+		if ip := host.Search("ip"); ip != expected[i] {
+			t.Errorf("host %d: expected ip %s got %s", i, expected[i], ip)
+		}
+		if host.Search("role") != "web" {
+			t.Errorf("host %d: template not applied: %s", i, host)
+		}
+	}
+
+	if _, err := BulkHosts(nil, []string{"a", "b"}, net.ParseIP("255.255.255.255")); err == nil {
+		t.Errorf("expected error for addresses past the end")
+	}
+
+	if _, err := BulkHosts(nil, []string{"a"}, net.ParseIP("::1")); err == nil {
+		t.Errorf("expected error for IPv6 start")
+	}
+}
+
+func TestAddHosts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	data := "ip=10.0.0.1 sys=gw"
+
+	if err := ioutil.WriteFile(fname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, _ := BulkHosts(Record{Tuple{"info", "a b"}}, []string{"a", "b"}, net.ParseIP("10.0.0.10"))
+
+	if err := db.AddHosts(fname, hosts); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("info", "a b"); len(recs) != 2 {
+		t.Errorf("expected 2 new hosts, got %d", len(recs))
+	}
+
+	// every batch below must fail without writing anything
+	before, _ := ioutil.ReadFile(fname)
+
+	conflicts := []RecordSet{
+		{Record{Tuple{"sys", "c"}, Tuple{"ip", "10.0.0.1"}}},
+		{Record{Tuple{"sys", "a"}, Tuple{"ip", "10.0.0.20"}}},
+		{Record{Tuple{"sys", "d"}, Tuple{"ip", "10.0.0.21"}}, Record{Tuple{"sys", "d"}, Tuple{"ip", "10.0.0.22"}}},
+		{Record{Tuple{"sys", "e"}, Tuple{"bad attr", "x"}}},
+		{Record{Tuple{"sys", "f"}}, Record{Tuple{"sys", "g"}, Tuple{"note", `say "hi" now`}}},
+	}
+
+	for i, batch := range conflicts {
+		if err := db.AddHosts(fname, batch); err == nil {
+			t.Errorf("batch %d: expected error", i)
+		}
+	}
+
+	if after, _ := ioutil.ReadFile(fname); string(after) != string(before) {
+		t.Errorf("failed batches changed the file:\n%s", after)
+	}
+}
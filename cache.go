@@ -0,0 +1,116 @@
+package ndb
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A fixed size, least recently used cache of Ipinfo results.
+// Safe for concurrent use.
+type ipcache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List               // Most recently used first
+	items map[string]*list.Element // Key to element in order
+}
+
+type ipcacheentry struct {
+	key     string
+	result  Record
+	expires time.Time // When result goes stale, zero if never
+}
+
+func newipcache(size int) *ipcache {
+	return &ipcache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Cache key for an Ipinfo query.
+func ipcachekey(attr, val string, rattrs []string) string {
+	return attr + "\x00" + val + "\x00" + strings.Join(rattrs, "\x00")
+}
+
+// Look up a cached result that hasn't expired by now.
+func (c *ipcache) get(key string, now time.Time) (Record, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if expires := e.Value.(*ipcacheentry).expires; !expires.IsZero() && !now.Before(expires) {
+		c.order.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*ipcacheentry).result, true
+}
+
+// Add a result, good until expires unless that is zero, evicting the
+// least recently used if full.
+func (c *ipcache) put(key string, result Record, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*ipcacheentry).result = result
+		e.Value.(*ipcacheentry).expires = expires
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&ipcacheentry{key, result, expires})
+
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*ipcacheentry).key)
+	}
+}
+
+// Drop all cached results.
+func (c *ipcache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Cache up to size Ipinfo results, so repeated identical queries
+// don't walk the ipnet records again. The cache is emptied by Reopen.
+// A size of 0 disables caching.
+func (n *Ndb) SetIpinfoCache(size int) {
+	if size <= 0 {
+		n.ipcache = nil
+		return
+	}
+
+	n.ipcache = newipcache(size)
+}
+
+// Estimate the memory used by the cached results.
+func (c *ipcache) bytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*ipcacheentry)
+		total += len(entry.key) + tuplesize*cap(entry.result)
+		for _, tuple := range entry.result {
+			total += len(tuple.Attr) + len(tuple.Val)
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,133 @@
+package ndb
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIpcacheEvict(t *testing.T) {
+	c := newipcache(2)
+
+	c.put("a", Record{Tuple{"a", "1"}}, time.Time{})
+	c.put("b", Record{Tuple{"b", "2"}}, time.Time{})
+
+	// touch a, so b is least recently used
+	if _, ok := c.get("a", time.Now()); !ok {
+		t.Fatal("a not cached")
+	}
+
+	c.put("c", Record{Tuple{"c", "3"}}, time.Time{})
+
+	if _, ok := c.get("b", time.Now()); ok {
+		t.Error("b not evicted")
+	}
+
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.get(k, time.Now()); !ok {
+			t.Errorf("%s evicted", k)
+		}
+	}
+
+	c.purge()
+
+	if _, ok := c.get("a", time.Now()); ok {
+		t.Error("a survived purge")
+	}
+}
+
+func TestIpcacheExpire(t *testing.T) {
+	c := newipcache(2)
+	now := time.Now()
+
+	c.put("a", Record{Tuple{"a", "1"}}, now.Add(time.Minute))
+
+	if _, ok := c.get("a", now); !ok {
+		t.Fatal("a not cached")
+	}
+
+	if _, ok := c.get("a", now.Add(time.Minute)); ok {
+		t.Error("a cached after it expired")
+	}
+
+	if c.order.Len() != 0 {
+		t.Error("expired a not removed")
+	}
+}
+
+func TestIpinfoExpires(t *testing.T) {
+	db, err := Parse(strings.NewReader("ipnet=lan ip=10.0.0.0 ipmask=255.255.255.0 ipgw=10.0.0.1 expires=2000000000\nsys=a ip=10.0.0.5 expires=1900000000\nsys=b ip=10.0.0.6\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sys     string
+		expires int64
+	}{
+		{"a", 1900000000},
+		{"b", 2000000000},
+	}
+
+	for _, test := range tests {
+		res, expires := db.ipinfo("sys", test.sys, []string{"ipgw"}, nil)
+		if len(res) != 1 || expires.Unix() != test.expires {
+			t.Errorf("sys=%s: got %v expiring %v", test.sys, res, expires)
+		}
+	}
+}
+
+func TestIpinfoCache(t *testing.T) {
+	db, err := Open(testipnet)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetIpinfoCache(4)
+
+	for i := 0; i < 2; i++ {
+		for tno, test := range ipinfotests {
+			res := db.Ipinfo(test.attr, test.val, test.rattrs...)
+
+			if len(res) != len(test.tuples) {
+				t.Errorf("pass %d test %d: expected %d tuples got %d", i, tno, len(test.tuples), len(res))
+				continue
+			}
+
+			for n, tuple := range test.tuples {
+				if res[n] != tuple {
+					t.Errorf("pass %d test %d: tuple %d: expected %+v got %+v", i, tno, n, tuple, res[n])
+				}
+			}
+
+			// must not corrupt the cached copy
+			if len(res) > 0 {
+				res[0].Val = "scribbled" + strconv.Itoa(i)
+			}
+		}
+	}
+
+	if db.ipcache.order.Len() != 4 {
+		t.Errorf("expected 4 cached results, got %d", db.ipcache.order.Len())
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.ipcache.order.Len() != 0 {
+		t.Errorf("cache not emptied by Reopen")
+	}
+
+	db.Ipinfo(ipinfotests[0].attr, ipinfotests[0].val, ipinfotests[0].rattrs...)
+
+	if err := db.Cat("testndb/common"); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.ipcache.order.Len() != 0 {
+		t.Errorf("cache not emptied by Cat")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	outfile = flag.String("o", "", "file to add the hosts to (default: the ndb file)")
+	names   = flag.String("names", "", "host names, comma separated; name[01-12] expands to a range")
+	start   = flag.String("ip", "", "first address for -names hosts")
+	csvfile = flag.String("csv", "", "csv file of hosts, with a header row of attributes")
+	dryrun  = flag.Bool("n", false, "print the hosts instead of adding them")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-o file] [-n] (-names names -ip start | -csv file) [attr=val ...]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if (*names == "") == (*csvfile == "") || (*names != "" && *start == "") {
+		usage()
+		os.Exit(1)
+	}
+
+	tmpl, err := parsetuples(flag.Args())
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	var hosts ndb.RecordSet
+
+	if *csvfile != "" {
+		hosts, err = readcsv(*csvfile, tmpl)
+	} else {
+		hosts, err = fromnames(*names, *start, tmpl)
+	}
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	// date the hosts for ndbreport -stale
+	added := strconv.FormatInt(time.Now().Unix(), 10)
+	for i, host := range hosts {
+		if _, ok := host.Added(); !ok {
+			hosts[i] = append(host, ndb.Tuple{Attr: "added", Val: added})
+		}
+	}
+
+	if *dryrun {
+		for _, host := range hosts {
+			fmt.Println(host)
+		}
+		return
+	}
+
+	db, err := ndb.Open(*ndbfile)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	fname := *outfile
+	if fname == "" {
+		fname = db.Files()[0]
+	}
+
+	if err := db.AddHosts(fname, hosts); err != nil {
+		errs.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "added %d hosts to %s\n", len(hosts), fname)
+}
+
+// Template tuples from attr=val arguments.
+func parsetuples(args []string) (ndb.Record, error) {
+	var rec ndb.Record
+
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("bad tuple %q, want attr=val", arg)
+		}
+		rec = append(rec, ndb.Tuple{Attr: arg[:i], Val: arg[i+1:]})
+	}
+
+	return rec, nil
+}
+
+// Hosts named by spec, numbered up from the address first.
+func fromnames(spec, first string, tmpl ndb.Record) (ndb.RecordSet, error) {
+	ip := net.ParseIP(first)
+	if ip == nil {
+		return nil, fmt.Errorf("bad address %q", first)
+	}
+
+	var list []string
+
+	for _, s := range strings.Split(spec, ",") {
+		expanded, err := expand(s)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, expanded...)
+	}
+
+	return ndb.BulkHosts(tmpl, list, ip)
+}
+
+var rangere = regexp.MustCompile(`^(.*)\[([0-9]+)-([0-9]+)\](.*)$`)
+
+// Expand name[lo-hi] to each name in the range, zero padded to the
+// width of lo.
+func expand(s string) ([]string, error) {
+	m := rangere.FindStringSubmatch(s)
+	if m == nil {
+		return []string{s}, nil
+	}
+
+	lo, _ := strconv.Atoi(m[2])
+	hi, _ := strconv.Atoi(m[3])
+
+	if hi < lo {
+		return nil, fmt.Errorf("bad range %q", s)
+	}
+
+	var list []string
+	for i := lo; i <= hi; i++ {
+		list = append(list, fmt.Sprintf("%s%0*d%s", m[1], len(m[2]), i, m[4]))
+	}
+
+	return list, nil
+}
+
+// Hosts from the rows of a csv file, whose header row names the
+// attribute of each column. Empty cells are left out.
+func readcsv(fname string, tmpl ndb.Record) (ndb.RecordSet, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fname, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no header row", fname)
+	}
+
+	var hosts ndb.RecordSet
+
+	for _, row := range rows[1:] {
+		var host ndb.Record
+		for i, val := range row {
+			if val != "" {
+				host = append(host, ndb.Tuple{Attr: rows[0][i], Val: val})
+			}
+		}
+		hosts = append(hosts, append(host, tmpl...))
+	}
+
+	return hosts, nil
+}
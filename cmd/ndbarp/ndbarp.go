@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	unknown = flag.Bool("u", false, "also report neighbors not in the database")
+	seen    = flag.Bool("seen", false, "set seen= on hosts found in the neighbor tables")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+// A neighbor table entry.
+type neighbor struct {
+	ip, ether string
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-u] [-seen]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	// the tables' addresses may be written differently than ndb's
+	db, err := ndb.Open(*ndbfile,
+		ndb.WithNormalizer("ip", ndb.NormalizeIP),
+		ndb.WithNormalizer("ether", ndb.NormalizeEther))
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	neighbors, err := readneighbors()
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	bad := reconcile(os.Stdout, db, neighbors)
+
+	if *seen {
+		if err := db.MarkSeen(time.Now(), present(neighbors)); err != nil {
+			errs.Fatal(err)
+		}
+	}
+
+	if bad {
+		os.Exit(1)
+	}
+}
+
+// Return a match for MarkSeen: records with the ip of a neighbor whose
+// ether agrees with theirs, or who have no ether recorded.
+func present(neighbors []neighbor) func(ndb.Record) bool {
+	ethers := make(map[string]string)
+	for _, nb := range neighbors {
+		ethers[ndb.NormalizeIP(nb.ip)] = nb.ether
+	}
+
+	return func(rec ndb.Record) bool {
+		have := etherset(ndb.RecordSet{rec})
+
+		for _, tuple := range rec {
+			if ether, ok := ethers[ndb.NormalizeIP(tuple.Val)]; ok && tuple.Attr == "ip" && (len(have) == 0 || have[ether]) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Compare neighbors against the ip= and ether= tuples in the database,
+// printing a line for each problem. Returns true if there were any.
+func reconcile(w io.Writer, db *ndb.Ndb, neighbors []neighbor) bool {
+	bad := false
+
+	for _, nb := range neighbors {
+		byip := db.Search("ip", nb.ip)
+		byether := db.Search("ether", nb.ether)
+
+		switch {
+		case byip == nil && byether == nil:
+			if *unknown {
+				fmt.Fprintf(w, "unknown: ip=%s ether=%s is not in the database\n", nb.ip, nb.ether)
+				bad = true
+			}
+
+		case byip == nil:
+			fmt.Fprintf(w, "moved: ether=%s is at ip=%s, database has ip=%s\n",
+				nb.ether, nb.ip, byether.Search("ip"))
+			bad = true
+
+		default:
+			ethers := etherset(byip)
+			if len(ethers) == 0 {
+				fmt.Fprintf(w, "missing: ip=%s has no ether, network has ether=%s\n", nb.ip, nb.ether)
+				bad = true
+			} else if !ethers[nb.ether] {
+				fmt.Fprintf(w, "mismatch: ip=%s is ether=%s, database has ether=%s\n",
+					nb.ip, nb.ether, byip.Search("ether"))
+				bad = true
+			}
+		}
+	}
+
+	return bad
+}
+
+// Collect the normalized ether addresses of records.
+func etherset(recs ndb.RecordSet) map[string]bool {
+	ethers := make(map[string]bool)
+
+	for _, rec := range recs {
+		for _, tuple := range rec {
+			if tuple.Attr == "ether" {
+				ethers[ndb.NormalizeEther(tuple.Val)] = true
+			}
+		}
+	}
+
+	return ethers
+}
+
+// Read the system's ARP and NDP tables. On Linux the ARP table comes
+// from /proc/net/arp and the NDP table from ip(8); elsewhere from
+// arp(8) and ndp(8). A missing NDP tool is not an error.
+func readneighbors() ([]neighbor, error) {
+	var neighbors []neighbor
+
+	if data, err := ioutil.ReadFile("/proc/net/arp"); err == nil {
+		neighbors = parseprocarp(data)
+		if out, err := exec.Command("ip", "-6", "neigh", "show").Output(); err == nil {
+			neighbors = append(neighbors, parseipneigh(out)...)
+		}
+		return neighbors, nil
+	}
+
+	out, err := exec.Command("arp", "-an").Output()
+	if err != nil {
+		return nil, fmt.Errorf("arp: %s", err)
+	}
+
+	neighbors = parsearp(out)
+
+	if out, err := exec.Command("ndp", "-an").Output(); err == nil {
+		neighbors = append(neighbors, parsendp(out)...)
+	}
+
+	return neighbors, nil
+}
+
+// Parse /proc/net/arp, skipping incomplete entries.
+func parseprocarp(data []byte) []neighbor {
+	var neighbors []neighbor
+
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	scan.Scan() // header
+
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		if len(f) < 4 || f[2] == "0x0" {
+			continue
+		}
+		neighbors = append(neighbors, neighbor{f[0], ndb.NormalizeEther(f[3])})
+	}
+
+	return neighbors
+}
+
+// Parse "ip -6 neigh" output: fe80::1 dev eth0 lladdr 00:11:22:33:44:55 router REACHABLE
+func parseipneigh(data []byte) []neighbor {
+	var neighbors []neighbor
+
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		for i := 1; i+1 < len(f); i++ {
+			if f[i] == "lladdr" {
+				neighbors = append(neighbors, neighbor{f[0], ndb.NormalizeEther(f[i+1])})
+				break
+			}
+		}
+	}
+
+	return neighbors
+}
+
+var arpline = regexp.MustCompile(`\(([0-9.]+)\) at ([0-9a-fA-F:]+)`)
+
+// Parse BSD "arp -an" output: ? (10.0.0.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]
+func parsearp(data []byte) []neighbor {
+	var neighbors []neighbor
+
+	for _, m := range arpline.FindAllSubmatch(data, -1) {
+		neighbors = append(neighbors, neighbor{string(m[1]), ndb.NormalizeEther(string(m[2]))})
+	}
+
+	return neighbors
+}
+
+// Parse BSD "ndp -an" output: fe80::1%en0  0:11:22:33:44:55  en0 23h59m58s S R
+func parsendp(data []byte) []neighbor {
+	var neighbors []neighbor
+
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	scan.Scan() // header
+
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		if len(f) < 2 || !strings.Contains(f[1], ":") {
+			continue
+		}
+		ip := f[0]
+		if i := strings.Index(ip, "%"); i >= 0 {
+			ip = ip[:i]
+		}
+		neighbors = append(neighbors, neighbor{ip, ndb.NormalizeEther(f[1])})
+	}
+
+	return neighbors
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"os"
+	"strings"
+)
+
+var (
+	keyattr = flag.String("k", "sys", "attribute naming the same host in each database")
+	attrs   = flag.String("a", "", "comma-separated attributes to compare (default: all)")
+	errfmt  = flag.String("e", "text", "output format for conflicts and errors: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 2}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-k attr] [-a attrs] name=ndbfile name=ndbfile...\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	f := &ndb.Federation{}
+
+	for _, arg := range flag.Args() {
+		i := strings.IndexByte(arg, '=')
+		if i < 1 {
+			usage()
+			os.Exit(1)
+		}
+
+		db, err := ndb.Open(arg[i+1:])
+		if err != nil {
+			errs.Fatal(err)
+		}
+
+		f.Members = append(f.Members, ndb.Member{Name: arg[:i], DB: db})
+	}
+
+	var compare []string
+	if *attrs != "" {
+		compare = strings.Split(*attrs, ",")
+	}
+
+	conflicts := f.Conflicts(*keyattr, compare...)
+
+	for _, c := range conflicts {
+		if *errfmt == "json" {
+			json.NewEncoder(os.Stdout).Encode(c)
+			continue
+		}
+
+		fmt.Printf("%s=%s %s:", c.Key.Attr, c.Key.Val, c.Attr)
+		for _, m := range f.Members {
+			if vals, ok := c.Vals[m.Name]; ok {
+				fmt.Printf(" %s=%s", m.Name, strings.Join(vals, ","))
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/export"
+	"github.com/mischief/ndb/internal/diag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	redact  = flag.String("redact", "", "redaction policy, e.g. password=hide,psk=hash")
+	sorted  = flag.Bool("sort", false, "sort the output, so regenerated files diff cleanly")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+// An export format, run with the opened database and its arguments.
+type format struct {
+	usage string
+	run   func(db *ndb.Ndb, args []string) error
+}
+
+var formats = map[string]format{
+	"prometheus": format{"[-port n] [-labels attr,...]", prometheus},
+	"ldif":       format{"-base dn", ldif},
+	"factotum":   format{"[-secret attr,...]", factotum},
+	"switch":     format{"[-style ios|junos] switch", switchconf},
+	"bgp":        format{"[-style bird|frr] router", bgp},
+	"nagios":     format{"[-host template] [-service template]", nagios},
+	"hosts":      format{"", hosts},
+	"cloudinit":  format{"-dir dir attr val", cloudinit},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-redact policy] [-sort] format [options] [attr [val]]\n", os.Args[0])
+
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "formats:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", name, formats[name].usage)
+	}
+
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(1)
+	}
+
+	f, ok := formats[flag.Arg(0)]
+
+	if !ok {
+		usage()
+		os.Exit(1)
+	}
+
+	policy, err := ndb.ParseRedactPolicy(*redact)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	ndb.Redact = policy
+
+	if *sorted {
+		export.Order = export.SortedOrder
+	}
+
+	db, err := ndb.Open(*ndbfile)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	if err := f.run(db, flag.Args()[1:]); err != nil {
+		errs.Fatal(err)
+	}
+}
+
+// Select records with the remaining arguments: all of them,
+// or those matching attr (and val, if given).
+func selectrecs(db *ndb.Ndb, fs *flag.FlagSet) (ndb.RecordSet, error) {
+	switch fs.NArg() {
+	case 0:
+		var recs ndb.RecordSet
+		db.Walk(func(rec ndb.Record, pos ndb.Pos) bool {
+			recs = append(recs, rec)
+			return true
+		})
+		return recs, nil
+	case 1, 2:
+		return db.Search(fs.Arg(0), fs.Arg(1)), nil
+	}
+
+	return nil, fmt.Errorf("%s: too many arguments", fs.Name())
+}
+
+func prometheus(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("prometheus", flag.ExitOnError)
+	port := fs.Int("port", 0, "port to append to each target")
+	labels := fs.String("labels", "", "comma separated attributes to use as labels")
+	fs.Parse(args)
+
+	recs, err := selectrecs(db, fs)
+	if err != nil {
+		return err
+	}
+
+	opt := &export.PrometheusOptions{Port: *port}
+	if *labels != "" {
+		opt.Labels = strings.Split(*labels, ",")
+	}
+
+	return export.Prometheus(os.Stdout, db, recs, opt)
+}
+
+func ldif(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("ldif", flag.ExitOnError)
+	base := fs.String("base", "", "base DN for entries, e.g. ou=hosts,dc=example,dc=com")
+	fs.Parse(args)
+
+	recs, err := selectrecs(db, fs)
+	if err != nil {
+		return err
+	}
+
+	return export.LDIF(os.Stdout, recs, &export.LDIFOptions{BaseDN: *base})
+}
+
+func factotum(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("factotum", flag.ExitOnError)
+	secret := fs.String("secret", "", "comma separated attributes to mark secret, besides password")
+	fs.Parse(args)
+
+	recs, err := selectrecs(db, fs)
+	if err != nil {
+		return err
+	}
+
+	opt := &export.FactotumOptions{}
+	if *secret != "" {
+		opt.Secret = strings.Split(*secret, ",")
+	}
+
+	return export.Factotum(os.Stdout, recs, opt)
+}
+
+func hosts(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("hosts", flag.ExitOnError)
+	fs.Parse(args)
+
+	recs, err := selectrecs(db, fs)
+	if err != nil {
+		return err
+	}
+
+	return export.Hosts(os.Stdout, recs)
+}
+
+// Write a NoCloud seed for one host: meta-data, user-data and
+// network-config files in a directory.
+func cloudinit(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("cloudinit", flag.ExitOnError)
+	dir := fs.String("dir", "", "directory to write meta-data, user-data and network-config to")
+	fs.Parse(args)
+
+	if *dir == "" || fs.NArg() != 2 {
+		return fmt.Errorf("cloudinit: need -dir and a host attr val")
+	}
+
+	recs := db.Search(fs.Arg(0), fs.Arg(1))
+	if len(recs) != 1 {
+		return fmt.Errorf("cloudinit: %s=%s matches %d records, need 1", fs.Arg(0), fs.Arg(1), len(recs))
+	}
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"meta-data", func(w io.Writer) error { return export.CloudInitMetaData(w, recs[0]) }},
+		{"user-data", func(w io.Writer) error { return export.CloudInitUserData(w, recs[0]) }},
+		{"network-config", func(w io.Writer) error { return export.CloudInitNetwork(w, db, recs[0]) }},
+	}
+
+	for _, file := range files {
+		var buf bytes.Buffer
+		if err := file.write(&buf); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(*dir, file.name), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func nagios(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("nagios", flag.ExitOnError)
+	host := fs.String("host", "generic-host", "host template to use")
+	service := fs.String("service", "generic-service", "service template to use")
+	fs.Parse(args)
+
+	recs, err := selectrecs(db, fs)
+	if err != nil {
+		return err
+	}
+
+	return export.Nagios(os.Stdout, recs, &export.NagiosOptions{HostTemplate: *host, ServiceTemplate: *service})
+}
+
+func switchconf(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("switch", flag.ExitOnError)
+	style := fs.String("style", "ios", "configuration style: ios or junos")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("switch: need a switch name")
+	}
+
+	return export.Switch(os.Stdout, db.SwitchPorts(fs.Arg(0)), &export.SwitchOptions{Style: *style})
+}
+
+func bgp(db *ndb.Ndb, args []string) error {
+	fs := flag.NewFlagSet("bgp", flag.ExitOnError)
+	style := fs.String("style", "bird", "configuration style: bird or frr")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("bgp: need a router name")
+	}
+
+	return export.BGP(os.Stdout, db, fs.Arg(0), &export.BGPOptions{Style: *style})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"io/ioutil"
+	"os"
+)
+
+var (
+	list   = flag.Bool("l", false, "list files whose formatting differs")
+	write  = flag.Bool("w", false, "write the result to the file instead of stdout")
+	diff   = flag.Bool("d", false, "print diffs instead of the formatted text")
+	errfmt = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Prefix: os.Args[0] + ": ", Status: 2}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-l] [-w] [-d] [-e text|json] [file ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "lays out ndb files canonically, reading stdin if no files are given\n")
+	flag.PrintDefaults()
+}
+
+// Format the text of the file name, writing the result as the flags
+// say.
+func format(name string, data []byte) error {
+	f, err := ndb.ParseFile(bytes.NewReader(data))
+	if err != nil {
+		if perr, ok := err.(*ndb.ParseError); ok {
+			perr.File = name
+		}
+		return err
+	}
+
+	report(name, data, f)
+
+	if !*list && !*diff {
+		_, err = os.Stdout.Write(f.Bytes())
+	}
+
+	return err
+}
+
+// Format the file name in place, locked and replaced whole as the
+// package's writers do.
+func rewrite(name string) error {
+	return ndb.EditFile(name, func(f *ndb.File) error {
+		report(name, f.Bytes(), f)
+		return nil
+	})
+}
+
+// Format f, parsed from data, listing or diffing it as the flags say.
+func report(name string, data []byte, f *ndb.File) {
+	f.Format()
+
+	if *list && !bytes.Equal(f.Bytes(), data) {
+		fmt.Println(name)
+	}
+
+	if *diff {
+		fmt.Print(f.Diff(name, data))
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintf(os.Stderr, "%s: can't use -w on stdin\n", os.Args[0])
+			os.Exit(2)
+		}
+
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err == nil {
+			err = format("<standard input>", data)
+		}
+		if err != nil {
+			errs.Fatal(err)
+		}
+		return
+	}
+
+	status := 0
+
+	for _, name := range flag.Args() {
+		var err error
+		if *write {
+			err = rewrite(name)
+		} else {
+			var data []byte
+			if data, err = ioutil.ReadFile(name); err == nil {
+				err = format(name, data)
+			}
+		}
+		if err != nil {
+			errs.Print(err)
+			status = 2
+		}
+	}
+
+	os.Exit(status)
+}
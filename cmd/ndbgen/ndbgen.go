@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb/ndbgen"
+	"os"
+)
+
+var (
+	seed     = flag.Int64("seed", 0, "seed for the random choices")
+	domain   = flag.String("dom", "example.com", "domain of the hosts")
+	network  = flag.String("net", "10.0.0.0/8", "network to carve the subnets from")
+	subnets  = flag.Int("subnets", 4, "number of subnets")
+	hosts    = flag.Int("hosts", 100, "number of hosts")
+	services = flag.Bool("services", false, "add tcp= and udp= service records")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-seed n] [-dom domain] [-net cidr] [-subnets n] [-hosts n] [-services]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "writes a generated database to stdout\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	c := &ndbgen.Config{
+		Seed:     *seed,
+		Domain:   *domain,
+		Network:  *network,
+		Subnets:  *subnets,
+		Hosts:    *hosts,
+		Services: *services,
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+
+	err := ndbgen.Write(w, c)
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"os"
+	"regexp"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	attr    = flag.String("a", "", "only match values of this attribute")
+	icase   = flag.Bool("i", false, "ignore case")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 2}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-a attr] [-i] regexp\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	pattern := flag.Arg(0)
+	if *icase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	db, err := ndb.Open(*ndbfile)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	matched := false
+
+	db.Walk(func(rec ndb.Record, pos ndb.Pos) bool {
+		for _, tuple := range rec {
+			if *attr != "" && tuple.Attr != *attr {
+				continue
+			}
+
+			if re.MatchString(tuple.Val) {
+				printrecord(pos, rec)
+				matched = true
+				break
+			}
+		}
+		return true
+	})
+
+	// like grep, exit 1 if nothing matched and 2 on errors
+	if !matched {
+		os.Exit(1)
+	}
+}
+
+// Print a record on one line, prefixed by where it was found.
+func printrecord(pos ndb.Pos, rec ndb.Record) {
+	var buf bytes.Buffer
+	if _, err := rec.WriteLine(&buf); err != nil {
+		errs.Fatal(fmt.Errorf("%s: %s", pos, err))
+	}
+
+	fmt.Printf("%s: %s", pos, buf.Bytes())
+}
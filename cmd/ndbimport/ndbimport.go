@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb/netbox"
+	"os"
+)
+
+var (
+	url   = flag.String("url", "", "NetBox base URL")
+	token = flag.String("token", os.Getenv("NETBOX_TOKEN"), "NetBox API token (default $NETBOX_TOKEN)")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s -url url [-token token] netbox\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 || flag.Arg(0) != "netbox" || *url == "" {
+		usage()
+		os.Exit(1)
+	}
+
+	c := &netbox.Client{URL: *url, Token: *token}
+
+	recs, err := c.Import()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, rec := range recs {
+		fmt.Println(rec)
+	}
+}
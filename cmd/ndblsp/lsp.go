@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"log"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The parts of the Language Server Protocol used here.
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lsprange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string   `json:"uri"`
+	Range lsprange `json:"range"`
+}
+
+type diagnostic struct {
+	Range    lsprange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type textdocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type docposition struct {
+	TextDocument textdocument `json:"textDocument"`
+	Position     position     `json:"position"`
+}
+
+type didchange struct {
+	TextDocument   textdocument `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type completionitem struct {
+	Label string `json:"label"`
+	Kind  int    `json:"kind"`
+}
+
+// LSP diagnostic severities and completion item kinds.
+const (
+	lsperror   = 1
+	lspwarning = 2
+
+	kindfield = 5
+	kindvalue = 12
+)
+
+// A language server for the files of an ndb database.
+type server struct {
+	w    io.Writer
+	docs map[string]string // Text of open documents, by URI
+}
+
+func newserver(w io.Writer) *server {
+	return &server{w: w, docs: make(map[string]string)}
+}
+
+// Handle a message, returning true at exit.
+func (s *server) handle(msg *message) bool {
+	var result interface{}
+	var err error
+
+	switch msg.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    1, // full text
+					"save":      true,
+				},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"="},
+				},
+			},
+			"serverInfo": map[string]string{"name": "ndblsp"},
+		}
+
+	case "shutdown":
+		result = nil
+
+	case "exit":
+		return true
+
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument textdocument `json:"textDocument"`
+		}
+		if err = json.Unmarshal(msg.Params, &p); err == nil {
+			s.docs[p.TextDocument.URI] = p.TextDocument.Text
+			s.diagnose(p.TextDocument.URI)
+		}
+
+	case "textDocument/didChange":
+		var p didchange
+		if err = json.Unmarshal(msg.Params, &p); err == nil && len(p.ContentChanges) > 0 {
+			s.docs[p.TextDocument.URI] = p.ContentChanges[len(p.ContentChanges)-1].Text
+			s.diagnose(p.TextDocument.URI)
+		}
+
+	case "textDocument/didSave":
+		var p docposition
+		if err = json.Unmarshal(msg.Params, &p); err == nil {
+			s.diagnose(p.TextDocument.URI)
+		}
+
+	case "textDocument/didClose":
+		var p docposition
+		if err = json.Unmarshal(msg.Params, &p); err == nil {
+			delete(s.docs, p.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+				"uri": p.TextDocument.URI, "diagnostics": []diagnostic{},
+			})
+		}
+
+	case "textDocument/hover":
+		var p docposition
+		if err = json.Unmarshal(msg.Params, &p); err == nil {
+			result, err = s.hover(p)
+		}
+
+	case "textDocument/definition":
+		var p docposition
+		if err = json.Unmarshal(msg.Params, &p); err == nil {
+			result, err = s.definition(p)
+		}
+
+	case "textDocument/completion":
+		var p docposition
+		if err = json.Unmarshal(msg.Params, &p); err == nil {
+			result, err = s.completion(p)
+		}
+
+	default:
+		if msg.ID != nil {
+			s.reply(msg.ID, nil, &rpcerror{-32601, "method not found: " + msg.Method})
+		}
+		return false
+	}
+
+	if msg.ID == nil {
+		if err != nil {
+			log.Print(err)
+		}
+		return false
+	}
+
+	if err != nil {
+		s.reply(msg.ID, nil, &rpcerror{-32603, err.Error()})
+	} else {
+		s.reply(msg.ID, result, nil)
+	}
+
+	return false
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}, rerr *rpcerror) {
+	msg := &message{ID: id, Result: result, Error: rerr}
+
+	// a null result must still be sent
+	if result == nil && rerr == nil {
+		msg.Result = json.RawMessage("null")
+	}
+
+	if err := writemessage(s.w, msg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *server) notify(method string, params interface{}) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writemessage(s.w, &message{Method: method, Params: raw}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Convert between file URIs and paths.
+func uripath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+func pathuri(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// Open the database a file belongs to: the one given with -f, or the
+// default database, if the file is one of its files, or else the file
+// by itself. Open documents are read as they are being edited.
+func (s *server) dbfor(path string) (*ndb.Ndb, error) {
+	docs := make(overlay)
+	for uri, text := range s.docs {
+		if abs, err := filepath.Abs(uripath(uri)); err == nil {
+			docs[abs] = text
+		}
+	}
+
+	if db, err := ndb.Open(*ndbfile, ndb.WithFS(docs)); err == nil {
+		for _, file := range db.Files() {
+			if samefile(file, path) {
+				return db, nil
+			}
+		}
+	}
+
+	return ndb.Open(path, ndb.WithFS(docs))
+}
+
+func samefile(a, b string) bool {
+	a, erra := filepath.Abs(a)
+	b, errb := filepath.Abs(b)
+	return erra == nil && errb == nil && a == b
+}
+
+// Publish diagnostics for a document as it is being edited: errors and
+// warnings opening it, and the findings of the database report and
+// switch checks.
+func (s *server) diagnose(uri string) {
+	path := uripath(uri)
+	diags := []diagnostic{}
+
+	db, err := s.dbfor(path)
+
+	if err != nil {
+		d := ndb.ErrorDiagnostic(err)
+		diags = append(diags, diagnostic{lineRange(d.Line), lsperror, "ndb", d.Message})
+	} else {
+		found := append(db.Warnings(), db.Report().Diagnostics()...)
+		for _, d := range append(found, db.CheckSwitches()...) {
+			if samefile(d.File, path) {
+				sev := lspwarning
+				if d.Severity == ndb.SeverityError {
+					sev = lsperror
+				}
+				diags = append(diags, diagnostic{lineRange(d.Line), sev, "ndb", d.Message})
+			}
+		}
+	}
+
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diags})
+}
+
+// The range covering a whole line, counted from 1; 0 means the first.
+func lineRange(line int) lsprange {
+	if line > 0 {
+		line--
+	}
+	return lsprange{position{line, 0}, position{line + 1, 0}}
+}
+
+// Return the text of a line of an open document.
+func (s *server) line(uri string, n int) string {
+	lines := strings.Split(s.docs[uri], "\n")
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return lines[n]
+}
+
+// Return the byte offset in line of a position's character, which
+// LSP counts in UTF-16 code units.
+func offset(line string, char int) int {
+	n := 0
+	for i, r := range line {
+		if n >= char {
+			return i
+		}
+		n++
+		if r >= 0x10000 {
+			n++ // a surrogate pair
+		}
+	}
+
+	return len(line)
+}
+
+// Return the attr=val word around a position in the document.
+func (s *server) word(p docposition) string {
+	line := s.line(p.TextDocument.URI, p.Position.Line)
+
+	start := offset(line, p.Position.Character)
+	end := start
+
+	for start > 0 && !strings.ContainsRune(" \t", rune(line[start-1])) {
+		start--
+	}
+	for end < len(line) && !strings.ContainsRune(" \t", rune(line[end])) {
+		end++
+	}
+
+	return line[start:end]
+}
+
+// Find the record in file whose text contains line, counted from 0.
+func recordat(db *ndb.Ndb, file string, line int) (ndb.Record, ndb.Pos) {
+	var found ndb.Record
+	var pos ndb.Pos
+
+	db.Walk(func(rec ndb.Record, p ndb.Pos) bool {
+		if samefile(p.File, file) && p.Line <= line+1 {
+			found, pos = rec, p
+		}
+		return true
+	})
+
+	return found, pos
+}
+
+// Show the values the record at the position inherits from its
+// networks, as Ipinfo resolves them.
+func (s *server) hover(p docposition) (interface{}, error) {
+	path := uripath(p.TextDocument.URI)
+
+	db, err := s.dbfor(path)
+	if err != nil {
+		return nil, nil
+	}
+
+	rec, _ := recordat(db, path, p.Position.Line)
+	if rec == nil || rec.Search("ipnet") != "" {
+		return nil, nil
+	}
+
+	// every attribute some network provides
+	seen := make(map[string]bool)
+	for _, nw := range db.Networks() {
+		for _, tuple := range nw.Record {
+			switch tuple.Attr {
+			case "ipnet", "ip", "ipmask":
+			default:
+				seen[tuple.Attr] = true
+			}
+		}
+	}
+
+	var rattrs []string
+	for attr := range seen {
+		rattrs = append(rattrs, attr)
+	}
+	sort.Strings(rattrs)
+
+	key := rec.Primary()
+	tuples := db.Ipinfo(key.Attr, key.Val, rattrs...)
+	if tuples == nil {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "```\n# ipinfo %s=%s\n", key.Attr, key.Val)
+	for _, tuple := range tuples {
+		fmt.Fprintf(&b, "%s=%s\n", tuple.Attr, tuple.Val)
+	}
+	b.WriteString("```")
+
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": b.String()},
+	}, nil
+}
+
+// Attributes whose values name a host or network.
+var defattrs = []string{"sys", "dom", "ip", "ipnet"}
+
+// Jump from a value to the record it names: the first, other than
+// the one at the position, with a sys, dom, ip or ipnet tuple of
+// that value.
+func (s *server) definition(p docposition) (interface{}, error) {
+	path := uripath(p.TextDocument.URI)
+
+	word := s.word(p)
+	i := strings.Index(word, "=")
+	if i < 0 || i == len(word)-1 {
+		return nil, nil
+	}
+	val := strings.Trim(word[i+1:], `"`)
+
+	db, err := s.dbfor(path)
+	if err != nil {
+		return nil, nil
+	}
+
+	_, here := recordat(db, path, p.Position.Line)
+
+	var loc *location
+
+	db.Walk(func(rec ndb.Record, pos ndb.Pos) bool {
+		if pos == here {
+			return true
+		}
+
+		for _, attr := range defattrs {
+			for _, tuple := range rec {
+				if tuple.Attr == attr && tuple.Val == val {
+					loc = &location{pathuri(pos.File), lineRange(pos.Line)}
+					return false
+				}
+			}
+		}
+
+		return true
+	})
+
+	if loc == nil {
+		return nil, nil
+	}
+
+	return loc, nil
+}
+
+// Complete attribute names, or after an =, the values in the
+// database for the attribute.
+func (s *server) completion(p docposition) (interface{}, error) {
+	line := s.line(p.TextDocument.URI, p.Position.Line)
+	line = line[:offset(line, p.Position.Character)]
+
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+
+	db, err := s.dbfor(uripath(p.TextDocument.URI))
+	if err != nil {
+		return []completionitem{}, nil
+	}
+
+	items := []completionitem{}
+
+	if i := strings.Index(word, "="); i >= 0 {
+		for _, val := range db.Vals(word[:i]) {
+			if strings.HasPrefix(val, word[i+1:]) {
+				items = append(items, completionitem{val, kindvalue})
+			}
+		}
+	} else {
+		for _, attr := range db.Attrs() {
+			if strings.HasPrefix(attr, word) {
+				items = append(items, completionitem{attr, kindfield})
+			}
+		}
+	}
+
+	return items, nil
+}
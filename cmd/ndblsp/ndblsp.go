@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"os"
+	"strconv"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// A JSON-RPC 2.0 message, as request, response or notification.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcerror        `json:"error,omitempty"`
+}
+
+type rpcerror struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	// stdout carries the protocol
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("ndblsp: ")
+
+	s := newserver(os.Stdout)
+	r := textproto.NewReader(bufio.NewReader(os.Stdin))
+
+	for {
+		msg, err := readmessage(r)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if s.handle(msg) {
+			return
+		}
+	}
+}
+
+// Read one message, framed by a Content-Length header.
+func readmessage(r *textproto.Reader) (*message, error) {
+	hdr, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(hdr.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length: %v", err)
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r.R, body); err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// Write one message, framed by a Content-Length header.
+func writemessage(w io.Writer, msg *message) error {
+	msg.JSONRPC = "2.0"
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The files of the operating system, with the text of the open
+// documents in place of what is saved, so the database is analyzed as
+// it is being edited. Names are operating system paths, as the
+// database's file= tuples give them, rather than fs.FS's slash
+// separated paths.
+type overlay map[string]string // Text of open documents, by absolute path
+
+func (o overlay) Open(name string) (fs.File, error) {
+	if abs, err := filepath.Abs(name); err == nil {
+		if text, ok := o[abs]; ok {
+			return &docfile{strings.NewReader(text), docinfo{filepath.Base(name), int64(len(text))}}, nil
+		}
+	}
+
+	return os.Open(name)
+}
+
+// An open document, read as a file.
+type docfile struct {
+	*strings.Reader
+	info docinfo
+}
+
+func (f *docfile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *docfile) Close() error {
+	return nil
+}
+
+// The file information of an open document. Its modification time is
+// zero, as it has none until saved.
+type docinfo struct {
+	name string
+	size int64
+}
+
+func (i docinfo) Name() string       { return i.name }
+func (i docinfo) Size() int64        { return i.size }
+func (i docinfo) Mode() fs.FileMode  { return 0644 }
+func (i docinfo) ModTime() time.Time { return time.Time{} }
+func (i docinfo) IsDir() bool        { return false }
+func (i docinfo) Sys() interface{}   { return nil }
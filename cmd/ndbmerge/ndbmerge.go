@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var errfmt = flag.String("e", "text", "error output format: text or json")
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 2}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-e text|json] base ours theirs\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "merges the changes from base to theirs into ours, record by record\n")
+	flag.PrintDefaults()
+}
+
+// Identifies a record across the three versions: its key,
+// and which record with that key it is.
+type ident struct {
+	key ndb.Tuple
+	n   int
+}
+
+// A version of the file, records by identity.
+type version struct {
+	file  *ndb.File
+	order []ident
+	recs  map[ident]ndb.FileRecord
+}
+
+func load(fname string) (*version, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ndb.ParseFile(bytes.NewReader(data))
+	if err != nil {
+		if perr, ok := err.(*ndb.ParseError); ok {
+			perr.File = fname
+		}
+		return nil, err
+	}
+
+	return index(f), nil
+}
+
+// Index the records of f by identity.
+func index(f *ndb.File) *version {
+	v := &version{file: f, recs: make(map[ident]ndb.FileRecord)}
+	count := make(map[ndb.Tuple]int)
+
+	for _, r := range f.Records() {
+		key := r.Record().Key()
+		id := ident{key, count[key]}
+		count[key]++
+
+		v.order = append(v.order, id)
+		v.recs[id] = r
+	}
+
+	return v
+}
+
+// Return the record with identity id, or nil if there is none.
+func (v *version) record(id ident) ndb.Record {
+	r, ok := v.recs[id]
+	if !ok {
+		return nil
+	}
+
+	return r.Record()
+}
+
+// Return the text of the record with identity id, as it is in the
+// file, comments and continuation lines and all.
+func (v *version) lines(id ident) []*ndb.Line {
+	r, ok := v.recs[id]
+	if !ok {
+		return nil
+	}
+
+	first, last := r.Lines[0], r.Lines[len(r.Lines)-1]
+
+	var lines []*ndb.Line
+	for _, l := range v.file.Lines[first : last+1] {
+		lines = append(lines, &ndb.Line{Text: l.Text})
+	}
+
+	return lines
+}
+
+func same(a, b ndb.Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Merge one record, returning the result (nil if deleted)
+// and whether the two sides conflict.
+func merge1(base, ours, theirs ndb.Record) (ndb.Record, bool) {
+	switch {
+	case same(ours, theirs):
+		return ours, false
+	case same(ours, base):
+		return theirs, false
+	case same(theirs, base):
+		return ours, false
+	}
+
+	return nil, true
+}
+
+// Merge the changes from base to theirs into ours, keeping the text of
+// each record as it is on the side its merged value comes from, and
+// the comments and blank lines between ours's records. Returns the
+// number of conflicting records, written between conflict markers.
+func merge(base, ours, theirs *version) int {
+	conflicts := 0
+
+	marker := func(s string) *ndb.Line {
+		return &ndb.Line{Text: s}
+	}
+
+	// the merged text of the record with identity id
+	merged := func(id ident) []*ndb.Line {
+		rec, conflict := merge1(base.record(id), ours.record(id), theirs.record(id))
+
+		switch {
+		case conflict:
+			conflicts++
+			lines := append([]*ndb.Line{marker("<<<<<<< ours")}, ours.lines(id)...)
+			lines = append(append(lines, marker("=======")), theirs.lines(id)...)
+			return append(lines, marker(">>>>>>> theirs"))
+		case rec == nil:
+			return nil
+		case same(rec, ours.record(id)):
+			return ours.lines(id)
+		}
+
+		return theirs.lines(id)
+	}
+
+	f := ours.file
+	byline := make(map[int]ident)
+	for _, id := range ours.order {
+		byline[ours.recs[id].Lines[0]] = id
+	}
+
+	// records in our order, then those only they added, in theirs
+	var out []*ndb.Line
+	for i := 0; i < len(f.Lines); i++ {
+		id, ok := byline[i]
+		if !ok {
+			out = append(out, f.Lines[i])
+			continue
+		}
+
+		r := ours.recs[id]
+		out = append(out, merged(id)...)
+		i = r.Lines[len(r.Lines)-1]
+	}
+
+	for _, id := range theirs.order {
+		if _, ok := ours.recs[id]; ok {
+			continue
+		}
+
+		lines := merged(id)
+		if len(lines) == 0 {
+			continue
+		}
+
+		if n := len(out); n > 0 && strings.TrimSpace(out[n-1].Text) != "" {
+			out = append(out, &ndb.Line{})
+		}
+		out = append(out, lines...)
+	}
+
+	// an empty file has no final newline to keep
+	if len(f.Lines) == 0 && len(out) > 0 {
+		out = append(out, &ndb.Line{})
+	}
+
+	f.Lines = out
+
+	return conflicts
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 3 {
+		usage()
+		os.Exit(2)
+	}
+
+	base, err := load(flag.Arg(0))
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	theirs, err := load(flag.Arg(2))
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	// like git merge-file, the result replaces ours, locked and
+	// replaced whole as the package's writers do
+	conflicts := 0
+	err = ndb.EditFile(flag.Arg(1), func(f *ndb.File) error {
+		conflicts = merge(base, index(f), theirs)
+		return nil
+	})
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	if conflicts > 0 {
+		fmt.Fprintf(os.Stderr, "%d conflicting records\n", conflicts)
+		os.Exit(1)
+	}
+}
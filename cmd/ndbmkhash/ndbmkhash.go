@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"os"
+)
+
+var (
+	chain  = flag.Bool("chain", false, "hash every file listed by the database= record, not just file")
+	errfmt = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Prefix: os.Args[0] + ": ", Status: 1}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-chain] [-e text|json] file attr ...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "writes Plan 9 hash files file.attr for fast lookups by attr\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var opts []ndb.Option
+	if !*chain {
+		opts = append(opts, ndb.Single())
+	}
+
+	db, err := ndb.Open(flag.Arg(0), opts...)
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	for _, attr := range flag.Args()[1:] {
+		if err := db.WriteHash(attr); err != nil {
+			errs.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Shell completion scripts. Each one calls back into
+// "ndbquery __complete" with the words typed so far,
+// the last of which is the word being completed.
+var completions = map[string]string{
+	"bash": `_ndbquery() {
+	local IFS=$'\n'
+	COMPREPLY=($(ndbquery __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _ndbquery ndbquery
+`,
+	"zsh": `#compdef ndbquery
+_ndbquery() {
+	local -a comps
+	if [[ ${words[CURRENT-1]} == -f ]]; then
+		_files
+		return
+	fi
+	comps=(${(f)"$(ndbquery __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	compadd -a comps
+}
+compdef _ndbquery ndbquery
+`,
+	"fish": `function __ndbquery_complete
+	set -l words (commandline -opc)
+	ndbquery __complete $words[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c ndbquery -f -a '(__ndbquery_complete)'
+complete -c ndbquery -s f -r -F -d 'ndb file'
+`,
+}
+
+// Print the completion script for shell.
+func completion(shell string) error {
+	script, ok := completions[shell]
+	if !ok {
+		return fmt.Errorf("no completion for shell %q", shell)
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// Print candidates for the last word in args, one per line.
+// The first position completes to subcommands and attribute names,
+// and the value position completes to values of the preceding attribute.
+func complete(args []string) {
+	if len(args) == 0 {
+		return
+	}
+
+	cur := args[len(args)-1]
+	file := ""
+
+	// the flags, and whether each is boolean, so takes no value
+	var flags []string
+	boolean := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, "-"+f.Name)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			boolean[f.Name] = true
+		}
+	})
+
+	var words []string
+	for i := 0; i < len(args)-1; i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			words = append(words, args[i])
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+		val, hasval := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, val, hasval = name[:j], name[j+1:], true
+		}
+
+		if !hasval && !boolean[name] {
+			if i+1 == len(args)-1 {
+				// completing the flag's value, such as a file
+				// name; leave it to the shell
+				return
+			}
+			i++
+			val = args[i]
+		}
+
+		if name == "f" {
+			file = val
+		}
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		printprefixed(flags, cur)
+		return
+	}
+
+	db, err := ndb.Open(file)
+	if err != nil {
+		return
+	}
+
+	var candidates []string
+
+	if len(words) > 0 {
+		switch words[0] {
+		case "query":
+			words = words[1:]
+		case "dump", "stats", "text", "fuzzy":
+			return
+		case "tagged", "anytagged":
+			seen := make(map[string]bool)
+			db.Walk(func(rec ndb.Record, pos ndb.Pos) bool {
+				for _, tag := range rec.Tags() {
+					if !seen[tag] {
+						seen[tag] = true
+						candidates = append(candidates, tag)
+					}
+				}
+				return true
+			})
+			sort.Strings(candidates)
+			printprefixed(candidates, cur)
+			return
+		case "range":
+			if len(words) == 1 {
+				printprefixed(db.Attrs(), cur)
+			}
+			return
+		case "resolve":
+			// a host, then attributes
+			if len(words) == 1 {
+				candidates = db.Vals("sys")
+			} else {
+				candidates = db.Attrs()
+			}
+			printprefixed(candidates, cur)
+			return
+		}
+	}
+
+	switch len(words) {
+	case 0:
+		for name := range commands {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+		candidates = append(candidates, db.Attrs()...)
+	case 1:
+		candidates = db.Vals(words[0])
+	case 2:
+		// only attributes present in the matching records
+		seen := make(map[string]bool)
+		for _, rec := range db.Search(words[0], words[1]) {
+			for _, tuple := range rec {
+				if !seen[tuple.Attr] {
+					seen[tuple.Attr] = true
+					candidates = append(candidates, tuple.Attr)
+				}
+			}
+		}
+	}
+
+	printprefixed(candidates, cur)
+}
+
+// Print the candidates beginning with prefix.
+func printprefixed(candidates []string, prefix string) {
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			fmt.Println(c)
+		}
+	}
+}
+
+// Handle the hidden completion subcommands.
+// Returns false if args do not name one.
+func completecmd(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "__complete":
+		complete(args[1:])
+	case "__completion":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: %s __completion bash|zsh|fish\n", os.Args[0])
+			os.Exit(1)
+		}
+		if err := completion(args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		return false
+	}
+
+	return true
+}
@@ -4,57 +4,377 @@ import (
 	"flag"
 	"fmt"
 	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"github.com/mischief/ndb/internal/pager"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 )
 
 var (
-	ndbfile = flag.String("f", ndb.NdbLocal, "ndb file")
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	ipinfo  bool
+	nopage  = flag.Bool("nopage", false, "don't page output on a terminal")
+	wide    = flag.Bool("wide", false, "print each record on one line, without wrapping")
+	columns = flag.String("columns", "", "print the given comma-separated attributes of each record as aligned columns")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+	normal  = flag.Bool("normalize", false, "match dom, ip and ether values however they are formatted")
+	platfm  = flag.Bool("platform", false, "with -i and resolve, choose network tuples by the host's os= and arch=")
 )
 
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+func init() {
+	// as Plan 9's ndb/query -i
+	flag.BoolVar(&ipinfo, "i", false, "print rattrs as ndbipinfo(2) does, inheriting from ipnet records")
+	flag.BoolVar(&ipinfo, "ipinfo", false, "same as -i")
+}
+
+// A subcommand, run with the opened database and its arguments.
+type command struct {
+	usage string
+	narg  func(int) bool
+	run   func(db *ndb.Ndb, args []string)
+}
+
+var commands = map[string]command{
+	"query":     command{"attr val [rattr]", func(n int) bool { return n == 2 || n == 3 }, query},
+	"dump":      command{"", func(n int) bool { return n == 0 }, dump},
+	"stats":     command{"", func(n int) bool { return n == 0 }, stats},
+	"resolve":   command{"host attr...", func(n int) bool { return n >= 2 }, resolve},
+	"tagged":    command{"tag...", func(n int) bool { return n >= 1 }, tagged},
+	"anytagged": command{"tag...", func(n int) bool { return n >= 1 }, anytagged},
+	"range":     command{"attr lo hi", func(n int) bool { return n == 3 }, searchrange},
+	"text":      command{"term", func(n int) bool { return n == 1 }, text},
+	"fuzzy":     command{"term", func(n int) bool { return n == 1 }, fuzzy},
+}
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] attr val [rattr]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-columns attrs] [query] attr val [rattr]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] -i attr val rattr...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] [-wide | -columns attrs] dump\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] stats\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] resolve host attr...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] [-columns attrs] tagged|anytagged tag...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] [-columns attrs] range attr lo hi\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [-f ndbfile] [-columns attrs] text|fuzzy term\n", os.Args[0])
 	flag.PrintDefaults()
 }
 
 func main() {
+	if completecmd(os.Args[1:]) {
+		return
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 
-	narg := flag.NArg()
+	args := flag.Args()
+
+	// without a subcommand, behave as query
+	name := "query"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name = args[0]
+			args = args[1:]
+		}
+	}
+
+	cmd := commands[name]
+
+	if ipinfo {
+		if name != "query" {
+			usage()
+			os.Exit(1)
+		}
+		cmd = command{"attr val rattr...", func(n int) bool { return n >= 3 }, ipinfoquery}
+	}
 
-	if narg < 2 || narg > 3 {
+	if !cmd.narg(len(args)) {
 		usage()
 		os.Exit(1)
 	}
 
-	db, err := ndb.Open(*ndbfile)
+	var opts []ndb.Option
+	if *normal {
+		opts = append(opts,
+			ndb.WithNormalizer("dom", ndb.NormalizeDom),
+			ndb.WithNormalizer("ip", ndb.NormalizeIP),
+			ndb.WithNormalizer("ether", ndb.NormalizeEther))
+	}
+
+	db, err := ndb.Open(*ndbfile, opts...)
 
 	if err != nil {
-		fmt.Fprint(os.Stderr, err)
-		os.Exit(1)
+		errs.Fatal(err)
+	}
+
+	if !*nopage {
+		// errs.Fatal exits without running deferred functions
+		stop := pager.Start()
+		errs.AtExit(stop)
+		defer stop()
 	}
 
-	records := db.Search(flag.Arg(0), flag.Arg(1))
+	cmd.run(db, args)
+}
+
+// Print records matching attr=val, or just the rattr values.
+func query(db *ndb.Ndb, args []string) {
+	records := db.Search(args[0], args[1])
 
-	switch narg {
-	case 2:
-		// print all attributes
+	if records == nil {
+		suggest(db, args[0], args[1])
+	}
+
+	switch {
+	case *columns != "":
+		printcolumns(records)
+
+	case len(args) == 2:
+		printrecords(records)
+
+	case len(args) == 3:
+		// only print rattr
 		for _, rec := range records {
 			for _, tuple := range rec {
-				fmt.Printf("%s=%s ", tuple.Attr, tuple.Val)
+				if tuple.Attr == args[2] {
+					fmt.Printf("%s\n", tuple.Val)
+				}
 			}
+		}
+	}
+}
+
+// Tell the user about values close to one that wasn't found.
+func suggest(db *ndb.Ndb, attr, val string) {
+	if vals := db.Suggest(attr, val, 0); len(vals) > 0 {
+		fmt.Fprintf(os.Stderr, "%s=%s not found; did you mean %s=%s?\n", attr, val, attr, strings.Join(vals, " or "+attr+"="))
+	}
+}
+
+// Print records having all of the tags given, or any of them.
+func tagged(db *ndb.Ndb, args []string) {
+	printrecords(db.SearchTags(args...))
+}
+
+func anytagged(db *ndb.Ndb, args []string) {
+	printrecords(db.SearchAnyTag(args...))
+}
+
+// Print records with term in any attribute or value, or close to it.
+func text(db *ndb.Ndb, args []string) {
+	printrecords(db.SearchText(args[0]))
+}
+
+func fuzzy(db *ndb.Ndb, args []string) {
+	printrecords(db.SearchTextFuzzy(args[0], 0))
+}
+
+// Print records with an integer attr between lo and hi.
+func searchrange(db *ndb.Ndb, args []string) {
+	lo, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	hi, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	printrecords(db.SearchRange(args[0], lo, hi))
+}
+
+// Print each record on a line, or as columns with -columns.
+func printrecords(records ndb.RecordSet) {
+	if *columns != "" {
+		printcolumns(records)
+		return
+	}
+
+	for _, rec := range records {
+		for _, tuple := range rec {
+			fmt.Printf("%s=%s ", tuple.Attr, tuple.Val)
+		}
+		fmt.Print("\n")
+	}
+}
+
+// Print the rattr tuples for attr=val on one line, inheriting from
+// ipnet records, in the manner of Plan 9's ndb/query -i.
+func ipinfoquery(db *ndb.Ndb, args []string) {
+	tuples := lookup(db, args[0], args[1], args[2:]...)
+
+	if tuples == nil && db.Search(args[0], args[1]) == nil {
+		suggest(db, args[0], args[1])
+	}
+
+	printtuples(tuples)
+}
+
+// Print the effective values of attrs for a host, including those
+// inherited from the networks it is on. The host may be given as
+// attr=val, or as an ip address, sys name or domain name.
+func resolve(db *ndb.Ndb, args []string) {
+	host, attrs := args[0], args[1:]
+
+	var tuples ndb.Record
+
+	if i := strings.Index(host, "="); i > 0 {
+		tuples = lookup(db, host[:i], host[i+1:], attrs...)
+	} else {
+		for _, attr := range []string{"ip", "sys", "dom"} {
+			if attr == "ip" && net.ParseIP(host) == nil {
+				continue
+			}
+			if tuples = lookup(db, attr, host, attrs...); tuples != nil {
+				break
+			}
+		}
+	}
+
+	printtuples(tuples)
+}
+
+// Look up rattrs for attr=val with Ipinfo, or IpinfoPlatform
+// with -platform.
+func lookup(db *ndb.Ndb, attr, val string, rattrs ...string) ndb.Record {
+	if *platfm {
+		return db.IpinfoPlatform(attr, val, rattrs...)
+	}
+
+	return db.Ipinfo(attr, val, rattrs...)
+}
+
+// Print tuples on one line. Prints nothing if there are none.
+func printtuples(tuples ndb.Record) {
+	if _, err := tuples.WriteLine(os.Stdout); err != nil {
+		errs.Fatal(err)
+	}
+}
+
+// Print every record in the database, file by file.
+func dump(db *ndb.Ndb, args []string) {
+	if *columns != "" {
+		var records ndb.RecordSet
+		for _, file := range db.Files() {
+			records = append(records, db.FileRecords(file)...)
+		}
+		printcolumns(records)
+		return
+	}
+
+	for i, file := range db.Files() {
+		if i > 0 {
 			fmt.Print("\n")
 		}
 
-	case 3:
-		// only print rattr
-		for _, rec := range records {
+		fmt.Printf("# %s\n", file)
+
+		for _, rec := range db.FileRecords(file) {
+			printrecord(rec)
+		}
+	}
+}
+
+// Print the -columns attributes of each record in aligned columns,
+// under a heading. Several values of one attribute are joined by commas.
+func printcolumns(records ndb.RecordSet) {
+	attrs := strings.Split(*columns, ",")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, strings.Join(attrs, "\t"))
+
+	for _, rec := range records {
+		row := make([]string, len(attrs))
+		for i, attr := range attrs {
+			var vals []string
 			for _, tuple := range rec {
-				if tuple.Attr == flag.Arg(2) {
-					fmt.Printf("%s\n", tuple.Val)
+				if tuple.Attr == attr {
+					vals = append(vals, tuple.Val)
 				}
 			}
+			row[i] = strings.Join(vals, ",")
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+}
+
+// Print a record as ndb text, wrapping long records
+// onto indented continuation lines, unless -wide is set.
+func printrecord(rec ndb.Record) {
+	write := rec.WriteTo
+	if *wide {
+		write = rec.WriteLine
+	}
+
+	if _, err := write(os.Stdout); err != nil {
+		errs.Fatal(err)
+	}
+}
+
+// Print record, tuple and attribute counts, and estimated memory use.
+func stats(db *ndb.Ndb, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+
+	attrs := make(map[string]int)
+	nrec, ntup := 0, 0
+
+	fmt.Fprintf(w, "file\trecords\ttuples\n")
+
+	for _, file := range db.Files() {
+		recs := db.FileRecords(file)
+		tuples := 0
+
+		for _, rec := range recs {
+			for _, tuple := range rec {
+				attrs[tuple.Attr]++
+			}
+			tuples += len(rec)
 		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\n", file, len(recs), tuples)
+
+		nrec += len(recs)
+		ntup += tuples
+	}
+
+	fmt.Fprintf(w, "total\t%d\t%d\n", nrec, ntup)
+
+	names := make([]string, 0, len(attrs))
+	for attr := range attrs {
+		names = append(names, attr)
 	}
 
+	sort.Slice(names, func(i, j int) bool {
+		if attrs[names[i]] != attrs[names[j]] {
+			return attrs[names[i]] > attrs[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Fprintf(w, "\nattribute\tcount\n")
+
+	for _, attr := range names {
+		fmt.Fprintf(w, "%s\t%d\n", attr, attrs[attr])
+	}
+
+	fmt.Fprintf(w, "%d attributes\n", len(names))
+
+	m := db.MemStats()
+
+	fmt.Fprintf(w, "\nmemory\tbytes\n")
+	fmt.Fprintf(w, "records\t%d\n", m.RecordBytes)
+	fmt.Fprintf(w, "strings\t%d\n", m.StringBytes)
+	fmt.Fprintf(w, "indexes\t%d\n", m.IndexBytes)
+	fmt.Fprintf(w, "data\t%d\n", m.DataBytes)
+	fmt.Fprintf(w, "total\t%d\n", m.Total())
 }
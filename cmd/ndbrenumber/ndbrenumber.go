@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"os"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	write   = flag.Bool("w", false, "write the changes to the database files")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-w] from to\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	db, err := ndb.Open(*ndbfile)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	plan, err := db.Renumber(flag.Arg(0), flag.Arg(1))
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	if len(plan.Conflicts) > 0 {
+		for _, d := range plan.Conflicts {
+			if *errfmt == "json" {
+				json.NewEncoder(os.Stderr).Encode(d)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s:%d: %s\n", d.File, d.Line, d.Message)
+			}
+		}
+		os.Exit(1)
+	}
+
+	if !*write {
+		fmt.Print(plan.Patch())
+		return
+	}
+
+	if err := plan.Apply(); err != nil {
+		errs.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "renumbered %d values\n", len(plan.Changes))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"github.com/mischief/ndb/internal/pager"
+	"html/template"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	format  = flag.String("o", "text", "output format: text, json or html")
+	doping  = flag.Bool("ping", false, "ping every host and report those that don't answer")
+	jobs    = flag.Int("j", 32, "hosts to ping at once")
+	timeout = flag.Duration("t", 2*time.Second, "time to wait for each ping")
+	stale   = flag.Int("stale", 0, "list hosts not seen for this many days (see ndbarp -seen)")
+	nopage  = flag.Bool("nopage", false, "don't page text output on a terminal")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+var formats = map[string]func(io.Writer, *report) error{
+	"text": writetext,
+	"json": writejson,
+	"html": writehtml,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] [-o text|json|html] [-ping [-j n] [-t timeout]] [-stale days]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	write, ok := formats[*format]
+
+	if flag.NArg() != 0 || !ok || *jobs < 1 || *stale < 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	db, err := ndb.Open(*ndbfile)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	rep := &report{Report: db.Report(), Switches: db.CheckSwitches()}
+
+	// findings are also errors, for editors and CI wrappers
+	if *errfmt == "json" {
+		enc := json.NewEncoder(os.Stderr)
+		for _, d := range append(rep.Diagnostics(), rep.Switches...) {
+			enc.Encode(d)
+		}
+	}
+
+	if *stale > 0 {
+		rep.Stale = db.Stale(time.Duration(*stale)*24*time.Hour, time.Now())
+		rep.StaleDays = *stale
+	}
+
+	if *doping {
+		if err := annotate(rep, db, *jobs, *timeout); err != nil {
+			errs.Fatal(err)
+		}
+	}
+
+	done := func() {}
+	if *format == "text" && !*nopage {
+		done = pager.Start()
+	}
+
+	err = write(os.Stdout, rep)
+	done()
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+}
+
+func writejson(w io.Writer, rep *report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(rep)
+}
+
+func writetext(w io.Writer, rep *report) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "%d hosts\n\n", rep.Hosts)
+
+	fmt.Fprintf(tw, "subnet\tnetwork\thosts\tfree\n")
+	for _, sn := range rep.Subnets {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", sn.Name, sn.Net, sn.Hosts, freestring(sn.Free))
+	}
+
+	section := func(title string, hosts []ndb.HostRef) {
+		if len(hosts) == 0 {
+			return
+		}
+		fmt.Fprintf(tw, "\n%s:\n", title)
+		for _, h := range hosts {
+			fmt.Fprintf(tw, "  %s=%s\t%s\n", h.Key.Attr, h.Key.Val, h.Pos)
+		}
+	}
+
+	section("missing ether", rep.MissingEther)
+	section("missing dom", rep.MissingDom)
+
+	for _, dup := range rep.Duplicates {
+		section(fmt.Sprintf("duplicate %s=%s", dup.Tuple.Attr, dup.Tuple.Val), dup.Hosts)
+	}
+
+	if len(rep.Switches) > 0 {
+		fmt.Fprintf(tw, "\nswitch problems:\n")
+		for _, d := range rep.Switches {
+			fmt.Fprintf(tw, "  %s\t%s:%d\n", d.Message, d.File, d.Line)
+		}
+	}
+
+	section(fmt.Sprintf("not seen for %d days", rep.StaleDays), rep.Stale)
+
+	if rep.Pinged > 0 {
+		fmt.Fprintf(tw, "\n%d of %d hosts pinged did not answer\n", len(rep.Unreachable), rep.Pinged)
+		section("unreachable", rep.Unreachable)
+	}
+
+	return tw.Flush()
+}
+
+// Format free ranges as a comma separated list.
+func freestring(free []ndb.IPRange) string {
+	s := ""
+
+	for i, r := range free {
+		if i > 0 {
+			s += ","
+		}
+		if r.First.Equal(r.Last) {
+			s += r.First.String()
+		} else {
+			s += r.First.String() + "-" + r.Last.String()
+		}
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{"free": freestring}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>ndb report</title></head>
+<body>
+<h1>ndb report</h1>
+<p>{{.Hosts}} hosts</p>
+<h2>subnets</h2>
+<table>
+<tr><th>subnet</th><th>network</th><th>hosts</th><th>free</th></tr>
+{{range .Subnets}}<tr><td>{{.Name}}</td><td>{{.Net}}</td><td>{{.Hosts}}</td><td>{{free .Free}}</td></tr>
+{{end}}</table>
+{{define "hosts"}}<ul>
+{{range .}}<li>{{.Key.Attr}}={{.Key.Val}} <small>{{.Pos}}</small></li>
+{{end}}</ul>
+{{end}}{{if .MissingEther}}<h2>missing ether</h2>
+{{template "hosts" .MissingEther}}{{end}}{{if .MissingDom}}<h2>missing dom</h2>
+{{template "hosts" .MissingDom}}{{end}}{{range .Duplicates}}<h2>duplicate {{.Tuple.Attr}}={{.Tuple.Val}}</h2>
+{{template "hosts" .Hosts}}{{end}}{{if .Switches}}<h2>switch problems</h2>
+<ul>
+{{range .Switches}}<li>{{.Message}} <small>{{.File}}:{{.Line}}</small></li>
+{{end}}</ul>
+{{end}}{{if .Stale}}<h2>not seen for {{.StaleDays}} days</h2>
+{{template "hosts" .Stale}}{{end}}{{if .Pinged}}<h2>unreachable</h2>
+<p>{{len .Unreachable}} of {{.Pinged}} hosts pinged did not answer</p>
+{{template "hosts" .Unreachable}}{{end}}</body>
+</html>
+`))
+
+func writehtml(w io.Writer, rep *report) error {
+	return page.Execute(w, rep)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"github.com/mischief/ndb"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// A report with optional reachability annotations.
+type report struct {
+	*ndb.Report
+	Unreachable []ndb.HostRef    `json:",omitempty"` // Hosts none of whose addresses answered
+	Pinged      int              `json:",omitempty"` // Hosts pinged
+	Stale       []ndb.HostRef    `json:",omitempty"` // Hosts not seen for StaleDays
+	StaleDays   int              `json:",omitempty"` // Age given to -stale
+	Switches    []ndb.Diagnostic `json:",omitempty"` // Problems with vlan=, switch= and port=
+}
+
+// A host and its addresses.
+type pinghost struct {
+	ref ndb.HostRef
+	ips []string
+}
+
+// Ping every host with a valid ip= concurrently, at most jobs at a
+// time, and record those that did not answer within timeout.
+func annotate(rep *report, db *ndb.Ndb, jobs int, timeout time.Duration) error {
+	// otherwise every host would look dead
+	if _, err := exec.LookPath("ping"); err != nil {
+		return err
+	}
+
+	var hosts []pinghost
+
+	db.Walk(func(rec ndb.Record, pos ndb.Pos) bool {
+		var ips []string
+		isnet := false
+
+		for _, tuple := range rec {
+			switch tuple.Attr {
+			case "ipnet":
+				isnet = true
+			case "ip":
+				// anything else could be taken by ping as an option
+				if ip := net.ParseIP(tuple.Val); ip != nil {
+					ips = append(ips, ip.String())
+				}
+			}
+		}
+
+		if !isnet && len(ips) > 0 {
+			hosts = append(hosts, pinghost{ndb.HostRef{Key: rec.Key(), Pos: pos}, ips})
+		}
+		return true
+	})
+
+	alive := make([]bool, len(hosts))
+	sem := make(chan bool, jobs)
+
+	var wg sync.WaitGroup
+
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h pinghost) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+
+			for _, ip := range h.ips {
+				if ping(ip, timeout) {
+					alive[i] = true
+					return
+				}
+			}
+		}(i, h)
+	}
+
+	wg.Wait()
+
+	rep.Pinged = len(hosts)
+
+	for i, h := range hosts {
+		if !alive[i] {
+			rep.Unreachable = append(rep.Unreachable, h.ref)
+		}
+	}
+
+	return nil
+}
+
+// Send one echo request with the system ping, which unlike a raw
+// ICMP socket needs no privileges. Any failure counts as no answer.
+func ping(ip string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return exec.CommandContext(ctx, "ping", "-c", "1", ip).Run() == nil
+}
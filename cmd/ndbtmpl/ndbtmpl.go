@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"github.com/mischief/ndb/internal/diag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+var (
+	ndbfile = flag.String("f", "", "ndb file (default: first of ndb.DefaultFiles found)")
+	errfmt  = flag.String("e", "text", "error output format: text or json")
+)
+
+// Reports errors in the -e format.
+var errs = &diag.Reporter{Format: errfmt, Status: 1}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f ndbfile] template [attr [val]]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	narg := flag.NArg()
+
+	if narg < 1 || narg > 3 {
+		usage()
+		os.Exit(1)
+	}
+
+	db, err := ndb.Open(*ndbfile)
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	text, err := ioutil.ReadFile(flag.Arg(0))
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	tmpl, err := template.New(filepath.Base(flag.Arg(0))).Funcs(funcs(db)).Parse(string(text))
+
+	if err != nil {
+		errs.Fatal(err)
+	}
+
+	// select records: all of them, or those matching attr[=val]
+	var records ndb.RecordSet
+
+	if narg > 1 {
+		records = db.Search(flag.Arg(1), flag.Arg(2))
+	} else {
+		db.Walk(func(rec ndb.Record, pos ndb.Pos) bool {
+			records = append(records, rec)
+			return true
+		})
+	}
+
+	if err := tmpl.Execute(os.Stdout, records); err != nil {
+		errs.Fatal(err)
+	}
+}
+
+// Template functions for querying the database.
+func funcs(db *ndb.Ndb) template.FuncMap {
+	return template.FuncMap{
+		// records matching attr=val; val "" matches any value
+		"search": db.Search,
+		// attributes for a host, inherited from its networks
+		"ipinfo": db.Ipinfo,
+		// first value of attr in a record
+		"val": func(rec ndb.Record, attr string) string {
+			return rec.Search(attr)
+		},
+		// all values of attr in a record
+		"vals": func(rec ndb.Record, attr string) []string {
+			var vals []string
+			for _, tuple := range rec {
+				if tuple.Attr == attr {
+					vals = append(vals, tuple.Val)
+				}
+			}
+			return vals
+		},
+		// whether the record has attr at all
+		"has": func(rec ndb.Record, attr string) bool {
+			for _, tuple := range rec {
+				if tuple.Attr == attr {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
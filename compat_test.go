@@ -0,0 +1,83 @@
+package ndb
+
+import (
+	"testing"
+)
+
+// Queries against a local file in the form of Plan 9's, chained to
+// the auth file and a reduced common file from the distribution, and
+// the answers native ndb gives for them.
+
+const (
+	testplan9 = "testndb/plan9"
+)
+
+type CompatTest struct {
+	attr, val string
+	rattr     string
+
+	vals []string
+}
+
+var compattests = []CompatTest{
+	// end of line comments
+	CompatTest{"dom", "B.ROOT-SERVERS.NET", "ip", []string{"192.228.79.201"}},
+	CompatTest{"dom", "L.ROOT-SERVERS.NET", "ip", []string{"199.7.83.42"}},
+	CompatTest{"dom", "10.in-addr.arpa", "ns", []string{"ns1.cs.bell-labs.com", "ns2.cs.bell-labs.com"}},
+	CompatTest{"dom", "0.in-addr.arpa", "refresh", []string{"3600"}},
+	CompatTest{"sys", "helix", "auth", []string{""}},
+	CompatTest{"ipnet", "mh-astro-net", "auth", []string{"p9auth.cs.bell-labs.com"}},
+	// quoted values keep spaces and #
+	CompatTest{"sys", "anna", "info", []string{"anna's #1 terminal"}},
+	CompatTest{"sys", "unquoted", "info", []string{"no closing quote"}},
+	CompatTest{"sys", "unquoted", "dom", []string{"unquoted.cs.bell-labs.com"}},
+	// bare attributes, and space before =
+	CompatTest{"sys", "helix", "cpu", []string{""}},
+	CompatTest{"cpu", "", "sys", []string{"helix"}},
+	CompatTest{"sys", "helix", "proto", []string{"il"}},
+	// an empty line ends a record, even before an indented line
+	CompatTest{"sys", "split", "dom", nil},
+	CompatTest{"dom", "split.cs.bell-labs.com", "sys", nil},
+	CompatTest{"dom", "split.cs.bell-labs.com", "dom", []string{"split.cs.bell-labs.com"}},
+	// auth
+	CompatTest{"hostid", "bootes", "uid", []string{"!sys", "!adm", "*"}},
+	// services
+	CompatTest{"tcp", "https", "port", []string{"443"}},
+	CompatTest{"udp", "dns", "port", []string{"53"}},
+	CompatTest{"tcp", "invalid", "port", nil},
+	CompatTest{"sys", "nonexistent", "ip", nil},
+}
+
+func TestPlan9Compat(t *testing.T) {
+	db, err := Open(testplan9)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ct := range compattests {
+		var vals []string
+
+		if recs := db.Search(ct.attr, ct.val); len(recs) > 0 {
+			for _, tuple := range recs[0].find(ct.rattr) {
+				vals = append(vals, tuple.Val)
+			}
+		}
+
+		if len(vals) != len(ct.vals) {
+			t.Errorf("%s=%s %s: expected %q got %q", ct.attr, ct.val, ct.rattr, ct.vals, vals)
+			continue
+		}
+
+		for i := range vals {
+			if vals[i] != ct.vals[i] {
+				t.Errorf("%s=%s %s: expected %q got %q", ct.attr, ct.val, ct.rattr, ct.vals, vals)
+				break
+			}
+		}
+	}
+
+	if ip := db.Ipinfo("sys", "helix", "ipgw", "dns"); len(ip) != 3 || ip[0].Val != "135.104.1.1" {
+		t.Errorf("ipinfo: %v", ip)
+	}
+}
@@ -0,0 +1,130 @@
+package ndb
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reads records one at a time from ndb text, so a database of any
+// size can be processed in constant memory. Unlike Open and Parse, a
+// Decoder keeps nothing but the record it is reading: it doesn't
+// follow database= records, apply options or index the records.
+type Decoder struct {
+	scan   *bufio.Scanner
+	name   string // File name for errors
+	lineno int
+	err    error
+
+	// The record being read, and where it began and its lines break
+	rec     Record
+	recline int
+	brk     []int
+
+	// Where the record last returned began and its lines break
+	line   int
+	breaks []int
+}
+
+// Return a Decoder reading ndb text from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scan := bufio.NewScanner(r)
+	scan.Split(scanlines)
+	return &Decoder{scan: scan}
+}
+
+// Split lines as bufio.ScanLines does, but keep a \r before the
+// newline, so a line holding only \r is white space, as on Plan 9,
+// rather than empty.
+func scanlines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// Return the next record, or io.EOF after the last. A malformed line
+// is a *ParseError, returned after the records before it, including
+// the tuples of its record on the lines above; Next returns the same
+// error from then on. Comments are skipped. As in Plan 9's ndbparse,
+// an empty line ends a record, so a line after it that begins with
+// white space starts a record rather than continuing the one before.
+func (d *Decoder) Next() (Record, error) {
+	for d.err == nil && d.scan.Scan() {
+		line := d.scan.Text()
+		d.lineno++
+
+		// an empty line ends the record
+		if line == "" {
+			if done := d.finish(); done != nil {
+				return done, nil
+			}
+			continue
+		}
+
+		first, _ := utf8.DecodeRuneInString(line)
+
+		// comment, skip
+		if first == '#' {
+			continue
+		}
+
+		// not whitespace, begin a record
+		var done Record
+		if !unicode.IsSpace(first) {
+			done = d.finish()
+		}
+
+		if tuples, err := parsetuples(line); err != nil {
+			perr := err.(*ParseError)
+			perr.File = d.name
+			perr.Line = d.lineno
+			d.err = perr
+		} else if len(tuples) > 0 {
+			if len(d.rec) == 0 {
+				d.recline = d.lineno
+			}
+			d.brk = append(d.brk, len(d.rec))
+			d.rec = append(d.rec, tuples...)
+		}
+
+		if done != nil {
+			return done, nil
+		}
+	}
+
+	if d.err == nil {
+		d.err = io.EOF
+		if err := d.scan.Err(); err != nil {
+			d.err = &ParseError{File: d.name, Line: d.lineno + 1, Err: err}
+		}
+	}
+
+	// make sure to get the last record.
+	if rec := d.finish(); rec != nil {
+		return rec, nil
+	}
+
+	return nil, d.err
+}
+
+// Return the record being read, if it has any tuples, and start
+// another.
+func (d *Decoder) finish() Record {
+	rec, brk := d.rec, d.brk
+	d.rec, d.brk = nil, nil
+
+	if len(rec) == 0 {
+		return nil
+	}
+
+	d.line, d.breaks = d.recline, brk
+	return rec
+}
@@ -0,0 +1,94 @@
+package ndb
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	db, err := Open(testndb, Single())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := db.FileRecords(testndb)
+
+	d := NewDecoder(f)
+	for i := 0; ; i++ {
+		rec, err := d.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("got %d records, want %d", i, len(want))
+			}
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if i >= len(want) || rec.String() != want[i].String() {
+			t.Errorf("record %d: got %s", i, rec)
+		}
+	}
+
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("after EOF: %v", err)
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	d := NewDecoder(strings.NewReader("sys=a\n# comment\n\nsys=b ip=1\n\t=x\nsys=c\n"))
+
+	var got []string
+	var err error
+	for err == nil {
+		var rec Record
+		if rec, err = d.Next(); err == nil {
+			got = append(got, rec.String())
+		}
+	}
+
+	if strings.Join(got, "|") != "sys=a|sys=b ip=1" {
+		t.Errorf("got records %q", got)
+	}
+
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 5 || perr.Err != ErrNoAttr {
+		t.Errorf("got error %v", err)
+	}
+
+	if _, again := d.Next(); again != err {
+		t.Errorf("got %v after error", again)
+	}
+}
+
+func TestDecoderEmptyLine(t *testing.T) {
+	// as in Plan 9, an empty line ends a record, but a line of white
+	// space, even a lone \r, doesn't
+	d := NewDecoder(strings.NewReader("sys=a\n\n\tip=1\n\t# note\nsys=b\r\n\r\n\tip=2\r\n  \n\tdom=b\n"))
+
+	want := []string{"sys=a", "ip=1", "sys=b ip=2 dom=b"}
+	lines := []int{1, 3, 5}
+
+	for i := 0; ; i++ {
+		rec, err := d.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("got %d records, want %d", i, len(want))
+			}
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if i >= len(want) || rec.String() != want[i] || d.line != lines[i] {
+			t.Errorf("record %d: got %s at line %d", i, rec, d.line)
+		}
+	}
+}
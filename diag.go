@@ -0,0 +1,73 @@
+package ndb
+
+import (
+	"fmt"
+)
+
+// Diagnostic severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// A problem with a database, in a form editors and other tools can
+// use to point at the right place. File and Line are empty when
+// unknown.
+type Diagnostic struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Describe err as an error diagnostic, with the file and line
+// taken from errors that carry them.
+func ErrorDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Message: err.Error()}
+
+	switch e := err.(type) {
+	case *PartialWriteError:
+		d.File = e.File
+	case *ParseError:
+		d.File = e.File
+		d.Line = e.Line
+	}
+
+	return d
+}
+
+// Return problems Open found with the database that didn't stop it
+// opening: a file listed twice by the database= record, which is used
+// only once so its records aren't searched twice, and database=
+// records in the other files. Only the first file's database= record
+// is followed, as in Plan 9, so files including each other can't make
+// a loop.
+func (n *Ndb) Warnings() []Diagnostic {
+	return append([]Diagnostic(nil), n.warnings...)
+}
+
+// Return the report's findings as warnings, one for each host
+// involved, in the order they appear in the report.
+func (r *Report) Diagnostics() []Diagnostic {
+	var diags []Diagnostic
+
+	warn := func(ref HostRef, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{ref.Pos.File, ref.Pos.Line, SeverityWarning, fmt.Sprintf(format, args...)})
+	}
+
+	for _, ref := range r.MissingEther {
+		warn(ref, "host %s=%s has no ether address", ref.Key.Attr, ref.Key.Val)
+	}
+
+	for _, ref := range r.MissingDom {
+		warn(ref, "host %s=%s has no domain name", ref.Key.Attr, ref.Key.Val)
+	}
+
+	for _, dup := range r.Duplicates {
+		for _, ref := range dup.Hosts {
+			warn(ref, "%s=%s is used by %d hosts", dup.Tuple.Attr, dup.Tuple.Val, len(dup.Hosts))
+		}
+	}
+
+	return diags
+}
@@ -0,0 +1,139 @@
+package ndb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorDiagnostic(t *testing.T) {
+	d := ErrorDiagnostic(&PartialWriteError{"testndb/local", PartialRetry})
+
+	if d.File != "testndb/local" || d.Severity != SeverityError || d.Message == "" {
+		t.Errorf("bad diagnostic: %+v", d)
+	}
+
+	d = ErrorDiagnostic(errors.New("boom"))
+
+	b, err := json.Marshal(d)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"severity":"error","message":"boom"}`; string(b) != want {
+		t.Errorf("expected %s got %s", want, b)
+	}
+}
+
+func TestReportDiagnostics(t *testing.T) {
+	db, err := Open("testndb/report")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := db.Report().Diagnostics()
+
+	// one missing ether, one missing dom, two duplicates of two hosts each
+	if len(diags) != 6 {
+		t.Fatalf("expected 6 diagnostics, got %+v", diags)
+	}
+
+	if d := diags[1]; d.File != "testndb/report" || d.Line != 6 || d.Severity != SeverityWarning {
+		t.Errorf("wrong missing dom diagnostic: %+v", d)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	if err := ioutil.WriteFile(a, []byte("# a\ndatabase=\n\tfile="+a+"\n\tfile="+b+"\n\tfile="+b+"\nsys=a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("database= file="+a+"\nsys=b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := db.Files(); len(files) != 2 {
+		t.Errorf("got files %q", files)
+	}
+
+	if recs := db.Search("sys", "b"); len(recs) != 1 {
+		t.Errorf("got %d sys=b records", len(recs))
+	}
+
+	warns := db.Warnings()
+	if len(warns) != 2 {
+		t.Fatalf("got warnings %+v", warns)
+	}
+
+	if w := warns[0]; w.File != b || w.Line != 1 || !strings.Contains(w.Message, "ignored") {
+		t.Errorf("bad warning %+v", w)
+	}
+
+	if w := warns[1]; w.File != a || w.Line != 2 || w.Severity != SeverityWarning || !strings.Contains(w.Message, "file="+b) {
+		t.Errorf("bad warning %+v", w)
+	}
+
+	if db, err := Open(testndb); err != nil || db.Warnings() != nil {
+		t.Errorf("got warnings %+v, %v", db.Warnings(), err)
+	}
+}
+
+func TestWarningsSameFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+
+	if err := os.Symlink("b", c); err != nil {
+		t.Skip(err)
+	}
+
+	if err := ioutil.WriteFile(a, []byte("database=\n\tfile="+b+"\n\tfile="+c+"\n\tfile="+dir+"/./a\nsys=a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("sys=b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := db.Files(); len(files) != 2 || files[0] != b || files[1] != a {
+		t.Errorf("got files %q", files)
+	}
+
+	if recs := db.Search("sys", ""); len(recs) != 2 {
+		t.Errorf("got records %v", recs)
+	}
+
+	if warns := db.Warnings(); len(warns) != 1 || !strings.Contains(warns[0].Message, "file="+c+" is the same file as file="+b) {
+		t.Errorf("got warnings %+v", warns)
+	}
+
+	if err := db.Cat(c); err != nil || len(db.Files()) != 2 {
+		t.Errorf("Cat added the same file again: %q, %v", db.Files(), err)
+	}
+}
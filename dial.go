@@ -0,0 +1,72 @@
+package ndb
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Network used when a dial string does not name one.
+	DefaultNet = "net"
+)
+
+// A Plan 9 network address of the form net!host!service.
+// Service may be empty.
+type DialString struct {
+	Net, Host, Service string
+}
+
+// Parse a Plan 9 dial string such as "tcp!example.com!http".
+//
+// A dial string with no '!' is taken to be a bare host, and gets the
+// network defnet (or DefaultNet if defnet is "").
+// A dial string without a service gets the service defsrv, which may be "".
+func ParseDialString(s, defnet, defsrv string) (DialString, error) {
+	var ds DialString
+
+	if defnet == "" {
+		defnet = DefaultNet
+	}
+
+	fields := strings.Split(s, "!")
+
+	switch len(fields) {
+	case 1:
+		ds = DialString{defnet, fields[0], defsrv}
+	case 2:
+		ds = DialString{fields[0], fields[1], defsrv}
+	case 3:
+		ds = DialString{fields[0], fields[1], fields[2]}
+	default:
+		return ds, fmt.Errorf("dial string %q: too many fields", s)
+	}
+
+	if ds.Net == "" {
+		return ds, fmt.Errorf("dial string %q: empty network", s)
+	}
+
+	if ds.Host == "" {
+		return ds, fmt.Errorf("dial string %q: empty host", s)
+	}
+
+	return ds, nil
+}
+
+// Build a dial string from its parts.
+// An empty net becomes DefaultNet, and an empty service is omitted.
+func MakeDialString(net, host, service string) string {
+	if net == "" {
+		net = DefaultNet
+	}
+
+	if service == "" {
+		return net + "!" + host
+	}
+
+	return net + "!" + host + "!" + service
+}
+
+// Return the dial string in net!host!service form.
+func (d DialString) String() string {
+	return MakeDialString(d.Net, d.Host, d.Service)
+}
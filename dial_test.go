@@ -0,0 +1,65 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type DialParseTest struct {
+	in             string
+	defnet, defsrv string
+
+	out DialString
+	str string
+	err bool
+}
+
+var (
+	dialtests = []DialParseTest{
+		DialParseTest{in: "tcp!example.com!http", out: DialString{"tcp", "example.com", "http"}, str: "tcp!example.com!http"},
+		DialParseTest{in: "example.com", out: DialString{"net", "example.com", ""}, str: "net!example.com"},
+		DialParseTest{in: "example.com", defnet: "udp", defsrv: "dns", out: DialString{"udp", "example.com", "dns"}, str: "udp!example.com!dns"},
+		DialParseTest{in: "tcp!example.com", defsrv: "564", out: DialString{"tcp", "example.com", "564"}, str: "tcp!example.com!564"},
+		DialParseTest{in: "tcp!example.com", out: DialString{"tcp", "example.com", ""}, str: "tcp!example.com"},
+		DialParseTest{in: "tcp!*!564", out: DialString{"tcp", "*", "564"}, str: "tcp!*!564"},
+		DialParseTest{in: "", err: true},
+		DialParseTest{in: "!example.com!http", err: true},
+		DialParseTest{in: "tcp!!http", err: true},
+		DialParseTest{in: "tcp!a!b!c", err: true},
+	}
+)
+
+func TestParseDialString(t *testing.T) {
+	for tno, test := range dialtests {
+		ds, err := ParseDialString(test.in, test.defnet, test.defsrv)
+
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: expected error for %q, got %+v", tno, test.in, ds)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: %s", tno, err)
+			continue
+		}
+
+		if ds != test.out {
+			t.Errorf("test %d: expected %+v got %+v", tno, test.out, ds)
+		}
+
+		if ds.String() != test.str {
+			t.Errorf("test %d: expected %q got %q", tno, test.str, ds.String())
+		}
+	}
+}
+
+func TestMakeDialString(t *testing.T) {
+	if s := MakeDialString("", "example.com", "http"); s != "net!example.com!http" {
+		t.Errorf("expected %q got %q", "net!example.com!http", s)
+	}
+
+	if s := MakeDialString("tcp", "example.com", ""); s != "tcp!example.com" {
+		t.Errorf("expected %q got %q", "tcp!example.com", s)
+	}
+}
@@ -0,0 +1,107 @@
+package ndb
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Return a unified diff, with three lines of context, from old to new,
+// the lines of the file name split at newlines, or "" if they are the
+// same. Lines are compared position by position, for edits within
+// lines; if lines were added or removed, the whole file is shown as
+// replaced.
+func linediff(name string, old, new []string) string {
+	var buf bytes.Buffer
+
+	const context = 3
+
+	if len(old) != len(new) {
+		fmt.Fprintf(&buf, "--- %s\n+++ %s\n@@ -1,%d +1,%d @@\n", name, name, len(trimeol(old)), len(trimeol(new)))
+		for _, line := range trimeol(old) {
+			fmt.Fprintf(&buf, "-%s\n", line)
+		}
+		for _, line := range trimeol(new) {
+			fmt.Fprintf(&buf, "+%s\n", line)
+		}
+		return buf.String()
+	}
+
+	lines := old
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	noeol := len(lines) == len(old)
+
+	var changed []int
+	for i := range lines {
+		if old[i] != new[i] {
+			changed = append(changed, i)
+		}
+	}
+
+	if len(changed) == 0 {
+		return ""
+	}
+
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", name, name)
+
+	for len(changed) > 0 {
+		// gather changes close enough to share a hunk
+		last := 1
+		for last < len(changed) && changed[last]-changed[last-1] <= 2*context {
+			last++
+		}
+
+		start, end := changed[0]-context, changed[last-1]+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+
+		for i := start; i < end; {
+			if old[i] == new[i] {
+				fmt.Fprintf(&buf, " %s\n", old[i])
+				if noeol && i == len(lines)-1 {
+					buf.WriteString("\\ No newline at end of file\n")
+				}
+				i++
+				continue
+			}
+
+			j := i
+			for j < end && old[j] != new[j] {
+				j++
+			}
+
+			for _, side := range []struct {
+				mark  string
+				lines []string
+			}{{"-", old}, {"+", new}} {
+				for k := i; k < j; k++ {
+					fmt.Fprintf(&buf, "%s%s\n", side.mark, side.lines[k])
+					if noeol && k == len(lines)-1 {
+						buf.WriteString("\\ No newline at end of file\n")
+					}
+				}
+			}
+
+			i = j
+		}
+
+		changed = changed[last:]
+	}
+
+	return buf.String()
+}
+
+// Drop the empty string split from after a final newline.
+func trimeol(lines []string) []string {
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,268 @@
+package ndb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Differential tests: random databases are written out as text, opened,
+// and queried, and the answers compared with those of a plain reference
+// working from the records the generator meant to write. Failures print
+// the seed and database so they can be reproduced.
+
+var (
+	diffattrs = []string{"sys", "dom", "ether", "fs", "ipgw", "dns", "info", "auth"}
+	diffvals  = []string{"", "a", "b", "c", "fir", "x y", "#1", "a=b", "10.0.0.1"}
+)
+
+// Generate count random records, some of them ipnet records.
+func diffrecords(r *rand.Rand, count int) RecordSet {
+	var recs RecordSet
+
+	for i := 0; i < count; i++ {
+		var rec Record
+
+		if r.Intn(4) == 0 {
+			bits := []int{8, 16, 24}[r.Intn(3)]
+			ip := uint32(10)<<24 | uint32(r.Intn(3))<<16 | uint32(r.Intn(3))<<8
+			mask := ^uint32(0) << uint(32-bits)
+			rec = append(rec, Tuple{"ipnet", fmt.Sprintf("net%d", i)},
+				Tuple{"ip", ipstring(ip & mask)}, Tuple{"ipmask", ipstring(mask)})
+		} else if r.Intn(3) > 0 {
+			ip := uint32(10)<<24 | uint32(r.Intn(3))<<16 | uint32(r.Intn(3))<<8 | uint32(1+r.Intn(3))
+			rec = append(rec, Tuple{"ip", ipstring(ip)})
+		}
+
+		for n := r.Intn(6); n >= 0; n-- {
+			rec = append(rec, Tuple{diffattrs[r.Intn(len(diffattrs))], diffvals[r.Intn(len(diffvals))]})
+		}
+
+		r.Shuffle(len(rec), func(i, j int) { rec[i], rec[j] = rec[j], rec[i] })
+
+		recs = append(recs, rec)
+	}
+
+	return recs
+}
+
+func ipstring(a uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", a>>24, a>>16&0xff, a>>8&0xff, a&0xff)
+}
+
+func parseip4(s string) (uint32, bool) {
+	var a, b, c, d uint32
+	if n, err := fmt.Sscanf(s, "%d.%d.%d.%d", &a, &b, &c, &d); n != 4 || err != nil {
+		return 0, false
+	}
+	return a<<24 | b<<16 | c<<8 | d, true
+}
+
+// Write records as ndb text, varying the layout: continuation lines,
+// comments, bare attributes and spacing.
+func difftext(r *rand.Rand, recs RecordSet) string {
+	var buf bytes.Buffer
+
+	for _, rec := range recs {
+		if r.Intn(4) == 0 {
+			buf.WriteString("# a comment\n")
+		}
+
+		for i, tuple := range rec {
+			switch {
+			case i == 0:
+			case r.Intn(3) == 0:
+				buf.WriteString("\n\t")
+			default:
+				buf.WriteString([]string{" ", "\t", "  "}[r.Intn(3)])
+			}
+
+			switch {
+			case tuple.Val == "" && r.Intn(2) == 0:
+				buf.WriteString(tuple.Attr)
+			case strings.ContainsAny(tuple.Val, " #") || r.Intn(5) == 0:
+				buf.WriteString(tuple.Attr + `="` + tuple.Val + `"`)
+			default:
+				buf.WriteString(tuple.Attr + "=" + tuple.Val)
+			}
+		}
+
+		if r.Intn(4) == 0 {
+			buf.WriteString("\t# end of line")
+		}
+
+		buf.WriteString("\n")
+
+		if r.Intn(2) == 0 {
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
+
+// Reference search: every record with a matching tuple, once each.
+func refsearch(recs RecordSet, attr, val string) RecordSet {
+	var out RecordSet
+
+	for _, rec := range recs {
+		for _, tuple := range rec {
+			if tuple.Attr == attr && (val == "" || tuple.Val == val) {
+				out = append(out, rec)
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+// Reference ipinfo for the IPv4 databases generated here.
+func refipinfo(recs RecordSet, attr, val string, rattrs []string) Record {
+	var entry Record
+	if found := refsearch(recs, attr, val); len(found) > 0 {
+		entry = found[0]
+	}
+
+	ip, haveip := uint32(0), false
+	if attr == "ip" {
+		ip, haveip = parseip4(val)
+	}
+	for i := 0; !haveip && i < len(entry); i++ {
+		if entry[i].Attr == "ip" {
+			ip, haveip = parseip4(entry[i].Val)
+		}
+	}
+
+	if entry == nil && !haveip {
+		return nil
+	}
+
+	type refnet struct {
+		ones int
+		rec  Record
+	}
+	var nets []refnet
+
+	for _, rec := range recs {
+		if refsearch(RecordSet{rec}, "ipnet", "") == nil {
+			continue
+		}
+		netip, ok1 := parseip4(rec.Search("ip"))
+		mask, ok2 := parseip4(rec.Search("ipmask"))
+		if haveip && ok1 && ok2 && ip&mask == netip&mask {
+			ones := 0
+			for m := mask; m != 0; m <<= 1 {
+				ones++
+			}
+			nets = append(nets, refnet{ones, rec})
+		}
+	}
+
+	sort.SliceStable(nets, func(i, j int) bool { return nets[i].ones > nets[j].ones })
+
+	var result Record
+
+	for _, rattr := range rattrs {
+		from := []Record{entry}
+		for _, n := range nets {
+			from = append(from, n.rec)
+		}
+
+		for _, rec := range from {
+			var found []Tuple
+			for _, tuple := range rec {
+				if tuple.Attr == rattr {
+					found = append(found, tuple)
+				}
+			}
+			if found != nil {
+				result = append(result, found...)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func sameset(a, b RecordSet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func TestDifferential(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	attrs := append([]string{"ip", "ipnet", "ipmask"}, diffattrs...)
+
+	for seed := int64(1); seed <= 200; seed++ {
+		r := rand.New(rand.NewSource(seed))
+
+		recs := diffrecords(r, 1+r.Intn(20))
+		text := difftext(r, recs)
+
+		if err := ioutil.WriteFile(fname, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := Open(fname)
+
+		if err != nil {
+			t.Fatalf("seed %d: %v\n%s", seed, err, text)
+		}
+
+		if got := db.FileRecords(fname); !sameset(got, recs) {
+			t.Fatalf("seed %d: parsed\n%v\nexpected\n%v\nfrom\n%s", seed, got, recs, text)
+		}
+
+		for q := 0; q < 20; q++ {
+			attr := attrs[r.Intn(len(attrs))]
+			val := diffvals[r.Intn(len(diffvals))]
+
+			if attr == "ip" && r.Intn(2) == 0 {
+				val = ipstring(uint32(10)<<24 | uint32(r.Intn(3))<<16 | uint32(r.Intn(3))<<8 | uint32(1+r.Intn(3)))
+			}
+
+			if got, want := db.Search(attr, val), refsearch(recs, attr, val); !sameset(got, want) {
+				t.Fatalf("seed %d: search %s=%s: got %v expected %v\n%s", seed, attr, val, got, want, text)
+			}
+
+			rattrs := []string{diffattrs[r.Intn(len(diffattrs))], diffattrs[r.Intn(len(diffattrs))]}
+
+			got := db.Ipinfo(attr, val, rattrs...)
+			want := refipinfo(recs, attr, val, rattrs)
+
+			if !sameset(RecordSet{got}, RecordSet{want}) {
+				t.Fatalf("seed %d: ipinfo %s=%s %q: got %v expected %v\n%s", seed, attr, val, rattrs, got, want, text)
+			}
+		}
+	}
+}
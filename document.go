@@ -0,0 +1,408 @@
+package ndb
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// A database file as lines of text, for programs that edit ndb files
+// without losing comments, blank lines and layout. Written back, a
+// File is the same byte for byte as the text it was parsed from,
+// except for the edits.
+type File struct {
+	Lines []*Line
+	eol   bool // Whether the text ended with a newline
+}
+
+// One line of a File. Edits rewrite Text in place, leaving the rest
+// of the line as it is.
+type Line struct {
+	Text string
+}
+
+// A record in a File: the indexes of its lines in File.Lines, which
+// change when lines are added or removed before it.
+type FileRecord struct {
+	File  *File
+	Lines []int
+}
+
+// Parse ndb text into a File. Returns a *ParseError if a tuple is
+// malformed, as Open does.
+func ParseFile(r io.Reader) (*File, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	if len(data) == 0 {
+		return f, nil
+	}
+
+	text := string(data)
+	if strings.HasSuffix(text, "\n") {
+		f.eol = true
+		text = text[:len(text)-1]
+	}
+
+	for i, s := range strings.Split(text, "\n") {
+		if _, err := parsetuples(s); err != nil {
+			err.(*ParseError).Line = i + 1
+			return nil, err
+		}
+		f.Lines = append(f.Lines, &Line{s})
+	}
+
+	return f, nil
+}
+
+// Return the text of the file.
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+
+	for i, l := range f.Lines {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l.Text)
+	}
+
+	if f.eol && len(f.Lines) > 0 {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// Write the text of the file to w.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(f.Bytes())
+	return int64(n), err
+}
+
+// Lay the file out canonically, as ndbfmt does: a record's lines after
+// the first are indented with one tab, tuples are separated by one
+// space and quoted only where needed, comments within a record are
+// indented like its lines, and trailing white space is removed, which
+// empties blank lines, except that one between lines of a record is
+// left as a tab, since an empty line would end the record. Lines are
+// rewritten in place, never added or removed. A line with a tuple that
+// can't be written, such as a value holding both an = and a quote, is
+// only reindented and trimmed.
+func (f *File) Format() {
+	for i, l := range f.Lines {
+		empty := l.Text == ""
+		l.format()
+		if !empty && l.Text == "" && f.within(i) {
+			l.Text = "\t"
+		}
+	}
+}
+
+// Whether line i is between lines with tuples of one record.
+func (f *File) within(i int) bool {
+	before, after := false, false
+
+	for j := i - 1; j >= 0 && !before; j-- {
+		text := f.Lines[j].Text
+		if text == "" {
+			return false
+		}
+		if text[0] != '#' {
+			tuples, _ := parseline(text)
+			before = len(tuples) > 0
+		}
+	}
+
+	for j := i + 1; j < len(f.Lines) && !after; j++ {
+		text := f.Lines[j].Text
+		if text == "" || !iswhite(text[0]) && text[0] != '#' {
+			return false
+		}
+		if text[0] != '#' {
+			tuples, _ := parseline(text)
+			after = len(tuples) > 0
+		}
+	}
+
+	return before && after
+}
+
+// Return the changes between the text orig and the file as a unified
+// diff of the file name, or "" if there are none. Lines are compared
+// in place, as suits edits such as Format's.
+func (f *File) Diff(name string, orig []byte) string {
+	return linediff(name, strings.Split(string(orig), "\n"), strings.Split(string(f.Bytes()), "\n"))
+}
+
+// Lay the line out as Format does.
+func (l *Line) format() {
+	text := strings.TrimRight(l.Text, " \t\r")
+
+	indent := ""
+	if text != "" && iswhite(text[0]) {
+		indent = "\t"
+		text = strings.TrimLeft(text, " \t\r")
+	}
+
+	trimmed := &Line{text}
+	tuples, comment := trimmed.Tuples(), trimmed.Comment()
+
+	var parts []string
+	for _, tuple := range tuples {
+		if checktuple(tuple) != nil {
+			l.Text = indent + text
+			return
+		}
+		parts = append(parts, formattuple(tuple))
+	}
+
+	if comment != "" {
+		parts = append(parts, comment)
+	}
+
+	if len(parts) == 0 {
+		l.Text = ""
+		return
+	}
+
+	l.Text = indent + strings.Join(parts, " ")
+}
+
+// Return the records in the file, in order.
+func (f *File) Records() []FileRecord {
+	var lines []string
+	for _, l := range f.Lines {
+		lines = append(lines, l.Text)
+	}
+
+	var recs []FileRecord
+	for _, idx := range splitrecords(lines) {
+		recs = append(recs, FileRecord{f, idx})
+	}
+
+	return recs
+}
+
+// Return the records with a tuple attr=val, or with any attr tuple
+// if val is "".
+func (f *File) Find(attr, val string) []FileRecord {
+	var found []FileRecord
+
+	for _, r := range f.Records() {
+		for _, tuple := range r.Record() {
+			if tuple.Attr == attr && (val == "" || tuple.Val == val) {
+				found = append(found, r)
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+// Add rec to the end of the file on one line, after a blank line if
+// the file doesn't end with one. Returns an error, leaving the file
+// alone, if a tuple can't be written.
+func (f *File) Append(rec Record) error {
+	if err := checkrecord(rec); err != nil {
+		return err
+	}
+
+	if n := len(f.Lines); n > 0 && strings.TrimSpace(f.Lines[n-1].Text) != "" {
+		f.Lines = append(f.Lines, &Line{""})
+	}
+
+	f.Lines = append(f.Lines, &Line{strings.TrimSuffix(formatrecord(rec), "\n")})
+	f.eol = true
+	return nil
+}
+
+// Remove the record's lines from the file. Comments and blank lines
+// between the record's lines go with it.
+func (f *File) Delete(r FileRecord) {
+	if len(r.Lines) == 0 {
+		return
+	}
+
+	first, last := r.Lines[0], r.Lines[len(r.Lines)-1]
+	f.Lines = append(f.Lines[:first], f.Lines[last+1:]...)
+}
+
+// Replace the record's lines with rec, written as Record.WriteTo does.
+// Returns an error, leaving the file alone, if a tuple can't be
+// written.
+func (f *File) Replace(r FileRecord, rec Record) error {
+	var buf bytes.Buffer
+	if _, err := rec.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	var lines []*Line
+	for _, s := range bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n")) {
+		lines = append(lines, &Line{string(s)})
+	}
+
+	first, last := r.Lines[0], r.Lines[len(r.Lines)-1]
+	lines = append(lines, f.Lines[last+1:]...)
+	f.Lines = append(f.Lines[:first], lines...)
+	return nil
+}
+
+// Return the record's tuples.
+func (r FileRecord) Record() Record {
+	var rec Record
+
+	for _, i := range r.Lines {
+		rec = append(rec, r.File.Lines[i].Tuples()...)
+	}
+
+	return rec
+}
+
+// Set the value of the record's first attr tuple to val, or add
+// attr=val to the end of its last line if it has none. Returns an
+// error, changing nothing, if attr=val can't be written.
+func (r FileRecord) Set(attr, val string) error {
+	for _, i := range r.Lines {
+		if ok, err := r.File.Lines[i].Set(attr, val); ok || err != nil {
+			return err
+		}
+	}
+
+	return r.File.Lines[r.Lines[len(r.Lines)-1]].Add(attr, val)
+}
+
+// Remove the record's first attr tuple, returning false if it has
+// none. A line left with no tuples is removed unless it has a
+// comment, and the next line starts the record if that was the first.
+func (r FileRecord) Remove(attr string) bool {
+	for k, i := range r.Lines {
+		l := r.File.Lines[i]
+		if !l.Remove(attr) {
+			continue
+		}
+
+		if len(l.Tuples()) > 0 {
+			return true
+		}
+
+		if k == 0 && len(r.Lines) > 1 {
+			next := r.File.Lines[r.Lines[1]]
+			next.Text = strings.TrimLeft(next.Text, " \t")
+		}
+
+		if l.Comment() == "" {
+			r.File.Lines = append(r.File.Lines[:i], r.File.Lines[i+1:]...)
+		}
+		return true
+	}
+
+	return false
+}
+
+// Return the line's tuples.
+func (l *Line) Tuples() []Tuple {
+	tuples, _ := parseline(l.Text)
+	return tuples
+}
+
+// Return the line's comment, from the # to the end of the line,
+// or "" if it has none.
+func (l *Line) Comment() string {
+	_, spans, _ := parsespans(l.Text)
+
+	rest := l.Text
+	if len(spans) > 0 {
+		rest = rest[spans[len(spans)-1][1]:]
+	}
+
+	rest = strings.TrimLeft(rest, " \t\r")
+	if strings.HasPrefix(rest, "#") {
+		return rest
+	}
+
+	return ""
+}
+
+// Whether the line continues the record of the lines before it, by
+// beginning with white space.
+func (l *Line) Continues() bool {
+	return l.Text != "" && iswhite(l.Text[0])
+}
+
+// Set the value of the line's first attr tuple to val, quoting it if
+// needed. Returns false if the line has no attr tuple, and an error,
+// changing nothing, if attr=val can't be written.
+func (l *Line) Set(attr, val string) (bool, error) {
+	if err := checktuple(Tuple{attr, val}); err != nil {
+		return false, err
+	}
+
+	tuples, spans, _ := parsespans(l.Text)
+
+	for i, tuple := range tuples {
+		if tuple.Attr == attr {
+			l.Text = l.Text[:spans[i][0]] + formattuple(Tuple{attr, val}) + l.Text[spans[i][1]:]
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Add attr=val after the line's last tuple, before any comment.
+// Returns an error, changing nothing, if it can't be written.
+func (l *Line) Add(attr, val string) error {
+	if err := checktuple(Tuple{attr, val}); err != nil {
+		return err
+	}
+
+	tuples, spans, _ := parsespans(l.Text)
+	tuple := formattuple(Tuple{attr, val})
+
+	if len(tuples) == 0 {
+		indent := len(l.Text) - len(strings.TrimLeft(l.Text, " \t"))
+		rest := l.Text[indent:]
+		if rest != "" {
+			tuple += " "
+		}
+		l.Text = l.Text[:indent] + tuple + rest
+		return nil
+	}
+
+	end := spans[len(spans)-1][1]
+	l.Text = l.Text[:end] + " " + tuple + l.Text[end:]
+	return nil
+}
+
+// Remove the line's first attr tuple and the space separating it from
+// its neighbour. Returns false if the line has no attr tuple.
+func (l *Line) Remove(attr string) bool {
+	tuples, spans, _ := parsespans(l.Text)
+
+	for i, tuple := range tuples {
+		if tuple.Attr != attr {
+			continue
+		}
+
+		start, end := spans[i][0], spans[i][1]
+
+		if i > 0 {
+			// take the space before, keeping the indent
+			start = spans[i-1][1]
+		} else {
+			for end < len(l.Text) && (l.Text[end] == ' ' || l.Text[end] == '\t') {
+				end++
+			}
+		}
+
+		l.Text = l.Text[:start] + l.Text[end:]
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,171 @@
+package ndb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("testndb/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files = append(files, "")
+
+	for _, fname := range files {
+		var data []byte
+		if fname != "" {
+			if data, err = ioutil.ReadFile(fname); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, text := range [][]byte{data, bytes.TrimSuffix(data, []byte("\n"))} {
+			f, err := ParseFile(bytes.NewReader(text))
+			if err != nil {
+				t.Errorf("%s: %s", fname, err)
+				continue
+			}
+
+			if out := f.Bytes(); !bytes.Equal(out, text) {
+				t.Errorf("%s: round trip changed the text", fname)
+			}
+		}
+	}
+}
+
+type FileEditTest struct {
+	in   string
+	edit func(f *File)
+	out  string
+}
+
+var fileedittests = []FileEditTest{
+	FileEditTest{
+		"# hosts\nsys=a   ip=10.0.0.1	# gateway\n\tdom=a.example.com\n\nsys=b ip=10.0.0.2\n",
+		func(f *File) { f.Find("sys", "a")[0].Set("ip", "10.0.0.9") },
+		"# hosts\nsys=a   ip=10.0.0.9	# gateway\n\tdom=a.example.com\n\nsys=b ip=10.0.0.2\n",
+	},
+	FileEditTest{
+		"sys=a ip=10.0.0.1 # gateway\n\tdom=a.example.com\n",
+		func(f *File) { f.Find("sys", "a")[0].Set("ether", "0011") },
+		"sys=a ip=10.0.0.1 # gateway\n\tdom=a.example.com ether=0011\n",
+	},
+	FileEditTest{
+		"sys=a ip=10.0.0.1 # gateway\n",
+		func(f *File) { f.Find("sys", "a")[0].Set("info", "two words") },
+		"sys=a ip=10.0.0.1 info=\"two words\" # gateway\n",
+	},
+	FileEditTest{
+		"sys=a ip=10.0.0.1\n\tdom=a.example.com   # name\n",
+		func(f *File) { f.Find("sys", "a")[0].Remove("ip") },
+		"sys=a\n\tdom=a.example.com   # name\n",
+	},
+	FileEditTest{
+		"sys=a\n\tip=10.0.0.1\n\tdom=a.example.com\n",
+		func(f *File) { f.Find("sys", "a")[0].Remove("ip") },
+		"sys=a\n\tdom=a.example.com\n",
+	},
+	FileEditTest{
+		"sys=b\n\nsys=a\n\tip=10.0.0.1\n",
+		func(f *File) { f.Find("sys", "a")[0].Remove("sys") },
+		"sys=b\n\nip=10.0.0.1\n",
+	},
+	FileEditTest{
+		"sys=a\n# keep\nsys=b\n\tip=10.0.0.2 # b\nsys=c\n",
+		func(f *File) { f.Delete(f.Find("sys", "b")[0]) },
+		"sys=a\n# keep\nsys=c\n",
+	},
+	FileEditTest{
+		"sys=a # last",
+		func(f *File) { f.Append(Record{{"sys", "b"}, {"ip", "10.0.0.2"}}) },
+		"sys=a # last\n\nsys=b ip=10.0.0.2\n",
+	},
+	FileEditTest{
+		"sys=a   ip=10.0.0.1\t# gateway  \n    dom=a.example.com\n  \t\n  # b\nsys=b info=\"two words\"  flag\n",
+		func(f *File) { f.Format() },
+		"sys=a ip=10.0.0.1 # gateway\n\tdom=a.example.com\n\n\t# b\nsys=b info=\"two words\" flag=\n",
+	},
+	FileEditTest{
+		"sys=a x=a\"b\r\n y=\"a=b\"   z=\"a b\n  y=a\"=b   z=1 \n",
+		func(f *File) { f.Format() },
+		"sys=a x=a\"b\n\ty=\"a=b\" z=\"a b\"\n\ty=a\"=b   z=1\n",
+	},
+	FileEditTest{
+		"sys=a\n  \n  # a\n\tip=10.0.0.1\n \nsys=b\n",
+		func(f *File) { f.Format() },
+		"sys=a\n\t\n\t# a\n\tip=10.0.0.1\n\nsys=b\n",
+	},
+}
+
+func TestFileEdit(t *testing.T) {
+	for i, test := range fileedittests {
+		f, err := ParseFile(strings.NewReader(test.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.edit(f)
+
+		if out := string(f.Bytes()); out != test.out {
+			t.Errorf("test %d: got %q want %q", i, out, test.out)
+		}
+	}
+}
+
+func TestFileEditError(t *testing.T) {
+	in := "sys=a ip=10.0.0.1 # gateway\n\tdom=a.example.com\n"
+	edits := []func(f *File) error{
+		func(f *File) error { return f.Find("sys", "a")[0].Set("ip", "a\nb") },
+		func(f *File) error { return f.Find("sys", "a")[0].Set("ether", "\"0011") },
+		func(f *File) error { return f.Find("sys", "a")[0].Set("a b", "1") },
+		func(f *File) error { return f.Append(Record{{"sys", "b"}, {"info", "x \"y\""}}) },
+		func(f *File) error { return f.Replace(f.Find("sys", "a")[0], Record{{"sys", "a"}, {"", "1"}}) },
+	}
+
+	for i, edit := range edits {
+		f, err := ParseFile(strings.NewReader(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := edit(f); err == nil {
+			t.Errorf("test %d: no error", i)
+		}
+
+		if out := string(f.Bytes()); out != in {
+			t.Errorf("test %d: file changed to %q", i, out)
+		}
+	}
+}
+
+func TestParseFileError(t *testing.T) {
+	_, err := ParseFile(strings.NewReader("sys=a\n\t=b\n"))
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 2 || perr.Column != 2 {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFileDiff(t *testing.T) {
+	orig := []byte("sys=a  ip=10.0.0.1\n dom=a\n")
+
+	f, err := ParseFile(bytes.NewReader(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := f.Diff("hosts", orig); d != "" {
+		t.Errorf("unchanged file diff: %q", d)
+	}
+
+	f.Format()
+
+	want := "--- hosts\n+++ hosts\n@@ -1,2 +1,2 @@\n-sys=a  ip=10.0.0.1\n- dom=a\n+sys=a ip=10.0.0.1\n+\tdom=a\n"
+	if d := f.Diff("hosts", orig); d != want {
+		t.Errorf("got %q want %q", d, want)
+	}
+}
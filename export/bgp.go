@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+)
+
+// Options for BGP.
+type BGPOptions struct {
+	Style string // "bird" (the default) or "frr"
+}
+
+// A BGP session from the router to a peer.
+type bgppeer struct {
+	name, ip, asn string
+}
+
+// Write the BGP neighbors of the router whose sys= is router. Each of
+// its peer= tuples names a peer by sys= or by address; the peer's
+// session address is its first ip=. The asn= of the router and each
+// peer is found as Ipinfo does, so it may be inherited from an ipnet
+// record. Returns an error if the router or a peer can't be found or
+// has no asn, including when the Redact policy hides it.
+func BGP(w io.Writer, db *ndb.Ndb, router string, opt *BGPOptions) error {
+	style := "bird"
+	if opt != nil && opt.Style != "" {
+		style = opt.Style
+	}
+
+	if style != "bird" && style != "frr" {
+		return fmt.Errorf("bgp: unknown style %q", style)
+	}
+
+	recs := db.Search("sys", router)
+	if recs == nil {
+		return fmt.Errorf("bgp: no router sys=%s", router)
+	}
+
+	asn := ndb.Redact.Apply(db.Ipinfo("sys", router, "asn")).Search("asn")
+	if asn == "" {
+		return fmt.Errorf("bgp: router sys=%s has no asn", router)
+	}
+
+	var peers []bgppeer
+
+	for _, tuple := range recs[0] {
+		if tuple.Attr != "peer" {
+			continue
+		}
+
+		attr := "sys"
+		if net.ParseIP(tuple.Val) != nil {
+			attr = "ip"
+		}
+
+		found := db.Search(attr, tuple.Val)
+		if found == nil {
+			return fmt.Errorf("bgp: no peer %s=%s", attr, tuple.Val)
+		}
+
+		// peers are found by their real values, but written redacted
+		p := bgppeer{name: ndb.Redact.Apply(ndb.Record{tuple}).Search("peer")}
+		p.ip = ndb.Redact.Apply(ndb.Record{{Attr: "ip", Val: tuple.Val}}).Search("ip")
+		if attr == "sys" {
+			p.ip = ndb.Redact.Apply(found[0]).Search("ip")
+		}
+
+		p.asn = ndb.Redact.Apply(db.Ipinfo(attr, tuple.Val, "asn")).Search("asn")
+
+		if p.name == "" || p.ip == "" || p.asn == "" {
+			return fmt.Errorf("bgp: peer %s=%s needs an ip and asn", attr, tuple.Val)
+		}
+
+		peers = append(peers, p)
+	}
+
+	if Order == SortedOrder {
+		sort.SliceStable(peers, func(i, j int) bool {
+			return peers[i].name < peers[j].name
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if style == "frr" {
+		fmt.Fprintf(bw, "router bgp %s\n", asn)
+		for _, p := range peers {
+			fmt.Fprintf(bw, " neighbor %s remote-as %s\n", p.ip, p.asn)
+			fmt.Fprintf(bw, " neighbor %s description %s\n", p.ip, p.name)
+		}
+		fmt.Fprintf(bw, "!\n")
+		return bw.Flush()
+	}
+
+	for _, p := range peers {
+		channel := "ipv4"
+		if net.ParseIP(p.ip).To4() == nil {
+			channel = "ipv6"
+		}
+
+		fmt.Fprintf(bw, "protocol bgp %s {\n", birdname(p.name))
+		fmt.Fprintf(bw, "\tdescription \"%s\";\n", p.name)
+		fmt.Fprintf(bw, "\tlocal as %s;\n", asn)
+		fmt.Fprintf(bw, "\tneighbor %s as %s;\n", p.ip, p.asn)
+		fmt.Fprintf(bw, "\t%s;\n", channel)
+		fmt.Fprintf(bw, "}\n\n")
+	}
+
+	return bw.Flush()
+}
+
+var birdbad = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Make a BIRD protocol name from a peer name.
+func birdname(name string) string {
+	return "peer_" + birdbad.ReplaceAllString(name, "_")
+}
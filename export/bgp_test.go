@@ -0,0 +1,100 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestBGP(t *testing.T) {
+	db, err := ndb.Open("../testndb/bgp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := BGP(&buf, db, "r1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `protocol bgp peer_r2 {
+	description "r2";
+	local as 65001;
+	neighbor 10.0.0.2 as 65002;
+	ipv4;
+}
+
+protocol bgp peer_2001_db8__9 {
+	description "2001:db8::9";
+	local as 65001;
+	neighbor 2001:db8::9 as 64999;
+	ipv6;
+}
+
+`
+
+	if buf.String() != want {
+		t.Errorf("bird: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := BGP(&buf, db, "r1", &BGPOptions{Style: "frr"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want = `router bgp 65001
+ neighbor 10.0.0.2 remote-as 65002
+ neighbor 10.0.0.2 description r2
+ neighbor 2001:db8::9 remote-as 64999
+ neighbor 2001:db8::9 description 2001:db8::9
+!
+`
+
+	if buf.String() != want {
+		t.Errorf("frr: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	for _, router := range []string{"r3", "r4", "nonexistent"} {
+		if err := BGP(&buf, db, router, nil); err == nil {
+			t.Errorf("%s: expected error", router)
+		}
+	}
+}
+
+func TestBGPRedact(t *testing.T) {
+	db, err := ndb.Open("../testndb/bgp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(p ndb.RedactPolicy) { ndb.Redact = p }(ndb.Redact)
+	ndb.Redact = ndb.RedactPolicy{"peer": ndb.Hash}
+
+	var buf bytes.Buffer
+
+	if err := BGP(&buf, db, "r1", &BGPOptions{Style: "frr"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `router bgp 65001
+ neighbor 10.0.0.2 remote-as 65002
+ neighbor 10.0.0.2 description sha256:db77fd01af957221
+ neighbor 2001:db8::9 remote-as 64999
+ neighbor 2001:db8::9 description sha256:b9034233dd288e5b
+!
+`
+
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	ndb.Redact = ndb.RedactPolicy{"asn": ndb.Hide}
+
+	if err := BGP(&buf, db, "r1", nil); err == nil {
+		t.Errorf("exported with asn hidden")
+	}
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Write cloud-init meta-data for the host rec: its instance-id and
+// local-hostname, both from its sys=, or else its first dom= label.
+func CloudInitMetaData(w io.Writer, rec ndb.Record) error {
+	rec = ndb.Redact.Apply(rec)
+
+	name := hostname(rec)
+	if name == "" {
+		return fmt.Errorf("cloudinit: host has no sys or dom")
+	}
+
+	_, err := fmt.Fprintf(w, "instance-id: %s\nlocal-hostname: %s\n", yamlstr(name), yamlstr(name))
+	return err
+}
+
+// Write #cloud-config user-data for the host rec: its hostname, its
+// fqdn from dom=, and its pubkey= tuples as ssh_authorized_keys.
+func CloudInitUserData(w io.Writer, rec ndb.Record) error {
+	rec = ndb.Redact.Apply(rec)
+
+	name := hostname(rec)
+	if name == "" {
+		return fmt.Errorf("cloudinit: host has no sys or dom")
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "#cloud-config\nhostname: %s\n", yamlstr(name))
+
+	if dom := rec.Search("dom"); dom != "" {
+		fmt.Fprintf(bw, "fqdn: %s\n", yamlstr(dom))
+	}
+
+	if keys := vals(rec, "pubkey"); len(keys) > 0 {
+		fmt.Fprintf(bw, "ssh_authorized_keys:\n")
+		for _, key := range keys {
+			fmt.Fprintf(bw, "  - %s\n", yamlstr(key))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Write a version 2 cloud-init network-config for the host rec: one
+// ethernet interface matched by its first ether=, with its ip=
+// addresses, each with the ipmask= of its own network, and the ipgw=
+// and dns= of its first address. Each is taken from rec if it has it,
+// or else from the most specific ipnet record that does, as Ipinfo
+// would, but for rec itself rather than whichever record shares its
+// sys=.
+func CloudInitNetwork(w io.Writer, db *ndb.Ndb, rec ndb.Record) error {
+	key := rec.Key()
+
+	rec = ndb.Redact.Apply(rec)
+	ips := vals(rec, "ip")
+	if len(ips) == 0 {
+		return fmt.Errorf("cloudinit: %s=%s has no ip", key.Attr, key.Val)
+	}
+
+	var nets []ndb.Network
+	for _, nw := range db.Networks() {
+		nw.Record = ndb.Redact.Apply(nw.Record)
+		nets = append(nets, nw)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "version: 2\nethernets:\n  eth0:\n")
+
+	// macaddr leaves ether= values that aren't 12 hex digits alone
+	if ether := rec.Search("ether"); ether != "" && macaddr(ether) != ether {
+		fmt.Fprintf(bw, "    match:\n      macaddress: %s\n    set-name: eth0\n", yamlstr(macaddr(ether)))
+	}
+
+	fmt.Fprintf(bw, "    addresses:\n")
+	for _, ip := range ips {
+		mask := ""
+		if m := netinfo(nets, rec, ip, "ipmask"); len(m) > 0 {
+			mask = m[0]
+		}
+		fmt.Fprintf(bw, "      - %s\n", yamlstr(ip+"/"+strconv.Itoa(prefixlen(ip, mask))))
+	}
+
+	if gw := netinfo(nets, rec, ips[0], "ipgw"); len(gw) > 0 {
+		fmt.Fprintf(bw, "    routes:\n      - to: default\n        via: %s\n", yamlstr(gw[0]))
+	}
+
+	if dns := netinfo(nets, rec, ips[0], "dns"); len(dns) > 0 {
+		fmt.Fprintf(bw, "    nameservers:\n      addresses:\n")
+		for _, d := range dns {
+			fmt.Fprintf(bw, "        - %s\n", yamlstr(d))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// The values of attr in rec, or else in the most specific of nets
+// containing ip that has any.
+func netinfo(nets []ndb.Network, rec ndb.Record, ip, attr string) []string {
+	if v := vals(rec, attr); len(v) > 0 {
+		return v
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+
+	var best []string
+	bestlen := -1
+
+	for _, nw := range nets {
+		if !nw.Net.Contains(addr) {
+			continue
+		}
+
+		if ones, _ := nw.Net.Mask.Size(); ones > bestlen {
+			if v := vals(nw.Record, attr); len(v) > 0 {
+				best, bestlen = v, ones
+			}
+		}
+	}
+
+	return best
+}
+
+// The host's sys=, or else the first label of its dom=.
+func hostname(rec ndb.Record) string {
+	if sys := rec.Search("sys"); sys != "" {
+		return sys
+	}
+
+	return strings.SplitN(rec.Search("dom"), ".", 2)[0]
+}
+
+// Prefix length for ip given an ipmask= value, a dotted mask or /n.
+// A host route is assumed without a usable mask.
+func prefixlen(ip, mask string) int {
+	bits := 32
+	if a := net.ParseIP(ip); a != nil && a.To4() == nil {
+		bits = 128
+	}
+
+	if strings.HasPrefix(mask, "/") {
+		if n, err := strconv.Atoi(mask[1:]); err == nil && n >= 0 && n <= bits {
+			return n
+		}
+		return bits
+	}
+
+	if m := net.ParseIP(mask); m != nil && bits == 32 && m.To4() != nil {
+		if ones, size := net.IPMask(m.To4()).Size(); size != 0 {
+			return ones
+		}
+	}
+
+	return bits
+}
+
+// Quote s as a YAML double quoted scalar.
+func yamlstr(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
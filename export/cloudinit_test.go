@@ -0,0 +1,166 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestCloudInit(t *testing.T) {
+	db, err := ndb.Open("../testndb/cloudinit")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := db.Search("sys", "fir")[0]
+
+	var buf bytes.Buffer
+
+	if err := CloudInitMetaData(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "instance-id: \"fir\"\nlocal-hostname: \"fir\"\n"; buf.String() != want {
+		t.Errorf("meta-data: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := CloudInitUserData(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `#cloud-config
+hostname: "fir"
+fqdn: "fir.example.com"
+ssh_authorized_keys:
+  - "ssh-ed25519 AAAAC3Nza fir@example.com"
+`
+
+	if buf.String() != want {
+		t.Errorf("user-data: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := CloudInitNetwork(&buf, db, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	want = `version: 2
+ethernets:
+  eth0:
+    match:
+      macaddress: "00:11:22:aa:bb:cc"
+    set-name: eth0
+    addresses:
+      - "10.1.2.10/24"
+    routes:
+      - to: default
+        via: "10.1.2.1"
+    nameservers:
+      addresses:
+        - "10.1.2.2"
+        - "10.1.2.3"
+`
+
+	if buf.String() != want {
+		t.Errorf("network-config: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	if err := CloudInitNetwork(&buf, db, ndb.Record{{Attr: "sys", Val: "none"}}); err == nil {
+		t.Errorf("expected error for host without ip")
+	}
+
+	// each address gets its own network's mask, and a host is
+	// configured from its own record, not another with its sys=
+	pines := db.Search("sys", "pine")
+
+	tests := []struct {
+		rec  ndb.Record
+		want string
+	}{
+		{pines[0], `version: 2
+ethernets:
+  eth0:
+    match:
+      macaddress: "00:11:22:aa:bb:dd"
+    set-name: eth0
+    addresses:
+      - "10.1.2.20/24"
+      - "10.9.4.20/16"
+    routes:
+      - to: default
+        via: "10.1.2.1"
+    nameservers:
+      addresses:
+        - "10.1.2.2"
+        - "10.1.2.3"
+`},
+		{pines[1], `version: 2
+ethernets:
+  eth0:
+    addresses:
+      - "10.9.4.21/16"
+    routes:
+      - to: default
+        via: "10.9.0.1"
+`},
+	}
+
+	for i, test := range tests {
+		buf.Reset()
+
+		if err := CloudInitNetwork(&buf, db, test.rec); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != test.want {
+			t.Errorf("pine %d: expected\n%s\ngot\n%s", i, test.want, buf.String())
+		}
+	}
+}
+
+func TestCloudInitRedact(t *testing.T) {
+	db, err := ndb.Open("../testndb/cloudinit")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(p ndb.RedactPolicy) { ndb.Redact = p }(ndb.Redact)
+	ndb.Redact = ndb.RedactPolicy{"sys": ndb.Hide, "ether": ndb.Hide, "dns": ndb.Hide}
+
+	rec := db.Search("sys", "fir")[0]
+
+	var buf bytes.Buffer
+
+	if err := CloudInitMetaData(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "instance-id: \"fir\"\nlocal-hostname: \"fir\"\n"; buf.String() != want {
+		t.Errorf("meta-data: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := CloudInitNetwork(&buf, db, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `version: 2
+ethernets:
+  eth0:
+    addresses:
+      - "10.1.2.10/24"
+    routes:
+      - to: default
+        via: "10.1.2.1"
+`
+
+	if buf.String() != want {
+		t.Errorf("network-config: expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
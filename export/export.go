@@ -0,0 +1,58 @@
+// Package export generates configuration for other systems
+// from ndb records. Every exporter applies the ndb.Redact policy
+// to records before using them, and writes in the order Order says.
+package export
+
+import (
+	"github.com/mischief/ndb"
+	"sort"
+)
+
+// How exporters order what they write.
+type Ordering int
+
+const (
+	DatabaseOrder Ordering = iota // As given, usually the order of the database
+	SortedOrder                   // Sorted, so regenerated files diff cleanly
+)
+
+// The ordering applied by every exporter. With SortedOrder, records
+// are sorted by the value of their key (see ndb.Record.Key), switch
+// ports by switch and port, and BGP peers by name, so moving records
+// around the database doesn't change the output. DatabaseOrder by
+// default.
+var Order = DatabaseOrder
+
+// Return recs in the order Order says, sorting a copy if it says to.
+func ordered(recs ndb.RecordSet) ndb.RecordSet {
+	if Order != SortedOrder {
+		return recs
+	}
+
+	sorted := append(ndb.RecordSet(nil), recs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Key(), sorted[j].Key()
+		if a.Val != b.Val {
+			return a.Val < b.Val
+		}
+		if a.Attr != b.Attr {
+			return a.Attr < b.Attr
+		}
+		return sorted[i].String() < sorted[j].String()
+	})
+
+	return sorted
+}
+
+// Return all values of attr in rec.
+func vals(rec ndb.Record, attr string) []string {
+	var vals []string
+
+	for _, tuple := range rec {
+		if tuple.Attr == attr {
+			vals = append(vals, tuple.Val)
+		}
+	}
+
+	return vals
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"strings"
+)
+
+// Options for Factotum.
+type FactotumOptions struct {
+	Secret []string // Attributes to mark secret with '!', besides password
+}
+
+// Write a factotum(4) key line for each record in recs that has a
+// key= tuple, e.g. the record
+//
+//	key= proto=p9sk1 dom=example.com user=glenda !password=secret
+//
+// becomes
+//
+//	key proto=p9sk1 dom=example.com user=glenda !password=secret
+//
+// The key= tuple itself is dropped. Attributes already beginning
+// with '!', password, and those listed in opt.Secret are written as
+// secret. The output can be written to /mnt/factotum/ctl.
+func Factotum(w io.Writer, recs ndb.RecordSet, opt *FactotumOptions) error {
+	secret := map[string]bool{"password": true}
+
+	if opt != nil {
+		for _, attr := range opt.Secret {
+			secret[attr] = true
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for _, rec := range ordered(recs) {
+		rec = ndb.Redact.Apply(rec)
+		if vals(rec, "key") == nil {
+			continue
+		}
+
+		fmt.Fprint(bw, "key")
+
+		for _, tuple := range rec {
+			if tuple.Attr == "key" {
+				continue
+			}
+
+			attr := tuple.Attr
+			if secret[attr] {
+				attr = "!" + attr
+			}
+
+			fmt.Fprintf(bw, " %s=%s", attr, p9quote(tuple.Val))
+		}
+
+		fmt.Fprint(bw, "\n")
+	}
+
+	return bw.Flush()
+}
+
+// Quote a string as quote(2) does, with single quotes, doubling
+// any quotes inside, if it is empty or contains spaces or quotes.
+func p9quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'") {
+		return s
+	}
+
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,53 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestFactotum(t *testing.T) {
+	recs := ndb.RecordSet{
+		ndb.Record{{Attr: "key", Val: ""}, {Attr: "proto", Val: "p9sk1"}, {Attr: "dom", Val: "example.com"},
+			{Attr: "user", Val: "glenda"}, {Attr: "!password", Val: "secret"}},
+		ndb.Record{{Attr: "key", Val: ""}, {Attr: "proto", Val: "pass"}, {Attr: "server", Val: "imap.example.com"},
+			{Attr: "user", Val: "glenda"}, {Attr: "password", Val: "it's a secret"}, {Attr: "pin", Val: "1234"}},
+		ndb.Record{{Attr: "sys", Val: "fir"}, {Attr: "password", Val: "not a key"}},
+	}
+
+	var buf bytes.Buffer
+
+	if err := Factotum(&buf, recs, &FactotumOptions{Secret: []string{"pin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `key proto=p9sk1 dom=example.com user=glenda !password=secret
+key proto=pass server=imap.example.com user=glenda !password='it''s a secret' !pin=1234
+`
+
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestFactotumRedact(t *testing.T) {
+	recs := ndb.RecordSet{
+		ndb.Record{{Attr: "key", Val: ""}, {Attr: "proto", Val: "p9sk1"}, {Attr: "user", Val: "glenda"},
+			{Attr: "!password", Val: "secret"}},
+	}
+
+	defer func(p ndb.RedactPolicy) { ndb.Redact = p }(ndb.Redact)
+	ndb.Redact = ndb.RedactPolicy{"!password": ndb.Hide}
+
+	var buf bytes.Buffer
+
+	if err := Factotum(&buf, recs, &FactotumOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "key proto=p9sk1 user=glenda\n"
+
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
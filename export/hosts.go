@@ -0,0 +1,39 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"strings"
+)
+
+// Write an /etc/hosts file with a line for each ip= of each record in
+// recs that has a dom= or sys=. The names on a line are the record's
+// dom= values followed by its sys= values, without duplicates.
+func Hosts(w io.Writer, recs ndb.RecordSet) error {
+	bw := bufio.NewWriter(w)
+
+	for _, rec := range ordered(recs) {
+		rec = ndb.Redact.Apply(rec)
+
+		var names []string
+		seen := make(map[string]bool)
+		for _, name := range append(vals(rec, "dom"), vals(rec, "sys")...) {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		for _, ip := range vals(rec, "ip") {
+			fmt.Fprintf(bw, "%s\t%s\n", ip, strings.Join(names, " "))
+		}
+	}
+
+	return bw.Flush()
+}
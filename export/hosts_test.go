@@ -0,0 +1,54 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestHosts(t *testing.T) {
+	recs := ndb.RecordSet{
+		ndb.Record{{Attr: "sys", Val: "fir"}, {Attr: "dom", Val: "fir.example.com"}, {Attr: "ip", Val: "10.1.2.10"},
+			{Attr: "ip", Val: "2001:db8::10"}, {Attr: "sys", Val: "fir"}},
+		ndb.Record{{Attr: "ip", Val: "10.9.9.9"}},
+		ndb.Record{{Attr: "sys", Val: "noip"}},
+	}
+
+	var buf bytes.Buffer
+
+	if err := Hosts(&buf, recs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "10.1.2.10\tfir.example.com fir\n2001:db8::10\tfir.example.com fir\n"
+
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestHostsSorted(t *testing.T) {
+	recs := ndb.RecordSet{
+		ndb.Record{{Attr: "sys", Val: "oak"}, {Attr: "ip", Val: "10.1.2.11"}},
+		ndb.Record{{Attr: "sys", Val: "fir"}, {Attr: "ip", Val: "10.1.2.10"}},
+	}
+
+	Order = SortedOrder
+	defer func() { Order = DatabaseOrder }()
+
+	var buf bytes.Buffer
+
+	if err := Hosts(&buf, recs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "10.1.2.10\tfir\n10.1.2.11\toak\n"
+
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	if recs[0].Search("sys") != "oak" {
+		t.Errorf("sorting changed the records given")
+	}
+}
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"strings"
+)
+
+// Options for LDIF.
+type LDIFOptions struct {
+	BaseDN string // Entries are created under this DN, e.g. ou=hosts,dc=example,dc=com
+}
+
+// Write an LDIF entry for each record in recs with a sys= or dom=,
+// using the RFC 2307 device, ipHost and ieee802Device object classes.
+// The entry's cn is its sys, or else its first dom; all sys= and dom=
+// values become cn values. ip= becomes ipHostNumber, ether= becomes
+// macAddress, and info= becomes description.
+func LDIF(w io.Writer, recs ndb.RecordSet, opt *LDIFOptions) error {
+	if opt == nil || opt.BaseDN == "" {
+		return fmt.Errorf("ldif: no base DN")
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "version: 1\n")
+
+	for _, rec := range ordered(recs) {
+		rec = ndb.Redact.Apply(rec)
+		names := append(vals(rec, "sys"), vals(rec, "dom")...)
+		if len(names) == 0 {
+			continue
+		}
+
+		ips := vals(rec, "ip")
+		macs := vals(rec, "ether")
+
+		fmt.Fprintf(bw, "\n")
+		ldifattr(bw, "dn", "cn="+dnescape(names[0])+","+opt.BaseDN)
+		ldifattr(bw, "objectClass", "top")
+		ldifattr(bw, "objectClass", "device")
+
+		if len(ips) > 0 {
+			ldifattr(bw, "objectClass", "ipHost")
+		}
+
+		if len(macs) > 0 {
+			ldifattr(bw, "objectClass", "ieee802Device")
+		}
+
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				ldifattr(bw, "cn", name)
+			}
+		}
+
+		for _, ip := range ips {
+			ldifattr(bw, "ipHostNumber", ip)
+		}
+
+		for _, mac := range macs {
+			ldifattr(bw, "macAddress", macaddr(mac))
+		}
+
+		for _, info := range vals(rec, "info") {
+			ldifattr(bw, "description", info)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Write an attribute line, base64 encoding values
+// that are not safe strings as defined by RFC 2849.
+func ldifattr(w io.Writer, attr, val string) {
+	if ldifsafe(val) {
+		fmt.Fprintf(w, "%s: %s\n", attr, val)
+	} else {
+		fmt.Fprintf(w, "%s:: %s\n", attr, base64.StdEncoding.EncodeToString([]byte(val)))
+	}
+}
+
+func ldifsafe(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	switch s[0] {
+	case ' ', ':', '<':
+		return false
+	}
+
+	if s[len(s)-1] == ' ' {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == 0 || c == '\n' || c == '\r' || c > 127 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Escape an attribute value for use in a DN, as in RFC 4514.
+func dnescape(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			i == 0 && (c == '#' || c == ' '),
+			i == len(s)-1 && c == ' ':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// Convert an ndb ether address (12 hex digits) to colon separated
+// form. Other forms are returned unchanged.
+func macaddr(ether string) string {
+	if len(ether) != 12 || strings.Trim(strings.ToLower(ether), "0123456789abcdef") != "" {
+		return ether
+	}
+
+	var parts []string
+	for i := 0; i < 12; i += 2 {
+		parts = append(parts, strings.ToLower(ether[i:i+2]))
+	}
+
+	return strings.Join(parts, ":")
+}
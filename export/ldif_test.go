@@ -0,0 +1,50 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestLDIF(t *testing.T) {
+	recs := ndb.RecordSet{
+		ndb.Record{{Attr: "sys", Val: "fir"}, {Attr: "dom", Val: "fir.example.com"}, {Attr: "ip", Val: "10.1.2.10"},
+			{Attr: "ether", Val: "001122AABBCC"}, {Attr: "info", Val: "rack 3"}},
+		ndb.Record{{Attr: "dom", Val: "a,b.example.com"}, {Attr: "info", Val: ":colon"}},
+		ndb.Record{{Attr: "ip", Val: "10.9.9.9"}},
+	}
+
+	var buf bytes.Buffer
+
+	if err := LDIF(&buf, recs, &LDIFOptions{BaseDN: "ou=hosts,dc=example,dc=com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `version: 1
+
+dn: cn=fir,ou=hosts,dc=example,dc=com
+objectClass: top
+objectClass: device
+objectClass: ipHost
+objectClass: ieee802Device
+cn: fir
+cn: fir.example.com
+ipHostNumber: 10.1.2.10
+macAddress: 00:11:22:aa:bb:cc
+description: rack 3
+
+dn: cn=a\,b.example.com,ou=hosts,dc=example,dc=com
+objectClass: top
+objectClass: device
+cn: a,b.example.com
+description:: OmNvbG9u
+`
+
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if err := LDIF(&buf, recs, nil); err == nil {
+		t.Errorf("expected error without base DN")
+	}
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"strings"
+)
+
+// Options for Nagios.
+type NagiosOptions struct {
+	HostTemplate    string // Host template to use; "generic-host" if empty
+	ServiceTemplate string // Service template to use; "generic-service" if empty
+}
+
+// Write Nagios object definitions, also read by Icinga 1 and Naemon,
+// for each record in recs with an ip= or dom=: a host named by its sys,
+// or else its first dom, at its first ip or else dom, and a service for
+// each check= tuple, whose value is a check_command such as
+// check_http!8080, described as the command and its arguments so
+// that each is unique. contact= values become the contacts of the host and
+// its services.
+func Nagios(w io.Writer, recs ndb.RecordSet, opt *NagiosOptions) error {
+	hosttmpl, svctmpl := "generic-host", "generic-service"
+	if opt != nil && opt.HostTemplate != "" {
+		hosttmpl = opt.HostTemplate
+	}
+	if opt != nil && opt.ServiceTemplate != "" {
+		svctmpl = opt.ServiceTemplate
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for _, rec := range ordered(recs) {
+		rec = ndb.Redact.Apply(rec)
+
+		addr := rec.Search("ip")
+		if addr == "" {
+			addr = rec.Search("dom")
+		}
+
+		name := rec.Search("sys")
+		if name == "" {
+			name = rec.Search("dom")
+		}
+
+		if addr == "" || name == "" {
+			continue
+		}
+
+		contacts := strings.Join(vals(rec, "contact"), ",")
+
+		fmt.Fprintf(bw, "define host {\n")
+		nagiosattr(bw, "use", hosttmpl)
+		nagiosattr(bw, "host_name", name)
+		nagiosattr(bw, "alias", rec.Search("dom"))
+		nagiosattr(bw, "address", addr)
+		nagiosattr(bw, "contacts", contacts)
+		fmt.Fprintf(bw, "}\n\n")
+
+		for _, check := range vals(rec, "check") {
+			fmt.Fprintf(bw, "define service {\n")
+			nagiosattr(bw, "use", svctmpl)
+			nagiosattr(bw, "host_name", name)
+			nagiosattr(bw, "service_description", strings.Replace(check, "!", " ", -1))
+			nagiosattr(bw, "check_command", check)
+			nagiosattr(bw, "contacts", contacts)
+			fmt.Fprintf(bw, "}\n\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Write a directive of an object definition, unless val is empty.
+func nagiosattr(w io.Writer, name, val string) {
+	if val != "" {
+		fmt.Fprintf(w, "\t%-20s %s\n", name, val)
+	}
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestNagios(t *testing.T) {
+	recs := ndb.RecordSet{
+		ndb.Record{{Attr: "sys", Val: "fir"}, {Attr: "dom", Val: "fir.example.com"}, {Attr: "ip", Val: "10.1.2.10"},
+			{Attr: "check", Val: "check_ssh"}, {Attr: "check", Val: "check_http!8080"},
+			{Attr: "contact", Val: "ops"}, {Attr: "contact", Val: "glenda"}},
+		ndb.Record{{Attr: "dom", Val: "www.example.com"}},
+		ndb.Record{{Attr: "ipnet", Val: "lab"}},
+	}
+
+	var buf bytes.Buffer
+
+	if err := Nagios(&buf, recs, &NagiosOptions{HostTemplate: "linux-server"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `define host {
+	use                  linux-server
+	host_name            fir
+	alias                fir.example.com
+	address              10.1.2.10
+	contacts             ops,glenda
+}
+
+define service {
+	use                  generic-service
+	host_name            fir
+	service_description  check_ssh
+	check_command        check_ssh
+	contacts             ops,glenda
+}
+
+define service {
+	use                  generic-service
+	host_name            fir
+	service_description  check_http 8080
+	check_command        check_http!8080
+	contacts             ops,glenda
+}
+
+define host {
+	use                  linux-server
+	host_name            www.example.com
+	alias                www.example.com
+	address              www.example.com
+}
+
+`
+
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,83 @@
+package export
+
+import (
+	"encoding/json"
+	"github.com/mischief/ndb"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Options for Prometheus.
+type PrometheusOptions struct {
+	Port   int      // Port appended to each target; 0 for none
+	Labels []string // Attributes copied into target labels
+}
+
+// A target group in a Prometheus file_sd file.
+type targetgroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// Write a Prometheus file_sd target list with one target group per
+// record in recs that has a dom= or ip=. The target is the record's
+// first dom, or else its first ip. Labels are taken from the
+// attributes named in opt.Labels, plus an "ipnet" label naming the
+// most specific network the host is on, if any.
+func Prometheus(w io.Writer, db *ndb.Ndb, recs ndb.RecordSet, opt *PrometheusOptions) error {
+	if opt == nil {
+		opt = &PrometheusOptions{}
+	}
+
+	groups := []targetgroup{}
+
+	for _, rec := range ordered(recs) {
+		rec = ndb.Redact.Apply(rec)
+		host := rec.Search("dom")
+		if host == "" {
+			host = rec.Search("ip")
+		}
+
+		if host == "" {
+			continue
+		}
+
+		if opt.Port != 0 {
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+			host += ":" + strconv.Itoa(opt.Port)
+		}
+
+		labels := make(map[string]string)
+
+		for _, attr := range opt.Labels {
+			if val := rec.Search(attr); val != "" {
+				labels[labelname(attr)] = val
+			}
+		}
+
+		if ip := rec.Search("ip"); ip != "" {
+			if nw := db.Ipinfo("ip", ip, "ipnet"); len(nw) > 0 {
+				labels["ipnet"] = nw[0].Val
+			}
+		}
+
+		groups = append(groups, targetgroup{[]string{host}, labels})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(groups)
+}
+
+// Make a valid Prometheus label name from an attribute.
+func labelname(attr string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, attr)
+}
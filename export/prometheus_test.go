@@ -0,0 +1,49 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/mischief/ndb"
+	"reflect"
+	"testing"
+)
+
+const (
+	testndb = "../testndb/export"
+)
+
+func TestPrometheus(t *testing.T) {
+	db, err := ndb.Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	opt := &PrometheusOptions{Port: 9100, Labels: []string{"role", "os"}}
+
+	if err := Prometheus(&buf, db, db.Search("sys", ""), opt); err != nil {
+		t.Fatal(err)
+	}
+
+	var groups []targetgroup
+
+	if err := json.Unmarshal(buf.Bytes(), &groups); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []targetgroup{
+		targetgroup{[]string{"fir.example.com:9100"}, map[string]string{"role": "web", "os": "9front", "ipnet": "lab"}},
+		targetgroup{[]string{"10.1.2.11:9100"}, map[string]string{"role": "db", "os": "linux", "ipnet": "lab"}},
+		targetgroup{[]string{"far.example.com:9100"}, nil},
+	}
+
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("expected %+v got %+v", want, groups)
+	}
+
+	if labelname("ip-gw.x") != "ip_gw_x" {
+		t.Errorf("bad label name %q", labelname("ip-gw.x"))
+	}
+}
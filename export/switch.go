@@ -0,0 +1,84 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Options for Switch.
+type SwitchOptions struct {
+	Style string // "ios" (the default) or "junos"
+}
+
+// Write interface configuration for the ports in ports, as returned
+// by ndb.SwitchPorts: a description naming the host, by its dom= or
+// else its sys= or key, and its vlan. A port with one vlan= is an
+// access port and one with several is a trunk carrying them all.
+// Ports without a port= are skipped.
+func Switch(w io.Writer, ports []ndb.SwitchPort, opt *SwitchOptions) error {
+	style := "ios"
+	if opt != nil && opt.Style != "" {
+		style = opt.Style
+	}
+
+	if style != "ios" && style != "junos" {
+		return fmt.Errorf("switch: unknown style %q", style)
+	}
+
+	if Order == SortedOrder {
+		ports = append([]ndb.SwitchPort(nil), ports...)
+		sort.SliceStable(ports, func(i, j int) bool {
+			if ports[i].Switch != ports[j].Switch {
+				return ports[i].Switch < ports[j].Switch
+			}
+			return ports[i].Port < ports[j].Port
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for _, sp := range ports {
+		if sp.Port == "" {
+			continue
+		}
+
+		host := ndb.Redact.Apply(sp.Host)
+		desc := host.Search("dom")
+		if desc == "" {
+			desc = host.Search("sys")
+		}
+		if desc == "" {
+			desc = host.Key().Val
+		}
+
+		if style == "ios" {
+			fmt.Fprintf(bw, "interface %s\n", sp.Port)
+			fmt.Fprintf(bw, " description %s\n", desc)
+			switch len(sp.Vlans) {
+			case 0:
+			case 1:
+				fmt.Fprintf(bw, " switchport mode access\n switchport access vlan %s\n", sp.Vlans[0])
+			default:
+				fmt.Fprintf(bw, " switchport mode trunk\n switchport trunk allowed vlan %s\n", strings.Join(sp.Vlans, ","))
+			}
+			fmt.Fprintf(bw, "!\n")
+			continue
+		}
+
+		fmt.Fprintf(bw, "set interfaces %s description %q\n", sp.Port, desc)
+		if len(sp.Vlans) > 0 {
+			mode := "access"
+			if len(sp.Vlans) > 1 {
+				mode = "trunk"
+			}
+			fmt.Fprintf(bw, "set interfaces %s unit 0 family ethernet-switching interface-mode %s\n", sp.Port, mode)
+			fmt.Fprintf(bw, "set interfaces %s unit 0 family ethernet-switching vlan members [ %s ]\n", sp.Port, strings.Join(sp.Vlans, " "))
+		}
+	}
+
+	return bw.Flush()
+}
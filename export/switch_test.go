@@ -0,0 +1,74 @@
+package export
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestSwitch(t *testing.T) {
+	ports := []ndb.SwitchPort{
+		{Switch: "sw1", Port: "ge-0/0/1", Vlans: []string{"100"},
+			Host: ndb.Record{{Attr: "sys", Val: "fir"}, {Attr: "dom", Val: "fir.example.com"}}},
+		{Switch: "sw1", Port: "ge-0/0/2", Vlans: []string{"100", "200"},
+			Host: ndb.Record{{Attr: "sys", Val: "oak"}}},
+		{Switch: "sw1", Host: ndb.Record{{Attr: "sys", Val: "elm"}}},
+	}
+
+	var buf bytes.Buffer
+
+	if err := Switch(&buf, ports, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `interface ge-0/0/1
+ description fir.example.com
+ switchport mode access
+ switchport access vlan 100
+!
+interface ge-0/0/2
+ description oak
+ switchport mode trunk
+ switchport trunk allowed vlan 100,200
+!
+`
+
+	if buf.String() != want {
+		t.Errorf("ios: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := Switch(&buf, ports[:2], &SwitchOptions{Style: "junos"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want = `set interfaces ge-0/0/1 description "fir.example.com"
+set interfaces ge-0/0/1 unit 0 family ethernet-switching interface-mode access
+set interfaces ge-0/0/1 unit 0 family ethernet-switching vlan members [ 100 ]
+set interfaces ge-0/0/2 description "oak"
+set interfaces ge-0/0/2 unit 0 family ethernet-switching interface-mode trunk
+set interfaces ge-0/0/2 unit 0 family ethernet-switching vlan members [ 100 200 ]
+`
+
+	if buf.String() != want {
+		t.Errorf("junos: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+
+	Order = SortedOrder
+	defer func() { Order = DatabaseOrder }()
+
+	if err := Switch(&buf, []ndb.SwitchPort{ports[1], ports[0]}, &SwitchOptions{Style: "junos"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != want {
+		t.Errorf("junos sorted: expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	if err := Switch(&buf, ports, &SwitchOptions{Style: "eos"}); err == nil {
+		t.Errorf("expected error for unknown style")
+	}
+}
@@ -0,0 +1,35 @@
+package ndb
+
+import (
+	"expvar"
+)
+
+// Counters published through expvar as the "ndb" map, and so
+// served at /debug/vars by programs using net/http. Programs can
+// also import net/http/pprof to profile the same server.
+var (
+	statParses        = new(expvar.Int) // Files parsed
+	statParseErrors   = new(expvar.Int) // Files that failed to open or parse
+	statReloads       = new(expvar.Int) // Calls to Reopen
+	statReloadErrors  = new(expvar.Int) // Calls to Reopen that failed, keeping the old records
+	statSearches      = new(expvar.Int) // Record searches
+	statIpinfos       = new(expvar.Int) // Ipinfo queries
+	statCacheHits     = new(expvar.Int) // Ipinfo queries answered by the cache
+	statCacheMisses   = new(expvar.Int) // Ipinfo queries the cache could not answer
+	statBloomSkips    = new(expvar.Int) // Files skipped by searches thanks to WithBloom
+	statSnapshotLoads = new(expvar.Int) // Databases loaded from a snapshot instead of parsed
+)
+
+func init() {
+	m := expvar.NewMap("ndb")
+	m.Set("parses", statParses)
+	m.Set("parse_errors", statParseErrors)
+	m.Set("reloads", statReloads)
+	m.Set("reload_errors", statReloadErrors)
+	m.Set("searches", statSearches)
+	m.Set("ipinfos", statIpinfos)
+	m.Set("ipcache_hits", statCacheHits)
+	m.Set("ipcache_misses", statCacheMisses)
+	m.Set("bloom_skips", statBloomSkips)
+	m.Set("snapshot_loads", statSnapshotLoads)
+}
@@ -0,0 +1,52 @@
+package ndb
+
+import (
+	"expvar"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	m, ok := expvar.Get("ndb").(*expvar.Map)
+
+	if !ok {
+		t.Fatal("ndb expvar map not published")
+	}
+
+	get := func(name string) int64 {
+		return m.Get(name).(*expvar.Int).Value()
+	}
+
+	parses, errors, searches := get("parses"), get("parse_errors"), get("searches")
+	hits, misses := get("ipcache_hits"), get("ipcache_misses")
+
+	db, err := Open(testipnet)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open("testndb/nonexistent"); err == nil {
+		t.Fatal("opened nonexistent file")
+	}
+
+	db.SetIpinfoCache(1)
+	db.Ipinfo("sys", "anna", "ipgw")
+	db.Ipinfo("sys", "anna", "ipgw")
+
+	if n := get("parses") - parses; n != 2 {
+		t.Errorf("expected 2 parses, got %d", n)
+	}
+
+	if n := get("parse_errors") - errors; n != 1 {
+		t.Errorf("expected 1 parse error, got %d", n)
+	}
+
+	// one search for the database record, one for the ipinfo miss
+	if n := get("searches") - searches; n != 2 {
+		t.Errorf("expected 2 searches, got %d", n)
+	}
+
+	if get("ipcache_hits")-hits != 1 || get("ipcache_misses")-misses != 1 {
+		t.Errorf("expected 1 cache hit and 1 miss")
+	}
+}
@@ -0,0 +1,132 @@
+package ndb
+
+import (
+	"sort"
+	"strings"
+)
+
+// Separate databases queried together, such as those of organizations
+// being merged, each under its own name.
+type Federation struct {
+	Members []Member
+}
+
+// A database in a Federation.
+type Member struct {
+	Name string
+	DB   *Ndb
+}
+
+// A record found in a Federation, and the member it came from.
+type MemberRecord struct {
+	Member string
+	Record Record
+}
+
+// Members of a Federation disagreeing about a record: each has a
+// record with Key, but their values of Attr differ.
+type Conflict struct {
+	Key  Tuple
+	Attr string
+	Vals map[string][]string // Values of Attr in each member's records, by member name
+}
+
+// Search each member for attr=val as Search does, returning the
+// records in member order.
+func (f *Federation) Search(attr, val string) []MemberRecord {
+	var found []MemberRecord
+
+	for _, m := range f.Members {
+		for _, rec := range m.DB.Search(attr, val) {
+			found = append(found, MemberRecord{m.Name, rec})
+		}
+	}
+
+	return found
+}
+
+// Report where members disagree: for each value of keyattr, such as
+// sys, found in more than one member, compare the values of attrs in
+// their records, or of every attribute if attrs is empty. Members
+// without the attribute are left out, so a member that doesn't track
+// ether= doesn't conflict with one that does. Conflicts are sorted by
+// key value and then attribute.
+func (f *Federation) Conflicts(keyattr string, attrs ...string) []Conflict {
+	// values of each attribute, by key value and member
+	keys := make(map[string]map[string]map[string][]string)
+
+	for _, m := range f.Members {
+		m.DB.Walk(func(rec Record, pos Pos) bool {
+			for _, key := range rec.find(keyattr) {
+				if keys[key.Val] == nil {
+					keys[key.Val] = make(map[string]map[string][]string)
+				}
+				if keys[key.Val][m.Name] == nil {
+					keys[key.Val][m.Name] = make(map[string][]string)
+				}
+				for _, tuple := range rec {
+					if tuple.Attr != keyattr {
+						vals := keys[key.Val][m.Name]
+						vals[tuple.Attr] = append(vals[tuple.Attr], tuple.Val)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	var conflicts []Conflict
+
+	keyvals := make([]string, 0, len(keys))
+	for keyval := range keys {
+		keyvals = append(keyvals, keyval)
+	}
+	sort.Strings(keyvals)
+
+	for _, keyval := range keyvals {
+		members := keys[keyval]
+		if len(members) < 2 {
+			continue
+		}
+
+		check := attrs
+		if len(check) == 0 {
+			seen := make(map[string]bool)
+			for _, vals := range members {
+				for attr := range vals {
+					seen[attr] = true
+				}
+			}
+			check = sortedkeys(seen)
+		}
+
+		for _, attr := range check {
+			c := Conflict{Tuple{keyattr, keyval}, attr, make(map[string][]string)}
+
+			var first string
+			agree := true
+
+			for name, vals := range members {
+				if vals[attr] == nil {
+					continue
+				}
+
+				v := append([]string(nil), vals[attr]...)
+				sort.Strings(v)
+				c.Vals[name] = v
+
+				if len(c.Vals) == 1 {
+					first = strings.Join(v, "\x00")
+				} else if strings.Join(v, "\x00") != first {
+					agree = false
+				}
+			}
+
+			if !agree {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	return conflicts
+}
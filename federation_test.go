@@ -0,0 +1,72 @@
+package ndb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testfederation(t *testing.T) *Federation {
+	f := &Federation{}
+
+	for _, name := range []string{"corp", "lab", "cloud"} {
+		db, err := Open("testndb/fed" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Members = append(f.Members, Member{name, db})
+	}
+
+	return f
+}
+
+func TestFederationSearch(t *testing.T) {
+	f := testfederation(t)
+
+	var members []string
+	for _, mr := range f.Search("sys", "anna") {
+		members = append(members, mr.Member)
+	}
+
+	if want := []string{"corp", "lab", "cloud"}; !reflect.DeepEqual(members, want) {
+		t.Errorf("sys=anna found in %q, want %q", members, want)
+	}
+
+	if found := f.Search("sys", "dave"); len(found) != 1 || found[0].Member != "lab" {
+		t.Errorf("sys=dave found %v", found)
+	}
+}
+
+type ConflictTest struct {
+	attrs []string
+	want  []Conflict
+}
+
+var conflicttests = []ConflictTest{
+	ConflictTest{
+		[]string{"ip"},
+		[]Conflict{
+			Conflict{Tuple{"sys", "anna"}, "ip", map[string][]string{"corp": {"10.1.0.10"}, "lab": {"10.1.0.10"}, "cloud": {"172.16.0.10"}}},
+			Conflict{Tuple{"sys", "bob"}, "ip", map[string][]string{"corp": {"10.1.0.11"}, "lab": {"10.1.0.11", "10.9.0.11"}}},
+		},
+	},
+	ConflictTest{
+		nil,
+		[]Conflict{
+			Conflict{Tuple{"sys", "anna"}, "dom", map[string][]string{"corp": {"anna.corp.example.com"}, "cloud": {"anna.cloud.example.com"}}},
+			Conflict{Tuple{"sys", "anna"}, "ether", map[string][]string{"corp": {"00163e000001"}, "lab": {"00163e0000ff"}}},
+			Conflict{Tuple{"sys", "anna"}, "ip", map[string][]string{"corp": {"10.1.0.10"}, "lab": {"10.1.0.10"}, "cloud": {"172.16.0.10"}}},
+			Conflict{Tuple{"sys", "bob"}, "ip", map[string][]string{"corp": {"10.1.0.11"}, "lab": {"10.1.0.11", "10.9.0.11"}}},
+		},
+	},
+	ConflictTest{[]string{"vlan"}, nil},
+}
+
+func TestFederationConflicts(t *testing.T) {
+	f := testfederation(t)
+
+	for i, test := range conflicttests {
+		if got := f.Conflicts("sys", test.attrs...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: got %v want %v", i, got, test.want)
+		}
+	}
+}
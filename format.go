@@ -0,0 +1,155 @@
+package ndb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format a tuple as attr=val, quoting the value if needed.
+func formattuple(tuple Tuple) string {
+	if strings.ContainsAny(tuple.Val, " \t\r#=") {
+		return tuple.Attr + `="` + tuple.Val + `"`
+	}
+
+	return tuple.Attr + "=" + tuple.Val
+}
+
+// Format a record as a single line of ndb text, with trailing newline.
+func formatrecord(rec Record) string {
+	var s []string
+
+	for _, tuple := range rec {
+		s = append(s, formattuple(tuple))
+	}
+
+	return strings.Join(s, " ") + "\n"
+}
+
+// Return the record as a single line of ndb text,
+// with the Redact policy applied.
+func (r Record) String() string {
+	return strings.TrimSuffix(formatrecord(Redact.Apply(r)), "\n")
+}
+
+// Columns WriteTo fills before wrapping a record onto a continuation
+// line, as Plan 9 databases are usually laid out.
+const wrapwidth = 72
+
+// Return an error if tuple can't be written as ndb text that parses
+// back to the same tuple: the attribute must be a non-empty word, and
+// a value that must be quoted can't contain a quote.
+func checktuple(tuple Tuple) error {
+	if tuple.Attr == "" || strings.ContainsAny(tuple.Attr, " \t\r\n#=\"") {
+		return fmt.Errorf("write: can't write attribute %q", tuple.Attr)
+	}
+
+	quoted := strings.ContainsAny(tuple.Val, " \t\r#=")
+	if strings.Contains(tuple.Val, "\n") || strings.HasPrefix(tuple.Val, `"`) || (quoted && strings.Contains(tuple.Val, `"`)) {
+		return fmt.Errorf("write: can't write value %q of %s=", tuple.Val, tuple.Attr)
+	}
+
+	return nil
+}
+
+// Return an error if any tuple of rec can't be written, as checktuple.
+func checkrecord(rec Record) error {
+	for _, tuple := range rec {
+		if err := checktuple(tuple); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write the record to w as ndb text, wrapping long records onto
+// continuation lines indented with a tab. Values are quoted where
+// needed. Unlike String, the Redact policy is not applied. Returns an
+// error without writing anything if a tuple can't be written, such as
+// a value with both a space and a quote.
+func (r Record) WriteTo(w io.Writer) (int64, error) {
+	return r.write(w, wrapwidth)
+}
+
+// Write the record to w as WriteTo does, but on one line however long
+// it is.
+func (r Record) WriteLine(w io.Writer) (int64, error) {
+	return r.write(w, 0)
+}
+
+// Write the record, wrapping lines at width columns unless it is 0.
+func (r Record) write(w io.Writer, width int) (int64, error) {
+	var buf strings.Builder
+
+	col := 0
+	if err := checkrecord(r); err != nil {
+		return 0, err
+	}
+
+	for i, tuple := range r {
+		s := formattuple(tuple)
+
+		switch {
+		case i == 0:
+		case width > 0 && col+1+len(s) > width:
+			buf.WriteString("\n\t")
+			col = 8
+		default:
+			buf.WriteByte(' ')
+			col++
+		}
+
+		buf.WriteString(s)
+		col += len(s)
+	}
+
+	if len(r) == 0 {
+		return 0, nil
+	}
+
+	buf.WriteByte('\n')
+
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err
+}
+
+// Write the records to w as ndb text, as Record.WriteTo does. Stops at
+// the first record that can't be written.
+func (rs RecordSet) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	for _, rec := range rs {
+		n, err := rec.WriteTo(w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Write the records of every file of the database to w as one ndb
+// file, as Record.WriteTo does, in search order. Records are written
+// as loaded, without the tuples they inherit from templates, and
+// database= records are left out, since the output holds every file.
+func (n *Ndb) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	for db := n; db != nil; db = db.next {
+		for _, rec := range db.recs() {
+			if rec.find("database") != nil {
+				continue
+			}
+
+			m, err := rec.WriteTo(w)
+			total += m
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,124 @@
+package ndb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordString(t *testing.T) {
+	rec := Record{Tuple{"sys", "fir"}, Tuple{"ip", "10.0.0.9"}, Tuple{"info", "rack 3"}, Tuple{"auth", ""}}
+	want := `sys=fir ip=10.0.0.9 info="rack 3" auth=`
+
+	if s := rec.String(); s != want {
+		t.Errorf("expected %q got %q", want, s)
+	}
+
+	tuples, err := parsetuples(rec.String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range rec {
+		if tuples[i] != rec[i] {
+			t.Errorf("tuple %d: expected %+v got %+v", i, rec[i], tuples[i])
+		}
+	}
+}
+
+type WriteTest struct {
+	rec  Record
+	text string
+	ok   bool
+}
+
+var writetests = []WriteTest{
+	WriteTest{Record{{"sys", "fir"}, {"ip", "10.0.0.9"}, {"info", "rack 3"}, {"auth", ""}}, "sys=fir ip=10.0.0.9 info=\"rack 3\" auth=\n", true},
+	WriteTest{Record{{"sys", "a"}, {"txt", strings.Repeat("x", 70)}, {"ip", "10.0.0.1"}, {"dom", "a.example.com"}}, "sys=a\n\ttxt=" + strings.Repeat("x", 70) + "\n\tip=10.0.0.1 dom=a.example.com\n", true},
+	WriteTest{Record{{"sys", `a"b`}}, "sys=a\"b\n", true},
+	WriteTest{Record{{"info", `say "hi"`}}, "", false},
+	WriteTest{Record{{"info", `"quoted`}}, "", false},
+	WriteTest{Record{{"info", "two\nlines"}}, "", false},
+	WriteTest{Record{{"", "a"}}, "", false},
+	WriteTest{Record{{"a b", "c"}}, "", false},
+	WriteTest{Record{}, "", true},
+}
+
+func TestRecordWriteTo(t *testing.T) {
+	for i, test := range writetests {
+		var buf bytes.Buffer
+
+		n, err := test.rec.WriteTo(&buf)
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: got error %v", i, err)
+			continue
+		}
+
+		if buf.String() != test.text || n != int64(buf.Len()) {
+			t.Errorf("test %d: wrote %d bytes %q, want %q", i, n, buf.String(), test.text)
+			continue
+		}
+
+		if !test.ok || len(test.rec) == 0 {
+			continue
+		}
+
+		db, err := Parse(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if recs := db.Search(test.rec[0].Attr, test.rec[0].Val); len(recs) != 1 || !reflect.DeepEqual(recs[0], test.rec) {
+			t.Errorf("test %d: read back %v", i, recs)
+		}
+	}
+}
+
+func TestRecordWriteLine(t *testing.T) {
+	rec := Record{{"sys", "a"}, {"txt", strings.Repeat("x", 70)}, {"info", "cr\r"}}
+	want := "sys=a txt=" + strings.Repeat("x", 70) + " info=\"cr\r\"\n"
+
+	var buf bytes.Buffer
+	if _, err := rec.WriteLine(&buf); err != nil || buf.String() != want {
+		t.Errorf("wrote %q, %v", buf.String(), err)
+	}
+
+	if _, err := (Record{{"info", `say "hi"`}}).WriteLine(&buf); err == nil {
+		t.Errorf("wrote a tuple that can't be written")
+	}
+}
+
+func TestNdbWriteTo(t *testing.T) {
+	db, err := Open(testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := db.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if written.Search("database", "") != nil {
+		t.Errorf("database= record written")
+	}
+
+	var want RecordSet
+	db.Walk(func(rec Record, pos Pos) bool {
+		if rec.find("database") == nil {
+			want = append(want, rec)
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(written.FileRecords(""), want) {
+		t.Errorf("records changed writing the database")
+	}
+}
@@ -0,0 +1,44 @@
+//go:build go1.16
+// +build go1.16
+
+package ndb
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Open an NDB database file from fsys, such as an embed.FS holding a
+// default database shipped inside a program. Files named by the
+// database= record, and files added later by Cat, are read from fsys
+// too. Functions that write to the files, such as Register, fail.
+func OpenFS(fsys fs.FS, name string, opts ...Option) (*Ndb, error) {
+	if name == "" {
+		return nil, fmt.Errorf("open: no file name")
+	}
+
+	return Open(name, append(opts, WithFS(fsys))...)
+}
+
+// Read database files from fsys rather than the operating system, as
+// OpenFS does. Give it to ReadSnapshot to check a snapshot of a
+// database opened by OpenFS against the files in fsys.
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) {
+		o.files = fsfiles{fsys}
+	}
+}
+
+// The files in an fs.FS.
+type fsfiles struct {
+	fsys fs.FS
+}
+
+func (f fsfiles) Open(name string) (dbfile, error) {
+	return f.fsys.Open(name)
+}
+
+func (f fsfiles) Stat(name string) (os.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
@@ -0,0 +1,128 @@
+//go:build go1.16
+// +build go1.16
+
+package ndb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestOpenFS(t *testing.T) {
+	db, err := OpenFS(os.DirFS("."), testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disk, err := Open(testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(db.Files(), disk.Files()) {
+		t.Errorf("files %q, want %q", db.Files(), disk.Files())
+	}
+
+	if got, want := db.Search("dom", "A.ROOT-SERVERS.NET"), disk.Search("dom", "A.ROOT-SERVERS.NET"); got == nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	if changed, err := db.Changed(); err != nil || changed {
+		t.Errorf("Changed = %v, %v", changed, err)
+	}
+}
+
+func TestOpenFSMap(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ndb/local":  {Data: []byte("database=\n\tfile=ndb/local\n\tfile=ndb/common\n\nsys=a ip=10.0.0.1\n")},
+		"ndb/common": {Data: []byte("sys=b ip=10.0.0.2\n")},
+	}
+
+	db, err := OpenFS(fsys, "ndb/local", Lazy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "b"); len(recs) != 1 {
+		t.Errorf("sys=b got %v", recs)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := OpenFS(fsys, "ndb/missing"); err == nil {
+		t.Errorf("no error opening a missing file")
+	}
+
+	// a snapshot is checked against the files in fsys
+	var buf bytes.Buffer
+	if err := db.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), WithFS(fsys)); err != nil {
+		t.Errorf("snapshot: %v", err)
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader(buf.Bytes())); err != ErrStaleSnapshot {
+		t.Errorf("snapshot read outside fsys: %v", err)
+	}
+}
+
+func TestOpenFSWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// a file on disk with the same name as the one in fsys
+	text := "sys=disk\n"
+	if err := os.Mkdir("ndb", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join("ndb", "local"), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenFS(fstest.MapFS{"ndb/local": {Data: []byte("sys=a\n")}}, "ndb/local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddRecord(Record{{"sys", "new"}}); err == nil {
+		t.Errorf("AddRecord succeeded")
+	}
+
+	if err := db.WriteFile(); err == nil {
+		t.Errorf("WriteFile succeeded")
+	}
+
+	if err := db.MarkSeen(time.Now(), func(Record) bool { return true }); err == nil {
+		t.Errorf("MarkSeen succeeded")
+	}
+
+	if _, err := db.Watch(WatchAuto, 0, 0); err == nil {
+		t.Errorf("Watch succeeded")
+	}
+
+	if data, _ := ioutil.ReadFile(filepath.Join("ndb", "local")); string(data) != text {
+		t.Errorf("file on disk written: %q", data)
+	}
+}
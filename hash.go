@@ -0,0 +1,379 @@
+package ndb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// Plan 9 hash files, made by ndbmkhash(8) for one attribute of one
+// database file and named file.attr, index the records by value so
+// ndbsearch needn't read the whole file. A hash file begins with the
+// database file's modification time and the number of slots, each
+// four bytes little-endian, followed by the slots and then the chains.
+// Pointers are three bytes little-endian: a byte offset in the
+// database file where a record with the value may begin, hashnap for
+// none, or with hashchain set, the offset past the header of a chain
+// entry of two pointers, to follow in turn.
+const (
+	hashhlen  = 8
+	hashplen  = 3
+	hashnap   = 0xffffff
+	hashchain = 1 << 23
+)
+
+// A hash file read into memory.
+type hashfile struct {
+	hlen  uint32
+	table []byte // Slots and chains, without the header
+}
+
+// Hash a value as ndbhash does.
+func ndbhash(val string, hlen uint32) uint32 {
+	var h uint32
+
+	for i := 0; i < len(val); i++ {
+		h = h*13 + uint32(val[i]) - 'a'
+	}
+
+	return h % hlen
+}
+
+// Read a pointer from b.
+func getp(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// Parse the hash file data for the database file modified at mtime.
+// Returns nil if it is malformed or for another version of the file.
+func parsehash(data []byte, mtime int64) *hashfile {
+	if len(data) < hashhlen {
+		return nil
+	}
+
+	h := &hashfile{
+		hlen:  binary.LittleEndian.Uint32(data[4:]),
+		table: data[hashhlen:],
+	}
+
+	if binary.LittleEndian.Uint32(data) != uint32(mtime) || h.hlen == 0 || uint64(len(h.table)) < uint64(h.hlen)*hashplen {
+		return nil
+	}
+
+	return h
+}
+
+// Return the offsets in the database file where records with the value
+// val may begin, from the slot val hashes to and its chain.
+func (h *hashfile) lookup(val string) []int {
+	var offs []int
+
+	ptrs := []uint32{getp(h.table[ndbhash(val, h.hlen)*hashplen:])}
+
+	// a chain can't have more entries than fit in the file
+	for steps := 0; len(ptrs) > 0 && steps <= len(h.table)/hashplen; steps++ {
+		p := ptrs[0]
+		ptrs = ptrs[1:]
+
+		switch {
+		case p == hashnap:
+		case p&hashchain == 0:
+			offs = append(offs, int(p))
+		default:
+			p &^= hashchain
+			if int(p)+2*hashplen > len(h.table) {
+				return offs
+			}
+			ptrs = append([]uint32{getp(h.table[p:]), getp(h.table[p+hashplen:])}, ptrs...)
+		}
+	}
+
+	return offs
+}
+
+// Return the records of db that may have attr=val according to its
+// hash file for attr, in file order, or false if there is no usable
+// hash file. One is only used if Plan 9 splits the file into the same
+// records as this parser, so the results are those of a scan. Values
+// a normalizer compares (see WithNormalize) aren't hashed the way they
+// would be matched, so then none is used, nor for a search for any
+// value, or after records are edited in memory. Hash files are read
+// when first needed and again after Reopen.
+func (db *Ndb) hashed(attr, val string) (RecordSet, bool) {
+	if val == "" || db.filename == "" || db.data == nil || db.opts == nil || db.opts.normalizers[attr] != nil || len(db.edits) > 0 {
+		return nil, false
+	}
+
+	db.hashmu.Lock()
+	defer db.hashmu.Unlock()
+
+	h, ok := db.hashes[attr]
+	if !ok {
+		h = db.readhash(attr)
+		if db.hashes == nil {
+			db.hashes = make(map[string]*hashfile)
+		}
+		db.hashes[attr] = h
+	}
+
+	if h == nil {
+		return nil, false
+	}
+
+	if db.hashlines == nil {
+		db.hashlines = make(map[int]int)
+		if data, err := ioutil.ReadAll(io.NewSectionReader(db.data, 0, db.data.Size())); err == nil {
+			if lines, err := hashlines(data); err == nil {
+				db.hashlines = lines
+			}
+		}
+	}
+
+	if len(db.hashlines) == 0 {
+		return nil, false
+	}
+
+	seen := make(map[int]bool)
+	var idx []int
+	for _, off := range h.lookup(val) {
+		// an offset that isn't where a record begins means the
+		// hash file and this parser disagree, so scan instead
+		line, ok := db.hashlines[off]
+		if !ok {
+			return nil, false
+		}
+
+		// the record may have been left out by the options
+		i := sort.SearchInts(db.lines, line)
+		if i < len(db.lines) && db.lines[i] == line && !seen[i] {
+			seen[i] = true
+			idx = append(idx, i)
+		}
+	}
+	sort.Ints(idx)
+
+	recs := RecordSet{}
+	for _, i := range idx {
+		recs = append(recs, db.records[i])
+	}
+
+	return recs, true
+}
+
+// Read db's hash file for attr, or return nil if it has none that is
+// up to date.
+func (db *Ndb) readhash(attr string) *hashfile {
+	f, err := db.opts.open(db.filename + "." + attr)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+
+	return parsehash(data, db.mtime.Unix())
+}
+
+// Map the offset ndbmkhash gives each record of data to the line this
+// parser says it begins on. Returns an error if the two split data
+// into records differently, as they do at a last line without a
+// newline, when a hash file would not find every record Search does.
+func hashlines(data []byte) (map[int]int, error) {
+	recs, offs := plan9records(data)
+	lines := make(map[int]int)
+
+	d := NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		rec, err := d.Next()
+		if err == io.EOF && i == len(recs) {
+			return lines, nil
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		} else if err == io.EOF || i == len(recs) || !sametuples(rec, recs[i]) {
+			return nil, fmt.Errorf("records are split differently by Plan 9")
+		}
+
+		lines[offs[i]] = d.line
+	}
+}
+
+// Whether a and b have the same tuples in the same order.
+func sametuples(a, b Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Write a Plan 9 hash file for attr beside each file of the database,
+// as ndbmkhash does, so Search here and ndbsearch on Plan 9 find
+// records by attr without reading every record. Each file is read as
+// it is now, under its lock, rather than as loaded. A hash file is
+// only used while its database file is unchanged, so write them again
+// after editing the files. Fails for files of 8MB or more, which the
+// format can't point into, and for files Plan 9 splits into records
+// differently than this parser does, such as one whose last line has
+// no newline, whose hash files would leave out records Search finds.
+func (n *Ndb) WriteHash(attr string) error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	for db := n; db != nil; db = db.next {
+		if db.filename == "" {
+			continue
+		}
+
+		if err := writehash(db.filename, attr); err != nil {
+			return fmt.Errorf("hash: %s", err)
+		}
+
+		db.hashmu.Lock()
+		delete(db.hashes, attr)
+		db.hashmu.Unlock()
+	}
+
+	return nil
+}
+
+// Write the hash file for attr of the database file fname.
+func writehash(fname, attr string) error {
+	lock, err := lockdb(fname)
+	if err != nil {
+		return err
+	}
+	defer unlockdb(lock)
+
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+
+	// a hash file Search can't use would still mislead Plan 9
+	if _, err := hashlines(data); err != nil {
+		return fmt.Errorf("%s: %s", fname, err)
+	}
+
+	hash, err := hashdata(data, fi.ModTime(), attr)
+	if err != nil {
+		return fmt.Errorf("%s: %s", fname, err)
+	}
+
+	return writefile(fname+"."+attr, hash)
+}
+
+// Return the hash file for attr of the database file data, modified
+// at mtime.
+func hashdata(data []byte, mtime time.Time, attr string) ([]byte, error) {
+	if len(data) >= hashchain {
+		return nil, fmt.Errorf("too large for a hash file")
+	}
+
+	recs, offs := plan9records(data)
+
+	var vals []string
+	var dboffs []uint32
+	for i, rec := range recs {
+		for _, tuple := range rec.find(attr) {
+			vals = append(vals, tuple.Val)
+			dboffs = append(dboffs, uint32(offs[i]))
+		}
+	}
+
+	// as ndbmkhash, twice as many slots as values
+	hlen := uint32(2*len(vals) + 1)
+	table := bytes.Repeat([]byte{0xff}, int(hlen)*hashplen)
+
+	putp := func(p, at uint32) {
+		table[at], table[at+1], table[at+2] = byte(p), byte(p>>8), byte(p>>16)
+	}
+
+	for i, val := range vals {
+		// add to the end of the value's chain
+		last := ndbhash(val, hlen) * hashplen
+		ptr := getp(table[last:])
+		if ptr == hashnap {
+			putp(dboffs[i], last)
+			continue
+		}
+
+		for ptr&hashchain != 0 {
+			last = ptr&^hashchain + hashplen
+			ptr = getp(table[last:])
+		}
+
+		next := uint32(len(table))
+		if next >= hashchain {
+			return nil, fmt.Errorf("too many values of %s for a hash file", attr)
+		}
+		table = append(table, make([]byte, 2*hashplen)...)
+
+		putp(next|hashchain, last)
+		putp(ptr, next)
+		putp(dboffs[i], next+hashplen)
+	}
+
+	hdr := make([]byte, hashhlen)
+	binary.LittleEndian.PutUint32(hdr, uint32(mtime.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:], hlen)
+
+	return append(hdr, table...), nil
+}
+
+// Split data into records as Plan 9's ndbparse does, with the offset
+// where ndbmkhash says each begins: where reading it starts, after the
+// lines read with the record before. Unlike the parser here, a last
+// line without a newline is ignored.
+func plan9records(data []byte) ([]Record, []int) {
+	var recs []Record
+	var offs []int
+
+	var rec Record
+	start := 0
+
+	for off := 0; off < len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			break
+		}
+
+		line := string(data[off : off+end+1])
+		if c := line[0]; rec != nil && c != ' ' && c != '\t' && c != '\r' && c != '#' {
+			recs = append(recs, rec)
+			offs = append(offs, start)
+			rec, start = nil, off
+		}
+
+		tuples, _ := parseline(line)
+		rec = append(rec, tuples...)
+
+		off += end + 1
+	}
+
+	if rec != nil {
+		recs = append(recs, rec)
+		offs = append(offs, start)
+	}
+
+	return recs, offs
+}
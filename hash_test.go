@@ -0,0 +1,213 @@
+package ndb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type WriteHashTest struct {
+	text  string
+	hlen  uint32
+	table []byte
+}
+
+var writehashtests = []WriteHashTest{
+	// a blank line is read with the record after it
+	WriteHashTest{"# hosts\nsys=a\n\nsys=b ip=1\n", 5, []byte{
+		0, 0, 0, 14, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}},
+	// the values a share slot 0, chained in file order
+	WriteHashTest{"sys=a\nsys=f\n# f\n\tsys=a\nsys=a\n", 9, []byte{
+		27, 0, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 6, 0, 0,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0, 0, 0, 33, 0, 0x80,
+		6, 0, 0, 23, 0, 0,
+	}},
+}
+
+func TestWriteHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+
+	for i, test := range writehashtests {
+		if err := ioutil.WriteFile(fname, []byte(test.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.WriteHash("sys"); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadFile(fname + ".sys")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := os.Stat(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if binary.LittleEndian.Uint32(data) != uint32(fi.ModTime().Unix()) || binary.LittleEndian.Uint32(data[4:]) != test.hlen {
+			t.Errorf("test %d: bad header %x", i, data[:hashhlen])
+		}
+
+		if !bytes.Equal(data[hashhlen:], test.table) {
+			t.Errorf("test %d: got table %v want %v", i, data[hashhlen:], test.table)
+		}
+
+		want := RecordSet{}
+		for _, rec := range db.recs() {
+			for _, tuple := range rec.find("sys") {
+				if tuple.Val == "a" {
+					want = append(want, rec)
+					break
+				}
+			}
+		}
+
+		if got, ok := db.hashed("sys", "a"); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: hash file gave %v want %v", i, got, want)
+		}
+	}
+}
+
+func TestHashSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	text := "# hosts\n\nsys=anna ip=10.0.0.1\n# comment\nsys=bob\n\tip=10.0.0.2 ip=10.0.0.9\n\nsys=carl ip=10.0.0.2\nsys=dora ip=10.0.0.4\nsys=anna ip=10.0.0.5\nsys=eve\n\n\tip=10.0.0.7\n"
+	if err := ioutil.WriteFile(fname, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scan, err := Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attr := range []string{"sys", "ip"} {
+		if err := db.WriteHash(attr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queries := []Tuple{{"sys", "anna"}, {"sys", "bob"}, {"ip", "10.0.0.2"}, {"ip", "10.0.0.9"}, {"ip", "10.0.0.7"}, {"sys", "nobody"}, {"sys", ""}, {"dom", "x"}}
+	for _, q := range queries {
+		if got, want := db.Search(q.Attr, q.Val), scan.Search(q.Attr, q.Val); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s=%s: got %v want %v", q.Attr, q.Val, got, want)
+		}
+	}
+
+	// records whose values share a slot are candidates too
+	if recs, ok := db.hashed("sys", "anna"); !ok || len(recs) < 2 {
+		t.Errorf("hash file not used: %v", recs)
+	}
+
+	// a hash file for another version of the database is ignored
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(fname, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := db.hashed("sys", "anna"); ok {
+		t.Errorf("stale hash file used")
+	}
+
+	if recs := db.Search("sys", "anna"); len(recs) != 2 {
+		t.Errorf("got %v", recs)
+	}
+
+	ro, err := Open(fname, ReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ro.WriteHash("sys"); err != ErrReadOnly {
+		t.Errorf("read-only WriteHash: %v", err)
+	}
+}
+
+func TestHashMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+
+	// Plan 9 ignores the last line, without a newline
+	texts := []string{"sys=a ip=1.1.1.1\nsys=b ip=1.1.1.1"}
+
+	for _, text := range texts {
+		if err := ioutil.WriteFile(fname, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := os.Stat(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, attr := range []string{"ip", "sys"} {
+			hash, err := hashdata([]byte(text), fi.ModTime(), attr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(fname+"."+attr, hash, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		db, err := Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.WriteHash("sys"); err == nil {
+			t.Errorf("%q: WriteHash wrote a hash file", text)
+		}
+
+		if _, ok := db.hashed("ip", "1.1.1.1"); ok {
+			t.Errorf("%q: hash file used", text)
+		}
+
+		if recs := db.Search("sys", "b"); len(recs) != 1 {
+			t.Errorf("%q: got %v", text, recs)
+		}
+
+		if recs := db.Search("ip", "1.1.1.1"); len(recs) == 0 {
+			t.Errorf("%q: no ip=1.1.1.1", text)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package diag reports the errors of the ndb commands in the format
+// their -e flag chooses.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mischief/ndb"
+	"os"
+)
+
+// Reports a command's errors to standard error.
+type Reporter struct {
+	Format *string // The -e flag: text, or json for ndb.ErrorDiagnostic objects
+	Prefix string  // Printed before text errors, such as the command's name
+	Status int     // Exit status of Fatal
+
+	atexit []func()
+}
+
+// Print err in the -e format.
+func (r *Reporter) Print(err error) {
+	if r.Format != nil && *r.Format == "json" {
+		json.NewEncoder(os.Stderr).Encode(ndb.ErrorDiagnostic(err))
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s%s\n", r.Prefix, err)
+}
+
+// Run f before Exit exits, such as to wait for a pager to show what
+// was written to it.
+func (r *Reporter) AtExit(f func()) {
+	r.atexit = append(r.atexit, f)
+}
+
+// Run the functions given to AtExit, last first, and exit with status.
+func (r *Reporter) Exit(status int) {
+	for i := len(r.atexit) - 1; i >= 0; i-- {
+		r.atexit[i]()
+	}
+
+	os.Exit(status)
+}
+
+// Print err in the -e format and Exit with r.Status.
+func (r *Reporter) Fatal(err error) {
+	r.Print(err)
+	r.Exit(r.Status)
+}
@@ -0,0 +1,57 @@
+// Package pager pages the output of the ndb commands.
+package pager
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Send standard output through $PAGER, or less, if it is a terminal.
+// Returns a function to call before exiting, which waits for the
+// pager to finish.
+func Start() func() {
+	fi, err := os.Stdout.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+		if os.Getenv("LESS") == "" {
+			// quit if it fits on one screen, keep colors, don't clear
+			os.Setenv("LESS", "FRX")
+		}
+	}
+
+	path, err := exec.LookPath(pager)
+	if err != nil {
+		return func() {}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+
+	cmd := exec.Command(path)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return func() {}
+	}
+
+	r.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+
+	return func() {
+		w.Close()
+		cmd.Wait()
+		os.Stdout = stdout
+	}
+}
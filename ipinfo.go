@@ -0,0 +1,307 @@
+package ndb
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An ipnet record and the network it describes.
+type ipnet struct {
+	net    *net.IPNet
+	record Record
+}
+
+// Prefix length of the network.
+func (i ipnet) prefix() int {
+	ones, _ := i.net.Mask.Size()
+	return ones
+}
+
+// An ipnet record and the host records on its network.
+type Network struct {
+	Net    *net.IPNet // Network from the ip= and ipmask= tuples
+	Record Record     // The ipnet record itself
+	Hosts  RecordSet  // Records with an ip= inside Net, in search order
+}
+
+// Return every ipnet record in the database, in search order, with the
+// records whose ip addresses fall inside its network. A host on nested
+// networks appears under each of them. ipnet records are not counted
+// as hosts.
+func (n *Ndb) Networks() []Network {
+	var nets []Network
+
+	for _, in := range n.allipnets() {
+		nets = append(nets, Network{Net: in.net, Record: in.record})
+	}
+
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			if record.find("ipnet") != nil {
+				continue
+			}
+
+			for i := range nets {
+				if nets[i].contains(record) {
+					nets[i].Hosts = append(nets[i].Hosts, record)
+				}
+			}
+		}
+	}
+
+	return nets
+}
+
+// Whether any of the record's ip addresses are on the network.
+func (nw *Network) contains(record Record) bool {
+	for _, tuple := range record.find("ip") {
+		if ip := net.ParseIP(tuple.Val); ip != nil && nw.Net.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Look up the entry matching attr=val and return the values of rattrs
+// for it, in the manner of ndbipinfo(2).
+//
+// Each attribute is taken from the entry itself if present. Otherwise
+// it is taken from the ipnet records whose networks contain the entry's
+// ip address, trying the most specific (longest prefix) network first
+// and falling outward until one has it. All tuples for an attribute
+// come from the same record.
+//
+// If attr is "ip" the value is used as the address even when no entry
+// matches it. Returns no tuples (nil) if nothing was found.
+//
+// Results are cached if SetIpinfoCache has been called, until the
+// first record they came from expires.
+func (n *Ndb) Ipinfo(attr, val string, rattrs ...string) Record {
+	statIpinfos.Add(1)
+
+	if n.ipcache == nil {
+		result, _ := n.ipinfo(attr, val, rattrs, nil)
+		return result
+	}
+
+	key := ipcachekey(attr, val, rattrs)
+
+	result, ok := n.ipcache.get(key, time.Now())
+	if ok {
+		statCacheHits.Add(1)
+	} else {
+		statCacheMisses.Add(1)
+		var expires time.Time
+		result, expires = n.ipinfo(attr, val, rattrs, nil)
+		n.ipcache.put(key, result, expires)
+	}
+
+	// callers may modify the result, unless they promised not to
+	if result == nil || (n.opts != nil && n.opts.readonly) {
+		return result
+	}
+
+	return append(Record(nil), result...)
+}
+
+// Look up rattrs as Ipinfo does. If netfind is not nil, it finds the
+// tuples for an attribute in a network record instead of Record.find.
+// Also returns when the first of the records used expires, or the zero
+// time if none does, after which the result may be wrong.
+func (n *Ndb) ipinfo(attr, val string, rattrs []string, netfind func(nw Record, entry Record, attr string) []Tuple) (Record, time.Time) {
+	var entry Record
+	var ip net.IP
+	var expires time.Time
+
+	uses := func(rec Record) {
+		if t, ok := rec.Expires(); ok && (expires.IsZero() || t.Before(expires)) {
+			expires = t
+		}
+	}
+
+	if recs := n.Search(attr, val); len(recs) > 0 {
+		entry = recs[0]
+		uses(entry)
+	}
+
+	if attr == "ip" {
+		ip = net.ParseIP(val)
+	}
+
+	if ip == nil {
+		for _, tuple := range entry {
+			if tuple.Attr == "ip" {
+				if ip = net.ParseIP(tuple.Val); ip != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if entry == nil && ip == nil {
+		return nil, expires
+	}
+
+	var nets []ipnet
+	if ip != nil {
+		nets = n.ipnets(ip)
+	}
+
+	for _, nw := range nets {
+		uses(nw.record)
+	}
+
+	var result Record
+
+	for _, rattr := range rattrs {
+		found := entry.find(rattr)
+
+		for i := 0; found == nil && i < len(nets); i++ {
+			if netfind != nil {
+				found = netfind(nets[i].record, entry, rattr)
+			} else {
+				found = nets[i].record.find(rattr)
+			}
+		}
+
+		result = append(result, found...)
+	}
+
+	return result, expires
+}
+
+// Return all tuples in the record with the given attribute.
+func (r Record) find(attr string) []Tuple {
+	var tuples []Tuple
+
+	for _, tuple := range r {
+		if tuple.Attr == attr {
+			tuples = append(tuples, tuple)
+		}
+	}
+
+	return tuples
+}
+
+// Return the ipnet records whose networks contain ip,
+// most specific first.
+func (n *Ndb) ipnets(ip net.IP) []ipnet {
+	var nets []ipnet
+
+	for _, in := range n.allipnets() {
+		if in.net.Contains(ip) {
+			nets = append(nets, in)
+		}
+	}
+
+	sort.SliceStable(nets, func(i, j int) bool {
+		return nets[i].prefix() > nets[j].prefix()
+	})
+
+	return nets
+}
+
+// Return every well-formed, unexpired ipnet record in the database,
+// in file order.
+func (n *Ndb) allipnets() []ipnet {
+	var nets []ipnet
+	now := time.Now()
+
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			if record.find("ipnet") == nil || record.Expired(now) {
+				continue
+			}
+
+			if in, err := parseipnet(record); err == nil {
+				nets = append(nets, in)
+			}
+		}
+	}
+
+	return nets
+}
+
+// Build the network for an ipnet record from its ip= and ipmask= tuples.
+func parseipnet(record Record) (ipnet, error) {
+	var ip net.IP
+	var mask net.IPMask
+	var err error
+
+	ips := record.find("ip")
+	if len(ips) == 0 {
+		return ipnet{}, fmt.Errorf("ipnet: no ip")
+	}
+
+	if ip = net.ParseIP(ips[0].Val); ip == nil {
+		return ipnet{}, fmt.Errorf("ipnet: bad ip %q", ips[0].Val)
+	}
+
+	if masks := record.find("ipmask"); len(masks) > 0 {
+		if mask, err = parseipmask(masks[0].Val, ip); err != nil {
+			return ipnet{}, err
+		}
+	} else {
+		mask = classmask(ip)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil && len(mask) == net.IPv4len {
+		ip = ip4
+	}
+
+	return ipnet{&net.IPNet{IP: ip.Mask(mask), Mask: mask}, record}, nil
+}
+
+// Parse an ipmask value, either a dotted quad (255.255.255.0)
+// or a prefix length (/24).
+func parseipmask(s string, ip net.IP) (net.IPMask, error) {
+	bits := net.IPv6len * 8
+	if ip.To4() != nil {
+		bits = net.IPv4len * 8
+	}
+
+	if strings.HasPrefix(s, "/") {
+		ones, err := strconv.Atoi(s[1:])
+		if err != nil || ones < 0 || ones > bits {
+			return nil, fmt.Errorf("ipnet: bad ipmask %q", s)
+		}
+		return net.CIDRMask(ones, bits), nil
+	}
+
+	m := net.ParseIP(s)
+	if m == nil {
+		return nil, fmt.Errorf("ipnet: bad ipmask %q", s)
+	}
+
+	if bits == net.IPv4len*8 {
+		if m = m.To4(); m == nil {
+			return nil, fmt.Errorf("ipnet: bad ipmask %q", s)
+		}
+	}
+
+	mask := net.IPMask(m)
+	if _, size := mask.Size(); size == 0 {
+		return nil, fmt.Errorf("ipnet: noncontiguous ipmask %q", s)
+	}
+
+	return mask, nil
+}
+
+// Return the classful mask for an IPv4 address, or a /64 for IPv6.
+func classmask(ip net.IP) net.IPMask {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return net.CIDRMask(64, net.IPv6len*8)
+	}
+
+	if mask := ip4.DefaultMask(); mask != nil {
+		return mask
+	}
+
+	return net.CIDRMask(32, net.IPv4len*8)
+}
@@ -0,0 +1,131 @@
+package ndb
+
+import (
+	"testing"
+)
+
+const (
+	testipnet = "testndb/ipnet"
+)
+
+type IpinfoTest struct {
+	attr, val string
+	rattrs    []string
+
+	tuples []Tuple
+}
+
+var (
+	ipinfotests = []IpinfoTest{
+		// subnet gateway, network-wide fs and auth
+		IpinfoTest{"sys", "anna", []string{"ipgw", "fs", "auth"},
+			[]Tuple{Tuple{"ipgw", "135.104.117.1"}, Tuple{"fs", "bootes.research.bell-labs.com"}, Tuple{"auth", "p9auth.research.bell-labs.com"}}},
+		// all dns tuples come from the one record
+		IpinfoTest{"sys", "bob", []string{"ipgw", "dns"},
+			[]Tuple{Tuple{"ipgw", "135.104.51.1"}, Tuple{"dns", "135.104.10.1"}, Tuple{"dns", "135.104.10.2"}}},
+		// three levels deep
+		IpinfoTest{"sys", "lab1", []string{"ipgw", "dns", "fs"},
+			[]Tuple{Tuple{"ipgw", "135.104.51.129"}, Tuple{"dns", "135.104.51.130"}, Tuple{"fs", "bootes.research.bell-labs.com"}}},
+		// entry's own tuples win
+		IpinfoTest{"sys", "carol", []string{"fs", "ipgw"},
+			[]Tuple{Tuple{"fs", "carolfs"}, Tuple{"ipgw", "135.104.1.1"}}},
+		// address with no entry
+		IpinfoTest{"ip", "135.104.51.77", []string{"ipgw"},
+			[]Tuple{Tuple{"ipgw", "135.104.51.1"}}},
+		// entry with no ip
+		IpinfoTest{"sys", "nowhere", []string{"ipgw"}, nil},
+		IpinfoTest{"sys", "nobody", []string{"ipgw"}, nil},
+	}
+)
+
+func TestIpinfo(t *testing.T) {
+	db, err := Open(testipnet)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tno, test := range ipinfotests {
+		res := db.Ipinfo(test.attr, test.val, test.rattrs...)
+
+		t.Logf("%s=%s %v -> %+v", test.attr, test.val, test.rattrs, res)
+
+		if len(res) != len(test.tuples) {
+			t.Errorf("test %d: expected %d tuples got %d", tno, len(test.tuples), len(res))
+			continue
+		}
+
+		for i, tuple := range test.tuples {
+			if res[i] != tuple {
+				t.Errorf("test %d: tuple %d: expected %+v got %+v", tno, i, tuple, res[i])
+			}
+		}
+	}
+}
+
+func TestParseIpmask(t *testing.T) {
+	good := map[string]int{"255.255.255.0": 24, "/20": 20, "255.0.0.0": 8}
+
+	for s, ones := range good {
+		rec := Record{Tuple{"ipnet", "x"}, Tuple{"ip", "10.0.0.0"}, Tuple{"ipmask", s}}
+		in, err := parseipnet(rec)
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if in.prefix() != ones {
+			t.Errorf("%q: expected /%d got /%d", s, ones, in.prefix())
+		}
+	}
+
+	for _, s := range []string{"255.0.255.0", "/33", "bogus"} {
+		rec := Record{Tuple{"ipnet", "x"}, Tuple{"ip", "10.0.0.0"}, Tuple{"ipmask", s}}
+		if _, err := parseipnet(rec); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	db, err := Open(testipnet)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nets := db.Networks()
+
+	want := map[string][]string{
+		"135.104.0.0/16":    []string{"anna", "bob", "lab1", "carol"},
+		"135.104.117.0/24":  []string{"anna"},
+		"135.104.51.0/24":   []string{"bob", "lab1"},
+		"135.104.51.128/25": []string{"lab1"},
+	}
+
+	if len(nets) != len(want) {
+		t.Fatalf("expected %d networks got %d", len(want), len(nets))
+	}
+
+	for _, nw := range nets {
+		hosts, ok := want[nw.Net.String()]
+		if !ok {
+			t.Errorf("unexpected network %s", nw.Net)
+			continue
+		}
+
+		if nw.Record.Search("ipnet") == "" {
+			t.Errorf("%s: record is not an ipnet record: %+v", nw.Net, nw.Record)
+		}
+
+		if len(nw.Hosts) != len(hosts) {
+			t.Errorf("%s: expected %d hosts got %d", nw.Net, len(hosts), len(nw.Hosts))
+			continue
+		}
+
+		for i, sys := range hosts {
+			if got := nw.Hosts[i].Search("sys"); got != sys {
+				t.Errorf("%s: host %d: expected %q got %q", nw.Net, i, sys, got)
+			}
+		}
+	}
+}
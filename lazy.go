@@ -0,0 +1,71 @@
+package ndb
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Parse the files listed by the database= record, and those added by
+// Cat, only when a query first needs their records, instead of all of
+// them in Open. A program whose queries are answered by the first
+// file, such as FileRecords, Walk stopping early, or SearchResult
+// finding more than its limit, never pays to parse a large shared
+// file. Queries that look at every record, such as Search, still parse
+// every file. Files parsed later are not counted by WithLimits, and an
+// error parsing one leaves it empty; Load reports it.
+func Lazy() Option {
+	return func(o *options) {
+		o.lazy = true
+	}
+}
+
+// Return a file whose parsing is put off until recs is called.
+func lazyone(fname string, o *options) *Ndb {
+	return &Ndb{filename: fname, opts: o, lazy: new(sync.Once)}
+}
+
+// Return the records of db, parsing the file first if Lazy put it
+// off. Safe to call from several goroutines.
+func (db *Ndb) recs() RecordSet {
+	if db.lazy != nil {
+		db.lazy.Do(db.load)
+	}
+
+	return db.records
+}
+
+// Parse a file put off by Lazy.
+func (db *Ndb) load() {
+	fresh, err := openone(db.filename, db.opts)
+	if err != nil {
+		db.loaderr = err
+		return
+	}
+
+	db.data = fresh.data
+	db.mtime = fresh.mtime
+	db.records = fresh.records
+	db.lines = fresh.lines
+	db.breaks = fresh.breaks
+	db.bloom = fresh.bloom
+	atomic.StoreInt32(&db.parsed, 1)
+}
+
+// Whether db is a file put off by Lazy that has not been parsed yet.
+// Safe to call while another goroutine parses it.
+func (db *Ndb) pending() bool {
+	return db.lazy != nil && atomic.LoadInt32(&db.parsed) == 0
+}
+
+// Parse any files put off by Lazy, and return the first error from
+// parsing one. Without Lazy, this does nothing.
+func (n *Ndb) Load() error {
+	for db := n; db != nil; db = db.next {
+		db.recs()
+		if db.loaderr != nil {
+			return db.loaderr
+		}
+	}
+
+	return nil
+}
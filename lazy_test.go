@@ -0,0 +1,123 @@
+package ndb
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	db, err := Open(testndb, Lazy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := db.Files(); len(files) != 2 {
+		t.Fatalf("wrong files: %q", files)
+	}
+
+	before := statParses.Value()
+
+	if recs := db.FileRecords(testndb); len(recs) == 0 {
+		t.Errorf("no records in %s", testndb)
+	}
+
+	res := db.SearchResult("sys", "localhost", 0)
+	if len(res.Records) == 0 {
+		t.Errorf("sys=localhost not found")
+	}
+
+	if changed, err := db.Changed(); err != nil || changed {
+		t.Errorf("Changed = %v, %v before parsing common", changed, err)
+	}
+
+	if n := statParses.Value() - before; n != 1 {
+		t.Errorf("parsed %d files, want 1", n)
+	}
+
+	eager, err := Open(testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := db.Search("dom", "A.ROOT-SERVERS.NET"), eager.Search("dom", "A.ROOT-SERVERS.NET"); got == nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("lazy search got %v want %v", got, want)
+	}
+
+	if err := db.Load(); err != nil {
+		t.Error(err)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(db.Attrs(), eager.Attrs()) {
+		t.Errorf("lazy attrs differ after reopen")
+	}
+}
+
+func TestLazyMissing(t *testing.T) {
+	db, err := Open(testndb, Lazy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.next.filename = "testndb/missing"
+
+	if recs := db.Search("dom", "A.ROOT-SERVERS.NET"); recs != nil {
+		t.Errorf("found records in a missing file: %v", recs)
+	}
+
+	if err := db.Load(); err == nil {
+		t.Errorf("no error loading a missing file")
+	}
+}
+
+func TestLazyCat(t *testing.T) {
+	db, err := Open(testndb, Lazy(), Single())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := statParses.Value()
+
+	if err := db.Cat("testndb/common"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := statParses.Value() - before; n != 0 {
+		t.Errorf("Cat parsed %d files", n)
+	}
+
+	if recs := db.Search("dom", "A.ROOT-SERVERS.NET"); recs == nil {
+		t.Errorf("no records from the file added by Cat")
+	}
+
+	if n := statParses.Value() - before; n != 1 {
+		t.Errorf("parsed %d files, want 1", n)
+	}
+}
+
+func TestLazyConcurrent(t *testing.T) {
+	db, err := Open(testndb, Lazy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run with -race: Changed asks whether files are pending while
+	// the search parses them
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			db.Search("dom", "A.ROOT-SERVERS.NET")
+		}()
+		go func() {
+			defer wg.Done()
+			db.Changed()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,134 @@
+package ndb
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Return when the record expires, from its expires= tuple, which
+// holds Unix seconds or an RFC 3339 time. The second result is false
+// if the record has no valid expires= tuple and so never expires.
+func (r Record) Expires() (time.Time, bool) {
+	return r.timestamp("expires")
+}
+
+// Parse the first value of attr as Unix seconds or an RFC 3339 time.
+func (r Record) timestamp(attr string) (time.Time, bool) {
+	val := r.Search(attr)
+	if val == "" {
+		return time.Time{}, false
+	}
+
+	if secs, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return time.Unix(secs, 0), true
+	}
+
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+// Whether the record has expired by the time now.
+// Expired records are ignored by Search and Ipinfo.
+func (r Record) Expired(now time.Time) bool {
+	t, ok := r.Expires()
+	return ok && !now.Before(t)
+}
+
+// Add rec to the lease file fname, with an expires= tuple ttl from now.
+// A lease with the same key (see Record.Key) is replaced, and expired
+// leases are dropped. The file is created if needed and locked while
+// it is rewritten. Leases are seen by queries when fname is one of the
+// database files; the database is reread if so.
+func (n *Ndb) AddLease(fname string, rec Record, ttl time.Duration) error {
+	now := time.Now()
+
+	lease := Record{}
+	for _, tuple := range rec {
+		if tuple.Attr != "expires" {
+			lease = append(lease, tuple)
+		}
+	}
+	lease = append(lease, Tuple{"expires", strconv.FormatInt(now.Add(ttl).Unix(), 10)})
+
+	return n.rewrite(fname, func(records RecordSet) RecordSet {
+		var keep RecordSet
+
+		for _, old := range records {
+			if !old.Expired(now) && old.Key() != lease.Key() {
+				keep = append(keep, old)
+			}
+		}
+
+		return append(keep, lease)
+	})
+}
+
+// Add rec to the dynamic file fname, replacing any record with the
+// same key (see Record.Key), so a host can register or update its own
+// entry. The file is created if needed and locked while it is
+// rewritten, and the database is reread if fname is one of its files.
+func (n *Ndb) Register(fname string, rec Record) error {
+	return n.rewrite(fname, func(records RecordSet) RecordSet {
+		for i, old := range records {
+			if old.Key() == rec.Key() {
+				records[i] = rec
+				return records
+			}
+		}
+
+		return append(records, rec)
+	})
+}
+
+// Replace the records in fname with the result of edit, holding the
+// file's lock (see lockdb). Comments and formatting in the file are not
+// preserved. Nothing is written if a tuple can't be. Rereads the
+// database if fname is one of its files, by any name.
+func (n *Ndb) rewrite(fname string, edit func(RecordSet) RecordSet) error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	lock, err := lockdb(fname)
+	if err != nil {
+		return fmt.Errorf("rewrite: %s", err)
+	}
+	defer unlockdb(lock)
+
+	data, err := readdb(fname)
+	if err != nil {
+		return fmt.Errorf("rewrite: %s", err)
+	}
+
+	records, _, _, err := parserec(&Ndb{filename: fname, data: bytes.NewReader(data)})
+	if err != nil {
+		return fmt.Errorf("rewrite: %s", err)
+	}
+
+	var buf bytes.Buffer
+
+	for _, rec := range edit(records) {
+		for _, tuple := range rec {
+			if err := checktuple(tuple); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(formatrecord(rec))
+	}
+
+	if err := writefile(fname, buf.Bytes()); err != nil {
+		return fmt.Errorf("rewrite: %s", err)
+	}
+
+	// fname may name a database file another way
+	if n.opts.samefile(n.Files(), fname) != "" {
+		return n.Reopen()
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordExpires(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+
+	tests := []struct {
+		val     string
+		expired bool
+	}{
+		{"", false},
+		{"999999999", true},
+		{"1000000000", true},
+		{"1000000001", false},
+		{"2001-09-09T01:46:39Z", true},
+		{"2001-09-09T01:46:41Z", false},
+		{"bogus", false},
+	}
+
+	for _, test := range tests {
+		rec := Record{Tuple{"sys", "x"}}
+		if test.val != "" {
+			rec = append(rec, Tuple{"expires", test.val})
+		}
+
+		if rec.Expired(now) != test.expired {
+			t.Errorf("expires=%q: expected expired %v", test.val, test.expired)
+		}
+	}
+}
+
+func TestLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local")
+	leases := filepath.Join(dir, "leases")
+
+	data := "database=\n\tfile=" + local + "\n\tfile=" + leases + "\n" +
+		"sys=static ip=10.0.0.1\n"
+
+	if err := ioutil.WriteFile(local, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := "sys=stale ip=10.0.0.9 expires=1\nsys=gone ip=10.0.0.8 expires=1\n"
+
+	if err := ioutil.WriteFile(leases, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(local)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "stale"); recs != nil {
+		t.Errorf("expired record returned: %+v", recs)
+	}
+
+	if err := db.AddLease(leases, Record{Tuple{"sys", "dyn"}, Tuple{"ip", "10.0.0.2"}}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// renew with a new address
+	if err := db.AddLease(leases, Record{Tuple{"sys", "dyn"}, Tuple{"ip", "10.0.0.3"}}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := db.Search("sys", "dyn")
+
+	if len(recs) != 1 || recs[0].Search("ip") != "10.0.0.3" {
+		t.Fatalf("expected one renewed lease, got %+v", recs)
+	}
+
+	if _, ok := recs[0].Expires(); !ok {
+		t.Errorf("lease has no expiry: %+v", recs[0])
+	}
+
+	if n := len(db.FileRecords(leases)); n != 1 {
+		t.Errorf("expected expired leases to be dropped, have %d records", n)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local")
+	dynamic := filepath.Join(dir, "dynamic")
+
+	data := "database=\n\tfile=" + local + "\n\tfile=" + dynamic + "\n"
+
+	if err := ioutil.WriteFile(local, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(dynamic, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(local)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ip := range []string{"10.0.0.5", "10.0.0.6"} {
+		if err := db.Register(dynamic, Record{Tuple{"sys", "laptop"}, Tuple{"ip", ip}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Register(dynamic, Record{Tuple{"sys", "phone"}, Tuple{"ip", "10.0.0.7"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ip := db.Search("sys", "laptop").Search("ip"); ip != "10.0.0.6" {
+		t.Errorf("expected updated ip 10.0.0.6, got %q", ip)
+	}
+
+	if n := len(db.FileRecords(dynamic)); n != 2 {
+		t.Errorf("expected 2 records, got %d", n)
+	}
+
+	if err := db.Register(dynamic, Record{Tuple{"sys", "tablet"}, Tuple{"info", `say "hi"`}}); err == nil {
+		t.Errorf("registered a record that can't be written")
+	}
+
+	if data, _ := ioutil.ReadFile(dynamic); strings.Contains(string(data), "tablet") {
+		t.Errorf("wrote a record that can't be written: %q", data)
+	}
+
+	// the database is reread whatever the file is called
+	other := dir + "/./dynamic"
+	if err := db.Register(other, Record{Tuple{"sys", "watch"}, Tuple{"ip", "10.0.0.8"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "watch"); len(recs) != 1 {
+		t.Errorf("database not reread after registering in %s", other)
+	}
+}
+
+func TestRewriteOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local")
+
+	data := "sys=fir ip=10.0.0.9\n\tether=0011223344aa dom=fir.example.com\n" +
+		"ip=10.0.0.10 sys=pine\n" +
+		"sys=spruce ip=10.0.0.11 info=\"rack 3\"\n"
+
+	if err := ioutil.WriteFile(local, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(local)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := append(RecordSet(nil), db.FileRecords(local)...)
+
+	// rename pine, keeping ip first
+	pine := Record{Tuple{"ip", "10.0.0.10"}, Tuple{"sys", "larch"}}
+
+	if err := db.Register(local, pine); err != nil {
+		t.Fatal(err)
+	}
+
+	want := RecordSet{before[0], pine, before[2]}
+
+	if got := db.FileRecords(local); !sameset(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+
+	// now key fir by dom
+	fir := before[0].MoveTupleFirst("dom")
+
+	if fir.Key() != (Tuple{"dom", "fir.example.com"}) {
+		t.Fatalf("bad move: %v", fir)
+	}
+
+	if err := db.Register(local, fir); err != nil {
+		t.Fatal(err)
+	}
+
+	want = RecordSet{before[0], pine, before[2], fir}
+
+	if got := db.FileRecords(local); !sameset(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+}
@@ -0,0 +1,95 @@
+package ndb
+
+// Return the record split into the lines it was written on. Plan 9
+// treats tuples on the same line as more closely bound than those on
+// other lines of the record, such as an ip= and the dom= beside it.
+// A record that is not from the database, or was expanded from
+// templates, is returned as a single line.
+func (n *Ndb) Lines(rec Record) []Record {
+	if len(rec) == 0 {
+		return nil
+	}
+
+	for db := n; db != nil; db = db.next {
+		for i, record := range db.recs() {
+			if len(record) == len(rec) && &record[0] == &rec[0] {
+				return split(record, db.breaks[i])
+			}
+		}
+	}
+
+	return []Record{rec}
+}
+
+// Split a record at the given tuple indexes.
+func split(rec Record, breaks []int) []Record {
+	var lines []Record
+
+	for i, start := range breaks {
+		end := len(rec)
+		if i+1 < len(breaks) {
+			end = breaks[i+1]
+		}
+		lines = append(lines, rec[start:end:end])
+	}
+
+	return lines
+}
+
+// Search for attr=val and return the rattr tuples of the first matching
+// record, preferring those on the same line as the matching tuple, as
+// Plan 9's ndbgetvalue does. If that line has none, the rattr tuples of
+// the whole record are returned. Returns no tuples (nil) if not found.
+func (n *Ndb) SearchPaired(attr, val, rattr string) []Tuple {
+	recs := n.Search(attr, val)
+	if len(recs) == 0 {
+		return nil
+	}
+
+	for _, line := range n.Lines(recs[0]) {
+		for _, tuple := range line {
+			if tuple.Attr == attr && (val == "" || n.opts.sameval(attr, tuple.Val, val)) {
+				if found := line.find(rattr); found != nil {
+					return found
+				}
+				return recs[0].find(rattr)
+			}
+		}
+	}
+
+	return recs[0].find(rattr)
+}
+
+// Return one record per network interface of a multi-homed host. Each
+// line of rec with an ip= or ether= tuple describes an interface; its
+// record holds that line and the record's lines that describe none,
+// such as the sys= naming the host, in their original order. A record
+// with fewer than two such lines is returned unchanged.
+func (n *Ndb) Interfaces(rec Record) []Record {
+	lines := n.Lines(rec)
+
+	var ifcs []int
+	for i, line := range lines {
+		if line.find("ip") != nil || line.find("ether") != nil {
+			ifcs = append(ifcs, i)
+		}
+	}
+
+	if len(ifcs) < 2 {
+		return []Record{rec}
+	}
+
+	var out []Record
+
+	for _, ifc := range ifcs {
+		var sub Record
+		for i, line := range lines {
+			if i == ifc || (line.find("ip") == nil && line.find("ether") == nil) {
+				sub = append(sub, line...)
+			}
+		}
+		out = append(out, sub)
+	}
+
+	return out
+}
@@ -0,0 +1,106 @@
+package ndb
+
+import (
+	"testing"
+)
+
+const (
+	testmultihomed = "testndb/multihomed"
+)
+
+func TestLines(t *testing.T) {
+	db, err := Open(testmultihomed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := db.Search("sys", "gw")[0]
+	lines := db.Lines(gw)
+
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %v", lines)
+	}
+
+	if lines[2][0] != (Tuple{"ip", "10.1.0.1"}) || len(lines[2]) != 3 {
+		t.Errorf("wrong third line: %v", lines[2])
+	}
+
+	// a copy is not in the database
+	if lines := db.Lines(append(Record(nil), gw...)); len(lines) != 1 {
+		t.Errorf("expected copy as one line, got %v", lines)
+	}
+
+	if lines := db.Lines(nil); lines != nil {
+		t.Errorf("expected no lines, got %v", lines)
+	}
+}
+
+type PairedTest struct {
+	attr, val, rattr string
+	vals             []string
+}
+
+var pairedtests = []PairedTest{
+	PairedTest{"ip", "10.1.0.1", "dom", []string{"gw-b.example.com"}},
+	PairedTest{"ether", "00005e000101", "ip", []string{"10.0.0.1"}},
+	// nothing on the line, so the whole record
+	PairedTest{"ip", "fe80::1", "dom", []string{"gw-a.example.com", "gw-b.example.com"}},
+	PairedTest{"sys", "gw", "ip", []string{"10.0.0.1", "10.1.0.1", "fe80::1"}},
+	PairedTest{"sys", "fir", "dom", []string{"fir.example.com"}},
+	PairedTest{"sys", "noip", "ip", nil},
+	PairedTest{"sys", "nonexistent", "ip", nil},
+}
+
+func TestSearchPaired(t *testing.T) {
+	db, err := Open(testmultihomed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pt := range pairedtests {
+		var vals []string
+		for _, tuple := range db.SearchPaired(pt.attr, pt.val, pt.rattr) {
+			vals = append(vals, tuple.Val)
+		}
+
+		if len(vals) != len(pt.vals) {
+			t.Errorf("%s=%s %s: expected %q got %q", pt.attr, pt.val, pt.rattr, pt.vals, vals)
+			continue
+		}
+
+		for i := range vals {
+			if vals[i] != pt.vals[i] {
+				t.Errorf("%s=%s %s: expected %q got %q", pt.attr, pt.val, pt.rattr, pt.vals, vals)
+				break
+			}
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	db, err := Open(testmultihomed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ifcs := db.Interfaces(db.Search("sys", "gw")[0])
+
+	want := []Record{
+		Record{Tuple{"sys", "gw"}, Tuple{"ip", "10.0.0.1"}, Tuple{"ether", "00005e000101"}, Tuple{"dom", "gw-a.example.com"}, Tuple{"fs", "fs.example.com"}},
+		Record{Tuple{"sys", "gw"}, Tuple{"ip", "10.1.0.1"}, Tuple{"ether", "00005e000102"}, Tuple{"dom", "gw-b.example.com"}, Tuple{"fs", "fs.example.com"}},
+		Record{Tuple{"sys", "gw"}, Tuple{"ip", "fe80::1"}, Tuple{"fs", "fs.example.com"}},
+	}
+
+	if !sameset(RecordSet(ifcs), RecordSet(want)) {
+		t.Errorf("expected %v got %v", want, ifcs)
+	}
+
+	fir := db.Search("sys", "fir")[0]
+
+	if ifcs := db.Interfaces(fir); len(ifcs) != 1 || !sameset(RecordSet(ifcs), RecordSet{fir}) {
+		t.Errorf("single interface host split: %v", ifcs)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ndb
+
+import (
+	"errors"
+	"os"
+)
+
+var errnolock = errors.New("file locking not supported on this system")
+
+func lockfile(f *os.File) error {
+	return errnolock
+}
+
+func unlockfile(f *os.File) error {
+	return errnolock
+}
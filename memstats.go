@@ -0,0 +1,75 @@
+package ndb
+
+import (
+	"unsafe"
+)
+
+// Estimated memory used by a database, from MemStats. The estimates
+// count what the package keeps, not allocator overhead, so they are
+// best used to compare one set of options with another.
+type MemStats struct {
+	Files   int // Files loaded; files put off by Lazy are not counted
+	Records int
+	Tuples  int
+
+	RecordBytes int // Record and tuple slices
+	StringBytes int // Attribute and value text
+	IndexBytes  int // Line numbers, line breaks, bloom filters and the Ipinfo cache
+	DataBytes   int // File text kept after parsing
+}
+
+// Total estimated bytes.
+func (m MemStats) Total() int {
+	return m.RecordBytes + m.StringBytes + m.IndexBytes + m.DataBytes
+}
+
+// Sizes of the values kept per record and tuple.
+const (
+	slicesize = int(unsafe.Sizeof([]int(nil)))
+	tuplesize = int(unsafe.Sizeof(Tuple{}))
+	intsize   = int(unsafe.Sizeof(int(0)))
+)
+
+// Estimate the memory used by the database's records, their text and
+// the indexes kept on them, without parsing files put off by Lazy.
+func (n *Ndb) MemStats() MemStats {
+	var m MemStats
+
+	for db := n; db != nil; db = db.next {
+		if db.pending() {
+			continue
+		}
+
+		m.Files++
+		m.Records += len(db.records)
+		m.RecordBytes += slicesize * cap(db.records)
+
+		for _, rec := range db.records {
+			m.Tuples += len(rec)
+			m.RecordBytes += tuplesize * cap(rec)
+			for _, tuple := range rec {
+				m.StringBytes += len(tuple.Attr) + len(tuple.Val)
+			}
+		}
+
+		m.IndexBytes += intsize * cap(db.lines)
+		m.IndexBytes += slicesize * cap(db.breaks)
+		for _, b := range db.breaks {
+			m.IndexBytes += intsize * cap(b)
+		}
+
+		if db.bloom != nil {
+			m.IndexBytes += 8 * len(db.bloom.bits)
+		}
+
+		if db.data != nil {
+			m.DataBytes += int(db.data.Size())
+		}
+	}
+
+	if n.ipcache != nil {
+		m.IndexBytes += n.ipcache.bytes()
+	}
+
+	return m
+}
@@ -0,0 +1,44 @@
+package ndb
+
+import (
+	"testing"
+)
+
+func TestMemStats(t *testing.T) {
+	db, err := Open(testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := db.MemStats()
+
+	if m.Files != 2 || m.Records == 0 || m.Tuples < m.Records {
+		t.Errorf("wrong counts: %+v", m)
+	}
+
+	if m.StringBytes == 0 || m.RecordBytes < m.Tuples*tuplesize || m.DataBytes == 0 {
+		t.Errorf("wrong sizes: %+v", m)
+	}
+
+	// bloom filters and the ipinfo cache add to the indexes
+	bloomed, err := Open(testndb, WithBloom("sys", "dom", "ip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bloomed.SetIpinfoCache(16)
+	bloomed.Ipinfo("sys", "localhost", "ip")
+
+	if b := bloomed.MemStats(); b.IndexBytes <= m.IndexBytes || b.Total() <= m.Total() {
+		t.Errorf("indexes %d not more than %d", b.IndexBytes, m.IndexBytes)
+	}
+
+	lazy, err := Open(testndb, Lazy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := lazy.MemStats(); l.Files != 1 || l.Records >= m.Records {
+		t.Errorf("lazy database stats counted unparsed files: %+v", l)
+	}
+}
@@ -0,0 +1,237 @@
+package ndb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+)
+
+// Add rec to the end of the first file of the database. The change is
+// seen by searches at once, and written to the file by WriteFile.
+// Like Reopen, changes must not be made while other goroutines search.
+func (n *Ndb) AddRecord(rec Record) error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	for _, tuple := range rec {
+		if err := checktuple(tuple); err != nil {
+			return err
+		}
+	}
+
+	if len(rec) == 0 {
+		return fmt.Errorf("write: empty record")
+	}
+
+	rec = append(Record(nil), rec...)
+
+	n.records = append(n.records, rec)
+	n.lines = append(n.lines, 0)
+	n.breaks = append(n.breaks, []int{0})
+	n.addbloom(n, rec)
+
+	n.edited(n, func(f *File) error {
+		return f.Append(rec)
+	})
+
+	return nil
+}
+
+// Replace the first record with exactly the tuples of old, as loaded,
+// with new, in whichever file has it. Returns an error if there is no
+// such record. Written by WriteFile, as AddRecord is.
+func (n *Ndb) ReplaceRecord(old, new Record) error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	for _, tuple := range new {
+		if err := checktuple(tuple); err != nil {
+			return err
+		}
+	}
+
+	for db := n; db != nil; db = db.next {
+		for i, rec := range db.recs() {
+			if !reflect.DeepEqual(rec, old) {
+				continue
+			}
+
+			new = append(Record(nil), new...)
+
+			db.records[i] = new
+			db.breaks[i] = []int{0}
+			n.addbloom(db, new)
+
+			n.edited(db, func(f *File) error {
+				for _, r := range f.Records() {
+					if reflect.DeepEqual(r.Record(), old) {
+						return f.Replace(r, new)
+					}
+				}
+				return fmt.Errorf("write: %s: record %s=%s not found", db.filename, old.Key().Attr, old.Key().Val)
+			})
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("write: record %s=%s not found", old.Key().Attr, old.Key().Val)
+}
+
+// Delete every record with a tuple attr=val from the database, with
+// values compared as Search compares them (see WithNormalizer), and
+// return how many there were. Written by WriteFile, as AddRecord is.
+func (n *Ndb) DeleteWhere(attr, val string) (int, error) {
+	if err := n.writable(); err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+
+	for db := n; db != nil; db = db.next {
+		var records RecordSet
+		var lines []int
+		var breaks [][]int
+
+		for i, rec := range db.recs() {
+			if n.opts.hasval(rec, attr, val) {
+				continue
+			}
+			records = append(records, rec)
+			lines = append(lines, db.lines[i])
+			breaks = append(breaks, db.breaks[i])
+		}
+
+		if len(records) == len(db.records) {
+			continue
+		}
+
+		deleted += len(db.records) - len(records)
+		db.records, db.lines, db.breaks = records, lines, breaks
+
+		n.edited(db, func(f *File) error {
+			// last first, so the lines of the others don't move
+			recs := f.Records()
+			for i := len(recs) - 1; i >= 0; i-- {
+				if n.opts.hasval(recs[i].Record(), attr, val) {
+					f.Delete(recs[i])
+				}
+			}
+			return nil
+		})
+	}
+
+	return deleted, nil
+}
+
+// Whether the record has an attr tuple whose value matches val, as
+// Search compares them.
+func (o *options) hasval(r Record, attr, val string) bool {
+	for _, tuple := range r {
+		if tuple.Attr == attr && o.sameval(attr, tuple.Val, val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Note an edit to db, to be made to its file by WriteFile.
+func (n *Ndb) edited(db *Ndb, edit func(f *File) error) {
+	db.edits = append(db.edits, edit)
+
+	if n.ipcache != nil {
+		n.ipcache.purge()
+	}
+	n.resettemplates()
+}
+
+// Add the values of rec to db's bloom filter, if it has one.
+func (n *Ndb) addbloom(db *Ndb, rec Record) {
+	if db.bloom == nil {
+		return
+	}
+
+	for _, tuple := range rec {
+		if db.opts.bloomattrs[tuple.Attr] {
+			db.bloom.add(tuple.Attr, db.opts.normalize(tuple.Attr, tuple.Val))
+		}
+	}
+}
+
+// Write the changes made by AddRecord, ReplaceRecord and DeleteWhere
+// to the database files, then reread the database. Each file is locked
+// while it is edited (see lockdb) and replaced whole, so readers never
+// see it half written. The edits are made to its text as it is now,
+// keeping comments, layout and changes others have made since it was
+// loaded. Fails for a database not read from files on disk, such as
+// one from Parse or OpenFS.
+func (n *Ndb) WriteFile() error {
+	if err := n.writable(); err != nil {
+		return err
+	}
+
+	for db := n; db != nil; db = db.next {
+		if len(db.edits) == 0 {
+			continue
+		}
+
+		if err := writeedits(db.filename, db.edits); err != nil {
+			return err
+		}
+
+		db.edits = nil
+	}
+
+	return n.Reopen()
+}
+
+// Edit the database file fname as a File, the way WriteFile does: it
+// is locked, read, passed to edit, and replaced whole if edit changed
+// it and returned nil, so readers and other writers never see it half
+// written. For programs such as ndbfmt that edit files directly.
+func EditFile(fname string, edit func(f *File) error) error {
+	return writeedits(fname, []func(f *File) error{edit})
+}
+
+// Make edits to the file fname, holding its lock.
+func writeedits(fname string, edits []func(f *File) error) error {
+	lock, err := lockdb(fname)
+	if err != nil {
+		return fmt.Errorf("write: %s", err)
+	}
+	defer unlockdb(lock)
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return fmt.Errorf("write: %s", err)
+	}
+
+	f, err := ParseFile(bytes.NewReader(data))
+	if err != nil {
+		if perr, ok := err.(*ParseError); ok {
+			perr.File = fname
+		}
+		return err
+	}
+
+	for _, edit := range edits {
+		if err := edit(f); err != nil {
+			return err
+		}
+	}
+
+	out := f.Bytes()
+	if bytes.Equal(out, data) {
+		return nil
+	}
+
+	if err := writefile(fname, out); err != nil {
+		return fmt.Errorf("write: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMutate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	text := "# hosts\nsys=a ip=10.0.0.1	# gateway\n\nsys=b ip=10.0.0.2\n\tdom=b.example.com\nsys=c ip=10.0.0.3 role=old\nsys=d ip=10.0.0.4 role=old\n"
+
+	if err := ioutil.WriteFile(fname, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname, WithBloom("sys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddRecord(Record{{"sys", "e"}, {"ip", "10.0.0.5"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddRecord(Record{{"info", `say "hi"`}}); err == nil {
+		t.Errorf("added a record that can't be written")
+	}
+
+	b := db.Search("sys", "b")
+	if len(b) != 1 {
+		t.Fatalf("sys=b got %v", b)
+	}
+
+	if err := db.ReplaceRecord(b[0], Record{{"sys", "b"}, {"ip", "10.0.0.22"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.ReplaceRecord(Record{{"sys", "z"}}, Record{{"sys", "y"}}); err == nil {
+		t.Errorf("replaced a record that isn't there")
+	}
+
+	if n, err := db.DeleteWhere("role", "old"); n != 2 || err != nil {
+		t.Errorf("deleted %d records, want 2: %v", n, err)
+	}
+
+	// changes are seen before they are written
+	if recs := db.Search("sys", "e"); len(recs) != 1 {
+		t.Errorf("added record not found: %v", recs)
+	}
+
+	if recs := db.Search("ip", "10.0.0.22"); len(recs) != 1 {
+		t.Errorf("replaced record not found: %v", recs)
+	}
+
+	if recs := db.Search("sys", "c"); recs != nil {
+		t.Errorf("deleted record found: %v", recs)
+	}
+
+	if data, _ := ioutil.ReadFile(fname); string(data) != text {
+		t.Errorf("file written before WriteFile")
+	}
+
+	if err := db.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# hosts\nsys=a ip=10.0.0.1	# gateway\n\nsys=b ip=10.0.0.22\n\nsys=e ip=10.0.0.5\n"
+
+	if data, _ := ioutil.ReadFile(fname); string(data) != want {
+		t.Errorf("wrote %q want %q", data, want)
+	}
+
+	if recs := db.Search("sys", "e"); len(recs) != 1 {
+		t.Errorf("added record not found after reload: %v", recs)
+	}
+
+	parsed, err := Parse(strings.NewReader("sys=a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed.DeleteWhere("sys", "a")
+
+	if err := parsed.WriteFile(); err == nil {
+		t.Errorf("wrote a database with no file")
+	}
+}
+
+func TestDeleteWhereNormalized(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	text := "sys=a ether=00:16:3E:00:00:01\nsys=b ether=00163e000001\nsys=c ether=00163e000002\n"
+
+	if err := ioutil.WriteFile(fname, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname, WithNormalizer("ether", NormalizeEther))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the records Search finds are the ones deleted
+	found := db.Search("ether", "0016.3e00.0001")
+	if len(found) != 2 {
+		t.Fatalf("search found %v", found)
+	}
+
+	if n, err := db.DeleteWhere("ether", "0016.3e00.0001"); n != len(found) || err != nil {
+		t.Errorf("deleted %d records, want %d: %v", n, len(found), err)
+	}
+
+	if err := db.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(fname); string(data) != "sys=c ether=00163e000002\n" {
+		t.Errorf("wrote %q", data)
+	}
+}
+
+func TestEditFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+
+	if err := ioutil.WriteFile(fname, []byte("sys=a   ip=10.0.0.1\n  dom=a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	format := func(f *File) error {
+		f.Format()
+		return nil
+	}
+
+	if err := EditFile(fname, format); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(fname); string(data) != "sys=a ip=10.0.0.1\n\tdom=a\n" {
+		t.Errorf("wrote %q", data)
+	}
+
+	if fi, err := os.Stat(fname); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode %v not kept", fi.Mode())
+	}
+
+	if _, err := os.Stat(fname + ".lock"); err != nil {
+		t.Errorf("file not locked: %v", err)
+	}
+
+	if err := ioutil.WriteFile(fname, []byte("=a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EditFile(fname, format); err == nil || !strings.Contains(err.Error(), fname) {
+		t.Errorf("got %v for a malformed file", err)
+	}
+}
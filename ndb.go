@@ -3,22 +3,35 @@
 package ndb
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
-	"strings"
+	"sort"
+	"sync"
 	"time"
-	"unicode"
-	"unicode/utf8"
 )
 
 const (
 	// Default NDB file
 	NdbLocal = "/lib/ndb/local"
+
+	// Network configuration provided by the Plan 9 kernel and ipconfig
+	NetNdb = "/net/ndb"
 )
 
+// Files tried in order by Open when no file name is given;
+// the first that exists is used. $VAR and ${VAR} are expanded
+// from the environment, and entries naming unset variables are
+// skipped. Files reports which one was chosen.
+var DefaultFiles = []string{
+	NdbLocal,
+	"$PLAN9/ndb/local",
+	"/usr/local/plan9/ndb/local",
+}
+
 // A single database attribute=value tuple.
 // The value may be empty.
 type Tuple struct {
@@ -27,8 +40,77 @@ type Tuple struct {
 
 // A NDB record, which may contain multiple tuples,
 // and may span multiple lines in the file.
+//
+// Tuples are kept in the order they appear in the file, and records
+// in file order, through parsing and through the functions that
+// rewrite files, such as Register. Order matters: Plan 9 tools take
+// the first tuple as the one identifying the record.
 type Record []Tuple
 
+// Attributes that identify a record, in order of preference.
+// When empty, or when a record has none of them, the record's
+// first tuple identifies it, as in Plan 9.
+var KeyAttrs []string
+
+// Return the tuple identifying the record, so the same record can be
+// recognized across reloads: the first tuple with an attribute in
+// KeyAttrs, or else the first tuple. Returns an empty Tuple for an
+// empty record.
+func (r Record) Key() Tuple {
+	for _, attr := range KeyAttrs {
+		for _, tuple := range r {
+			if tuple.Attr == attr {
+				return tuple
+			}
+		}
+	}
+
+	if len(r) == 0 {
+		return Tuple{}
+	}
+
+	return r[0]
+}
+
+// Return the record's first tuple, which by Plan 9 convention names
+// the record, or an empty Tuple for an empty record. Unlike Key, this
+// ignores KeyAttrs.
+func (r Record) Primary() Tuple {
+	if len(r) == 0 {
+		return Tuple{}
+	}
+
+	return r[0]
+}
+
+// Return a copy of the record with its first attr tuple moved to the
+// front, the others keeping their order. Returns the record unchanged
+// if it has no attr tuple.
+func (r Record) MoveTupleFirst(attr string) Record {
+	for i, tuple := range r {
+		if tuple.Attr == attr {
+			out := make(Record, 0, len(r))
+			out = append(out, tuple)
+			out = append(out, r[:i]...)
+			return append(out, r[i+1:]...)
+		}
+	}
+
+	return r
+}
+
+// Search a Record for a given attribute and return the value.
+// Returns "" if not present.
+func (r Record) Search(attr string) string {
+	for _, tuple := range r {
+		if tuple.Attr == attr {
+			return tuple.Val
+		}
+	}
+
+	return ""
+}
+
 // RecordSet is a related group of records, from a single entry in ndb.
 type RecordSet []Record
 
@@ -52,43 +134,118 @@ type Ndb struct {
 	data     *bytes.Reader // Raw data
 	mtime    time.Time     // Last modified time
 	records  RecordSet     // NDB Records
+	lines    []int         // Line number of each record
+	breaks   [][]int       // Index of the first tuple of each line of each record
 	next     *Ndb          // Next in linked list
+	opts     *options      // Options given to Open
+	bloom    *bloom        // Values of some attributes, see WithBloom
+
+	// Parsing put off by Lazy
+	lazy    *sync.Once // Parses the file once, nil if parsed by Open
+	parsed  int32      // Whether lazy has parsed the file, set atomically
+	loaderr error      // Error from parsing it, if it failed
+
+	edits []func(f *File) error // Changes for WriteFile to make to the file
+
+	// Plan 9 hash files, see hashed
+	hashmu    sync.Mutex
+	hashes    map[string]*hashfile // By attribute, nil if there is none
+	hashlines map[int]int          // Line of the record at each offset, empty if hash files can't be used
+
+	ipcache *ipcache // Ipinfo results, only used in the first Ndb
+
+	// Template records by name, only used in the first Ndb, see template
+	tmplmu    sync.Mutex
+	templates map[string]Record // nil until needed after loading
+
+	// Load status, only used in the first Ndb
+	loaded    time.Time    // When the records were last loaded
+	reloaderr error        // Error from the last Reopen, if it failed
+	warnings  []Diagnostic // Problems found by Open, see Warnings
+}
+
+// Where a record begins in the database.
+type Pos struct {
+	File string // NDB file name
+	Line int    // Line number, counting from 1
+}
+
+// Return the position in file:line form.
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
 }
 
-// Open an NDB database file.
-func Open(fname string) (*Ndb, error) {
+// Open an NDB database file. A file listed more than once by the
+// database= record, under any name, is opened once; see Warnings.
+func Open(fname string, opts ...Option) (*Ndb, error) {
 	var db, first, last *Ndb
+	var warnings []Diagnostic
 	var err error
 
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	if fname == "" {
-		fname = NdbLocal
+		if fname, err = defaultfile(); err != nil {
+			return nil, err
+		}
 	}
-	db, err = openone(fname)
+	db, err = openone(fname, o)
 	if err != nil {
 		return nil, err
 	}
 
+	dbs := []*Ndb{db}
+	if err := o.check(dbs); err != nil {
+		return nil, err
+	}
+
 	first = db
 	last = db
 
 	// open other db files
-	if dbrec := db.Search("database", ""); dbrec != nil {
+	if dbrec := db.Search("database", ""); dbrec != nil && !o.single {
+		var seen []string
+		line := db.dbline()
 
 		for _, files := range dbrec[0] {
 			if files.Attr == "file" {
-				if files.Val == fname {
+				if dup := o.samefile(seen, files.Val); dup == files.Val {
+					warnings = append(warnings, Diagnostic{fname, line, SeverityWarning,
+						fmt.Sprintf("file=%s is listed more than once; only the first is used", files.Val)})
+					continue
+				} else if dup != "" {
+					warnings = append(warnings, Diagnostic{fname, line, SeverityWarning,
+						fmt.Sprintf("file=%s is the same file as file=%s; only the first is used", files.Val, dup)})
+					continue
+				}
+				seen = append(seen, files.Val)
+
+				if o.samefile([]string{fname}, files.Val) != "" {
 					if first.next == nil {
 						continue
 					}
 					if first.filename == fname {
 						db = first
 						first = first.next
+						db.next = nil
 						last.next = db
 						last = db
 					}
 					continue
 				}
-				if db, err = openone(files.Val); err != nil {
+				if o.lazy {
+					db = lazyone(files.Val, o)
+				} else if db, err = openone(files.Val, o); err != nil {
+					return nil, err
+				} else if l := db.dbline(); l != 0 {
+					warnings = append(warnings, Diagnostic{files.Val, l, SeverityWarning,
+						"database= record is ignored; only the first file's is followed"})
+				}
+				dbs = append(dbs, db)
+				if err := o.check(dbs); err != nil {
 					return nil, err
 				}
 				last.next = db
@@ -97,15 +254,109 @@ func Open(fname string) (*Ndb, error) {
 		}
 	}
 
+	first.warnings = warnings
+	first.loaded = time.Now()
+
 	return first, nil
 }
 
+// Return the line of the file's database= record, or 0 if it has none.
+func (db *Ndb) dbline() int {
+	for i, rec := range db.recs() {
+		if rec.find("database") != nil {
+			return db.lines[i]
+		}
+	}
+
+	return 0
+}
+
+// Find the first of DefaultFiles that exists.
+func defaultfile() (string, error) {
+	for _, fname := range DefaultFiles {
+		unset := false
+		fname = os.Expand(fname, func(v string) string {
+			val, ok := os.LookupEnv(v)
+			if !ok || val == "" {
+				unset = true
+			}
+			return val
+		})
+
+		if unset {
+			continue
+		}
+
+		if _, err := os.Stat(fname); err == nil {
+			return fname, nil
+		}
+	}
+
+	return "", fmt.Errorf("open: no database in %q", DefaultFiles)
+}
+
+// Add the file fname to the end of the database, like ndbcat(2).
+// Adding a file that is already part of the database, under any name,
+// does nothing.
+// With Lazy, the file is parsed when a query first needs it.
+func (n *Ndb) Cat(fname string) error {
+	var dbs []*Ndb
+	last := n
+	for db := n; db != nil; db = db.next {
+		if n.opts.samefile([]string{db.filename}, fname) != "" {
+			return nil
+		}
+		dbs = append(dbs, db)
+		last = db
+	}
+
+	db := lazyone(fname, n.opts)
+	if !n.opts.lazy {
+		var err error
+		if db, err = openone(fname, n.opts); err != nil {
+			return err
+		}
+	}
+
+	if err := n.opts.check(append(dbs, db)); err != nil {
+		return err
+	}
+
+	last.next = db
+
+	// cached results may be answered differently by the new file
+	if n.ipcache != nil {
+		n.ipcache.purge()
+	}
+	n.resettemplates()
+
+	return nil
+}
+
+// Add the system's network configuration, NetNdb, to the end of the
+// database, as Plan 9's cs does. This exists on Plan 9 and where a
+// Plan 9 /net is mounted; elsewhere it does nothing.
+func (n *Ndb) CatNet() error {
+	if _, err := os.Stat(NetNdb); err != nil {
+		return nil
+	}
+
+	return n.Cat(NetNdb)
+}
+
 // Open just one NDB file
-func openone(fname string) (*Ndb, error) {
-	db := &Ndb{filename: fname}
+func openone(fname string, o *options) (db *Ndb, err error) {
+	statParses.Add(1)
+	defer func() {
+		if err != nil {
+			statParseErrors.Add(1)
+		}
+	}()
+
+	db = &Ndb{filename: fname, opts: o}
 
 	// open file
-	f, err := os.Open(db.filename)
+	f, err := o.open(db.filename)
 
 	if err != nil {
 		return nil, fmt.Errorf("open: %s", err)
@@ -123,37 +374,215 @@ func openone(fname string) (*Ndb, error) {
 	// read all data
 	if data, err := ioutil.ReadAll(f); err != nil {
 		return nil, fmt.Errorf("open: %s", err)
-	} else {
-		db.data = bytes.NewReader(data)
+	} else if partial(data) {
+		return nil, &PartialWriteError{fname, PartialRetry}
+	} else if err := db.parse(data); err != nil {
+		return nil, err
 	}
 
-	// parse records
-	if db.records, err = parserec(db); err != nil {
-		return nil, fmt.Errorf("open: %s", err)
+	return db, nil
+}
+
+// Parse an NDB database from r, such as data embedded in a program or
+// read from the network. The database has no file name: records are
+// at positions with an empty File, Reopen and Changed leave it alone,
+// and functions that write to the file fail. database= records are
+// not followed; use Cat to add files to the database.
+func Parse(r io.Reader, opts ...Option) (*Ndb, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	statParses.Add(1)
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		statParseErrors.Add(1)
+		return nil, fmt.Errorf("parse: %s", err)
+	}
+
+	db := &Ndb{opts: o}
+	if err := db.parse(data); err != nil {
+		statParseErrors.Add(1)
+		return nil, err
+	}
+
+	if err := o.check([]*Ndb{db}); err != nil {
+		return nil, err
 	}
 
+	db.loaded = time.Now()
+
 	return db, nil
 }
 
-// Reopen NDB file.
+// Parse the records in data, keeping those the options want.
+func (db *Ndb) parse(data []byte) (err error) {
+	db.data = bytes.NewReader(data)
+
+	if db.records, db.lines, db.breaks, err = parserec(db); err != nil {
+		return err
+	}
+
+	db.opts.buildbloom(db)
+
+	return nil
+}
+
+// Suggested wait before retrying a file that is being written.
+const PartialRetry = time.Second
+
+// Error returned when a file looks like it is still being written,
+// so it isn't loaded in its truncated state.
+type PartialWriteError struct {
+	File       string        // NDB file name
+	RetryAfter time.Duration // Suggested wait before trying again
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("open: %s: partially written, retry after %v", e.File, e.RetryAfter)
+}
+
+// Error for a tuple with no attribute, such as =val.
+var ErrNoAttr = errors.New("tuple has no attribute")
+
+// Error returned by Open and Parse when a database file is malformed,
+// saying where.
+type ParseError struct {
+	File   string // NDB file name, empty for Parse
+	Line   int    // Line number, counting from 1
+	Column int    // Byte in the line where Text begins, counting from 1; 0 if unknown
+	Text   string // The malformed text, if known
+	Err    error  // What is wrong, such as ErrNoAttr
+}
+
+func (e *ParseError) Error() string {
+	pos := fmt.Sprintf("%s:%d", e.File, e.Line)
+	if e.Column > 0 {
+		pos += fmt.Sprintf(":%d", e.Column)
+	}
+
+	if e.Text == "" {
+		return fmt.Sprintf("parse: %s: %s", pos, e.Err)
+	}
+
+	return fmt.Sprintf("parse: %s: %s: %q", pos, e.Err, e.Text)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Whether data looks cut off partway through being written:
+// it does not end with a newline, and its last line ends
+// inside a quoted value.
+func partial(data []byte) bool {
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return false
+	}
+
+	_, open := parseline(string(data[bytes.LastIndexByte(data, '\n')+1:]))
+	return open
+}
+
+// Reopen NDB file. All files are parsed before any are replaced,
+// so if one fails to open or parse the database keeps serving the
+// records it had, and the error is kept for ReloadStatus. Changed
+// continues to report true, so the caller can retry.
 func (n *Ndb) Reopen() error {
+	statReloads.Add(1)
+
+	var fresh []*Ndb
+
 	for db := n; db != nil; db = db.next {
-		if newdb, err := openone(db.filename); err != nil {
+		if db.pending() || db.filename == "" {
+			fresh = append(fresh, db)
+			continue
+		}
+
+		newdb, err := openone(db.filename, db.opts)
+		if err != nil {
+			statReloadErrors.Add(1)
+			n.reloaderr = err
 			return err
-		} else {
-			db.data = newdb.data
-			db.mtime = newdb.mtime
-			db.records = newdb.records
 		}
+		fresh = append(fresh, newdb)
+	}
+
+	if err := n.opts.check(fresh); err != nil {
+		statReloadErrors.Add(1)
+		n.reloaderr = err
+		return err
 	}
 
+	if n.ipcache != nil {
+		n.ipcache.purge()
+	}
+	n.resettemplates()
+
+	for db, i := n, 0; db != nil; db, i = db.next, i+1 {
+		if fresh[i] == db {
+			// not from a file, or put off by Lazy; retry a failed parse when needed
+			if db.loaderr != nil {
+				db.lazy = new(sync.Once)
+				db.loaderr = nil
+			}
+			continue
+		}
+
+		db.data = fresh[i].data
+		db.mtime = fresh[i].mtime
+		db.records = fresh[i].records
+		db.lines = fresh[i].lines
+		db.breaks = fresh[i].breaks
+		db.bloom = fresh[i].bloom
+
+		db.hashmu.Lock()
+		db.hashes, db.hashlines = nil, nil
+		db.hashmu.Unlock()
+	}
+
+	n.loaded = time.Now()
+	n.reloaderr = nil
+
 	return nil
 }
 
+// Parse the files on disk as Reopen would, and return the first error,
+// without replacing the records being served.
+func (n *Ndb) ValidateReload() error {
+	var fresh []*Ndb
+
+	for db := n; db != nil; db = db.next {
+		if db.pending() || db.filename == "" {
+			continue
+		}
+
+		newdb, err := openone(db.filename, db.opts)
+		if err != nil {
+			return err
+		}
+		fresh = append(fresh, newdb)
+	}
+
+	return n.opts.check(fresh)
+}
+
+// Return when the records being served were loaded, and the error
+// from the last Reopen if it failed.
+func (n *Ndb) ReloadStatus() (time.Time, error) {
+	return n.loaded, n.reloaderr
+}
+
 // Check if any db files changed.
 func (n *Ndb) Changed() (bool, error) {
 	for db := n; db != nil; db = db.next {
-		fi, err := os.Stat(db.filename)
+		if db.pending() || db.filename == "" {
+			continue
+		}
+
+		fi, err := db.opts.stat(db.filename)
 		if err != nil {
 			return false, err
 		}
@@ -167,150 +596,230 @@ func (n *Ndb) Changed() (bool, error) {
 }
 
 // Search for a record set with the given attr=val.
+// Records that have expired (see Record.Expired) are skipped.
 // Returns no records (nil) if not found.
 func (n *Ndb) Search(attr, val string) RecordSet {
-	var results RecordSet
+	return n.SearchResult(attr, val, 0).Records
+}
+
+// Return the names of the files comprising the database, in search order.
+func (n *Ndb) Files() []string {
+	var files []string
 
-	// check each db file
 	for db := n; db != nil; db = db.next {
+		files = append(files, db.filename)
+	}
 
-		// and check each record
-		for _, record := range db.records {
+	return files
+}
 
-			// each each tuple!
-			for _, tuple := range record {
-				// if val is "" we don't care what it is
-				if val == "" && tuple.Attr == attr {
-					results = append(results, record)
-				} else if tuple.Attr == attr && tuple.Val == val {
-					results = append(results, record)
-				}
-			}
+// Return the records parsed from the named database file.
+// Returns no records (nil) if the file is not part of the database.
+func (n *Ndb) FileRecords(fname string) RecordSet {
+	for db := n; db != nil; db = db.next {
+		if db.filename == fname {
+			return db.recs()
 		}
-
 	}
 
-	return results
+	return nil
 }
 
-// Parse whole ndb records from the ndb
-func parserec(n *Ndb) (RecordSet, error) {
-	var err error
-
-	records := make(RecordSet, 1)
+// Call fn for each record in the database, in search order,
+// with the position where the record begins.
+// Stops early if fn returns false.
+func (n *Ndb) Walk(fn func(rec Record, pos Pos) bool) {
+	for db := n; db != nil; db = db.next {
+		for i, record := range db.recs() {
+			if !fn(record, Pos{db.filename, db.lines[i]}) {
+				return
+			}
+		}
+	}
+}
 
-	n.data.Seek(0, 0)
+// Return the distinct attribute names in the database, sorted.
+func (n *Ndb) Attrs() []string {
+	seen := make(map[string]bool)
 
-	scanl := bufio.NewScanner(n.data)
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			for _, tuple := range record {
+				seen[tuple.Attr] = true
+			}
+		}
+	}
 
-	var rec Record
+	return sortedkeys(seen)
+}
 
-	for scanl.Scan() {
-		line := scanl.Text()
+// Return the distinct values of attr in the database, sorted.
+// Empty values are not included.
+func (n *Ndb) Vals(attr string) []string {
+	seen := make(map[string]bool)
 
-		// skip empty lines
-		if line == "" {
-			continue
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			for _, tuple := range record {
+				if tuple.Attr == attr && tuple.Val != "" {
+					seen[tuple.Val] = true
+				}
+			}
 		}
+	}
 
-		first, _ := utf8.DecodeRuneInString(line)
+	return sortedkeys(seen)
+}
 
-		// comment, skip
-		if first == '#' {
-			continue
-		}
+func sortedkeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-		// not whitespace, begin a record
-		if !unicode.IsSpace(first) {
-			records = append(records, rec)
-			rec = Record{}
+// Parse whole ndb records from the ndb, along with the line number
+// each record begins on, and for each record the index of the first
+// tuple of each of its lines. Records its options drop are left out,
+// and parsing stops with a *LimitError once a limit is passed. Other
+// errors are *ParseError.
+func parserec(n *Ndb) (RecordSet, []int, [][]int, error) {
+	var records RecordSet
+	var lines []int
+	var breaks [][]int
+
+	n.data.Seek(0, 0)
+
+	d := NewDecoder(n.data)
+	d.name = n.filename
+	ntup := 0
+
+	for {
+		rec, err := d.Next()
+		if err == io.EOF {
+			return records, lines, breaks, nil
+		} else if err != nil {
+			return records, lines, breaks, err
 		}
 
-		if tuples, terr := parsetuples(line); err != nil {
-			err = terr
-			break
-		} else {
-			rec = append(rec, tuples...)
+		brk := d.breaks
+		if n.opts != nil {
+			var ok bool
+			if rec, brk, ok = n.opts.keep(rec, brk); !ok {
+				continue
+			}
 		}
 
-	}
+		records = append(records, rec)
+		lines = append(lines, d.line)
+		breaks = append(breaks, brk)
 
-	if err := scanl.Err(); err != nil {
-		return nil, err
+		// stop before a huge file is all in memory
+		ntup += len(rec)
+		if n.opts != nil {
+			if err := n.opts.limit(len(records), ntup); err != nil {
+				return records, lines, breaks, err
+			}
+		}
 	}
+}
 
-	// make sure to get the last record.
-	records = append(records, rec)
-
-	return records, err
+// Whether c separates tuples.
+func iswhite(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
 }
 
-// bufio.Scanner function to split data by words and quoted strings
-func scanStrings(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	// Skip leading spaces.
-	start := 0
-	for width := 0; start < len(data); start += width {
-		var r rune
-		r, width = utf8.DecodeRune(data[start:])
-		if !unicode.IsSpace(r) {
-			break
+// split up a string into ndb tuples, as _ndbparsetuple in Plan 9's
+// libndb does. A # where a tuple could begin starts a comment lasting
+// to the end of the line. An attribute without = has an empty value.
+// A "quoted value" may contain spaces and #, and ends at the closing
+// quote or the end of the line.
+// A tuple with no attribute, such as =val, is a ParseError.
+func parsetuples(line string) ([]Tuple, error) {
+	tuples, spans, _ := parsespans(line)
+
+	for i, tuple := range tuples {
+		if tuple.Attr == "" {
+			return nil, &ParseError{Column: spans[i][0] + 1, Text: line[spans[i][0]:spans[i][1]], Err: ErrNoAttr}
 		}
 	}
 
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
-	}
+	return tuples, nil
+}
 
-	// Scan until space, marking end of word.
-	inquote := false
-	for width, i := 0, start; i < len(data); i += width {
-		var r rune
-		r, width = utf8.DecodeRune(data[i:])
-		if r == '"' {
-			inquote = !inquote
-			continue
-		}
-		if unicode.IsSpace(r) && !inquote {
-			return i + width, data[start:i], nil
-		}
-	}
-	// If we're at EOF, we have a final, non-empty, non-terminated word. Return it.
-	if atEOF && len(data) > start {
-		return len(data), data[start:], nil
-	}
-	// Request more data.
-	return 0, nil, nil
+// Parse tuples as parsetuples does, also reporting whether
+// the line ended inside a quoted value.
+func parseline(line string) (tuples []Tuple, openquote bool) {
+	tuples, _, openquote = parsespans(line)
+	return tuples, openquote
 }
 
-// split up a string into ndb tuples.
-// parse "quoted strings" correctly, and
-// ignore comments at end of line
-func parsetuples(line string) ([]Tuple, error) {
-	tuples := make([]Tuple, 0)
+// Parse tuples as parseline does, also returning where each tuple's
+// text begins and ends in line.
+func parsespans(line string) (tuples []Tuple, spans [][2]int, openquote bool) {
+	tuples = make([]Tuple, 0)
 
-	// only chop comment if it is at the beginning of a line
-	// TODO: make comments work anywhere not in quotes
-	if line[0] == '#' {
-		return tuples, nil
-	}
+	for cp := 0; cp < len(line); {
+		// skip white space
+		for cp < len(line) && iswhite(line[cp]) {
+			cp++
+		}
 
-	scanw := bufio.NewScanner(strings.NewReader(line))
-	scanw.Split(scanStrings)
+		if cp == len(line) {
+			break
+		}
 
-	for scanw.Scan() {
-		tpstr := scanw.Text()
-		//fmt.Printf("tuple %q\n", tpstr)
-		spl := strings.SplitN(tpstr, "=", 2)
+		// comment, skip to end of line
+		if line[cp] == '#' {
+			for cp < len(line) && line[cp] != '\n' {
+				cp++
+			}
+			continue
+		}
 
-		if len(spl) != 2 {
-			return nil, fmt.Errorf("invalid tuple %q", tpstr)
+		// attribute
+		start := cp
+		p := cp
+		for cp < len(line) && line[cp] != '=' && !iswhite(line[cp]) {
+			cp++
 		}
+		tuple := Tuple{Attr: line[p:cp]}
 
-		spl[1] = strings.TrimLeft(spl[1], `"`)
-		spl[1] = strings.TrimRight(spl[1], `"`)
+		// value, which may follow spaces after the attribute
+		for cp < len(line) && (line[cp] == ' ' || line[cp] == '\t') {
+			cp++
+		}
+		if cp < len(line) && line[cp] == '=' {
+			cp++
+			switch {
+			case cp < len(line) && line[cp] == '"':
+				cp++
+				p = cp
+				for cp < len(line) && line[cp] != '"' && line[cp] != '\n' {
+					cp++
+				}
+				tuple.Val = line[p:cp]
+				if cp < len(line) && line[cp] == '"' {
+					cp++
+				} else {
+					openquote = true
+				}
+			case cp < len(line) && line[cp] == '#':
+				// empty value, the comment is skipped above
+			default:
+				p = cp
+				for cp < len(line) && !iswhite(line[cp]) {
+					cp++
+				}
+				tuple.Val = line[p:cp]
+			}
+		}
 
-		tuples = append(tuples, Tuple{spl[0], spl[1]})
+		tuples = append(tuples, tuple)
+		spans = append(spans, [2]int{start, cp})
 	}
 
-	return tuples, nil
+	return tuples, spans, openquote
 }
@@ -3,6 +3,10 @@ package ndb
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +39,21 @@ markov= order=2 nword=30 corpus=data/corpus`,
 			ntup:   1,
 			tuples: []Tuple{Tuple{"one", "one"}},
 		},
+		NdbParseTest{
+			line:   `dom=10.in-addr.arpa soa=		# rfc1918 zones`,
+			ntup:   2,
+			tuples: []Tuple{Tuple{"dom", "10.in-addr.arpa"}, Tuple{"soa", ""}},
+		},
+		NdbParseTest{
+			line:   `cpu fs auth=#comment proto ="il" info="a # b" c="unterminated`,
+			ntup:   3,
+			tuples: []Tuple{Tuple{"cpu", ""}, Tuple{"fs", ""}, Tuple{"auth", ""}},
+		},
+		NdbParseTest{
+			line:   `proto ="il" info="a # b" c="unterminated`,
+			ntup:   3,
+			tuples: []Tuple{Tuple{"proto", "il"}, Tuple{"info", "a # b"}, Tuple{"c", "unterminated"}},
+		},
 	}
 )
 
@@ -77,12 +96,16 @@ func TestParseRecord(t *testing.T) {
 	}
 
 	ndb := &Ndb{data: bytes.NewReader(data)}
-	rec, err := parserec(ndb)
+	rec, lines, breaks, err := parserec(ndb)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if len(lines) != len(rec) || len(breaks) != len(rec) {
+		t.Fatalf("%d records but %d line numbers and %d line breaks", len(rec), len(lines), len(breaks))
+	}
+
 	for _, record := range rec {
 
 		for n, tuple := range record {
@@ -141,4 +164,449 @@ func TestNdbSearch(t *testing.T) {
 	if syslog := recs.Search("port"); syslog != "514" {
 		t.Fatalf("expected 514, got %q", syslog)
 	}
+
+	if syslog := recs[0].Search("port"); syslog != "514" {
+		t.Fatalf("expected 514, got %q", syslog)
+	}
+
+	if none := recs[0].Search("nonexistent"); none != "" {
+		t.Fatalf("expected \"\", got %q", none)
+	}
+}
+
+func TestNdbAttrsVals(t *testing.T) {
+	ndb, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := ndb.Attrs()
+
+	for _, want := range []string{"database", "dom", "ip", "tcp", "udp"} {
+		i := sort.SearchStrings(attrs, want)
+		if i == len(attrs) || attrs[i] != want {
+			t.Errorf("attribute %q missing from %q", want, attrs)
+		}
+	}
+
+	vals := ndb.Vals("sys")
+
+	if i := sort.SearchStrings(vals, "localhost"); i == len(vals) || vals[i] != "localhost" {
+		t.Errorf("value %q missing from %q", "localhost", vals)
+	}
+
+	if vals := ndb.Vals("database"); len(vals) != 0 {
+		t.Errorf("expected no values for database, got %q", vals)
+	}
+}
+
+func TestNdbFiles(t *testing.T) {
+	ndb, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := ndb.Files()
+
+	if len(files) != 2 || files[0] != "testndb/local" || files[1] != "testndb/common" {
+		t.Fatalf("wrong files: %q", files)
+	}
+
+	for _, f := range files {
+		recs := ndb.FileRecords(f)
+
+		if len(recs) == 0 {
+			t.Errorf("%s: no records", f)
+		}
+
+		for i, rec := range recs {
+			if len(rec) == 0 {
+				t.Errorf("%s: record %d is empty", f, i)
+			}
+		}
+	}
+
+	if recs := ndb.FileRecords("testndb/nonexistent"); recs != nil {
+		t.Errorf("expected no records, got %d", len(recs))
+	}
+}
+
+func TestNdbWalk(t *testing.T) {
+	ndb, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found []Pos
+
+	ndb.Walk(func(rec Record, pos Pos) bool {
+		if rec[0].Attr == "database" || rec[0].Attr == "auth" {
+			found = append(found, pos)
+		}
+		return true
+	})
+
+	want := []Pos{Pos{"testndb/local", 5}, Pos{"testndb/local", 9}}
+
+	if len(found) != len(want) {
+		t.Fatalf("expected %v got %v", want, found)
+	}
+
+	for i := range want {
+		if found[i] != want[i] {
+			t.Errorf("expected %s got %s", want[i], found[i])
+		}
+	}
+
+	n := 0
+	ndb.Walk(func(rec Record, pos Pos) bool {
+		n++
+		return false
+	})
+
+	if n != 1 {
+		t.Errorf("walk did not stop: %d calls", n)
+	}
+}
+
+func TestNdbOpenDefault(t *testing.T) {
+	defer func(files []string) {
+		DefaultFiles = files
+	}(DefaultFiles)
+
+	os.Setenv("NDBTESTDIR", "testndb")
+	os.Unsetenv("NDBTESTUNSET")
+
+	DefaultFiles = []string{"$NDBTESTUNSET/local", "testndb/nonexistent", "${NDBTESTDIR}/local"}
+
+	ndb, err := Open("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := ndb.Files(); files[0] != "testndb/local" {
+		t.Errorf("expected testndb/local, got %q", files[0])
+	}
+
+	DefaultFiles = []string{"testndb/nonexistent"}
+
+	if _, err := Open(""); err == nil {
+		t.Errorf("expected error with no default files present")
+	}
+}
+
+func TestRecordKey(t *testing.T) {
+	defer func(attrs []string) {
+		KeyAttrs = attrs
+	}(KeyAttrs)
+
+	rec := Record{Tuple{"ip", "10.0.0.9"}, Tuple{"sys", "fir"}, Tuple{"dom", "fir.example.com"}}
+
+	if k := rec.Key(); k != (Tuple{"ip", "10.0.0.9"}) {
+		t.Errorf("expected first tuple, got %+v", k)
+	}
+
+	KeyAttrs = []string{"dom", "sys"}
+
+	if k := rec.Key(); k != (Tuple{"dom", "fir.example.com"}) {
+		t.Errorf("expected dom tuple, got %+v", k)
+	}
+
+	KeyAttrs = []string{"ether"}
+
+	if k := rec.Key(); k != (Tuple{"ip", "10.0.0.9"}) {
+		t.Errorf("expected fallback to first tuple, got %+v", k)
+	}
+
+	if k := (Record{}).Key(); k != (Tuple{}) {
+		t.Errorf("expected empty tuple, got %+v", k)
+	}
+}
+
+func TestNdbCat(t *testing.T) {
+	ndb, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ndb.Cat("testndb/ipnet"); err != nil {
+		t.Fatal(err)
+	}
+
+	// already present
+	if err := ndb.Cat("testndb/common"); err != nil {
+		t.Fatal(err)
+	}
+
+	files := ndb.Files()
+
+	if len(files) != 3 || files[2] != "testndb/ipnet" {
+		t.Fatalf("wrong files: %q", files)
+	}
+
+	if gw := ndb.Ipinfo("sys", "anna", "ipgw"); len(gw) != 1 || gw[0].Val != "135.104.117.1" {
+		t.Errorf("ipinfo across added file failed: %+v", gw)
+	}
+
+	if err := ndb.Cat("testndb/nonexistent"); err == nil {
+		t.Errorf("expected error adding nonexistent file")
+	}
+
+	if len(ndb.Files()) != 3 {
+		t.Errorf("failed Cat changed the database")
+	}
+}
+
+func TestNdbReopenFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local")
+	hosts := filepath.Join(dir, "hosts")
+
+	data := "database=\n\tfile=" + local + "\n\tfile=" + hosts + "\n"
+
+	if err := ioutil.WriteFile(local, []byte(data+"sys=fir\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(hosts, []byte("sys=pine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(local)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, err := db.ReloadStatus(); loaded.IsZero() || err != nil {
+		t.Errorf("bad status after open: %v %v", loaded, err)
+	}
+
+	// the first file changes, but the second is gone
+	if err := ioutil.WriteFile(local, []byte(data+"sys=spruce\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(hosts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.ValidateReload(); err == nil {
+		t.Errorf("expected validation to fail")
+	}
+
+	if _, err := db.ReloadStatus(); err != nil {
+		t.Errorf("validation recorded an error: %v", err)
+	}
+
+	if err := db.Reopen(); err == nil {
+		t.Fatal("expected reopen to fail")
+	}
+
+	if _, err := db.ReloadStatus(); err == nil {
+		t.Errorf("reload error not kept")
+	}
+
+	if db.Search("sys", "fir") == nil || db.Search("sys", "pine") == nil {
+		t.Errorf("old records not kept")
+	}
+
+	if db.Search("sys", "spruce") != nil {
+		t.Errorf("partial reload applied")
+	}
+
+	if changed, _ := db.Changed(); !changed {
+		t.Errorf("failed reload cleared Changed")
+	}
+
+	if err := ioutil.WriteFile(hosts, []byte("sys=pine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.ValidateReload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Search("sys", "spruce") != nil {
+		t.Errorf("validation replaced records")
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.ReloadStatus(); err != nil {
+		t.Errorf("reload error not cleared: %v", err)
+	}
+
+	if db.Search("sys", "spruce") == nil {
+		t.Errorf("retry did not load new records")
+	}
+}
+
+type PartialTest struct {
+	data    string
+	partial bool
+}
+
+var partialtests = []PartialTest{
+	PartialTest{"", false},
+	PartialTest{"sys=fir\n", false},
+	PartialTest{"sys=fir", false},
+	PartialTest{"sys=fir info=\"rack", true},
+	PartialTest{"sys=fir info=\"rack\n", false},
+	PartialTest{"sys=fir info=\"rack 3\"", false},
+	PartialTest{"sys=fir\n\tinfo=\"rack", true},
+	PartialTest{"sys=fir # \"comment", false},
+}
+
+func TestPartialWrite(t *testing.T) {
+	for _, pt := range partialtests {
+		if p := partial([]byte(pt.data)); p != pt.partial {
+			t.Errorf("%q: expected %v got %v", pt.data, pt.partial, p)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local")
+
+	if err := ioutil.WriteFile(local, []byte("sys=fir info=\"rack"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Open(local)
+
+	if perr, ok := err.(*PartialWriteError); !ok || perr.File != local || perr.RetryAfter <= 0 {
+		t.Errorf("expected PartialWriteError, got %v", err)
+	}
+}
+
+func TestMoveTupleFirst(t *testing.T) {
+	rec := Record{Tuple{"ip", "10.0.0.9"}, Tuple{"sys", "fir"}, Tuple{"dom", "fir.example.com"}, Tuple{"sys", "fir2"}}
+
+	want := Record{Tuple{"sys", "fir"}, Tuple{"ip", "10.0.0.9"}, Tuple{"dom", "fir.example.com"}, Tuple{"sys", "fir2"}}
+
+	if got := rec.MoveTupleFirst("sys"); !sameset(RecordSet{got}, RecordSet{want}) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+
+	if rec[0].Attr != "ip" {
+		t.Errorf("record modified: %v", rec)
+	}
+
+	if got := rec.MoveTupleFirst("ether"); !sameset(RecordSet{got}, RecordSet{rec}) {
+		t.Errorf("expected unchanged record, got %v", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	db, err := Parse(strings.NewReader("sys=a ip=10.0.0.1\n\nsys=b\n\tip=10.0.0.2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("ip", "10.0.0.2"); len(recs) != 1 || recs[0].Search("sys") != "b" {
+		t.Errorf("ip=10.0.0.2 got %v", recs)
+	}
+
+	db.Walk(func(rec Record, pos Pos) bool {
+		if pos.File != "" {
+			t.Errorf("parsed record at %s", pos)
+		}
+		return true
+	})
+
+	if changed, err := db.Changed(); err != nil || changed {
+		t.Errorf("Changed = %v, %v", changed, err)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Error(err)
+	}
+
+	if err := db.Cat("testndb/common"); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("dom", "A.ROOT-SERVERS.NET"); len(recs) != 1 {
+		t.Errorf("record from Cat not found: %v", recs)
+	}
+
+	if _, err := Parse(strings.NewReader("sys=a\n"), WithLimits(0, 0)); err != nil {
+		t.Error(err)
+	}
+}
+
+type ParseErrorTest struct {
+	data   string
+	line   int
+	column int
+	text   string
+}
+
+var parseerrortests = []ParseErrorTest{
+	{"=a\n", 1, 1, "=a"},
+	{"sys=a\n\tip=10.0.0.1 =\"x y\"\n", 2, 14, "=\"x y\""},
+	{"# comment\n\nsys=a\nsys=b dom= =c\n", 4, 12, "=c"},
+	{"sys=" + strings.Repeat("a", 70000) + "\n", 1, 0, ""},
+}
+
+func TestParseError(t *testing.T) {
+	for i, test := range parseerrortests {
+		_, err := Parse(strings.NewReader(test.data))
+
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("test %d: got %v, want a ParseError", i, err)
+			continue
+		}
+
+		if perr.Line != test.line || perr.Column != test.column || perr.Text != test.text {
+			t.Errorf("test %d: got line %d column %d text %q, want %d %d %q", i, perr.Line, perr.Column, perr.Text, test.line, test.column, test.text)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	if err := ioutil.WriteFile(fname, []byte("sys=a\n=b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Open(fname)
+	if perr, ok := err.(*ParseError); !ok || perr.File != fname || perr.Err != ErrNoAttr {
+		t.Errorf("open got %v", err)
+	}
+
+	if want := "parse: " + fname + ":2:1: tuple has no attribute: \"=b\""; err.Error() != want {
+		t.Errorf("got %q want %q", err, want)
+	}
+
+	if d := ErrorDiagnostic(err); d.File != fname || d.Line != 2 {
+		t.Errorf("diagnostic at %s:%d", d.File, d.Line)
+	}
 }
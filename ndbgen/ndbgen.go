@@ -0,0 +1,220 @@
+// Package ndbgen generates realistic ndb databases of any size, with
+// subnets, hosts and services, for benchmarks, fuzzing seeds and
+// demos. The same Config always generates the same database.
+package ndbgen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+)
+
+// What to generate. The zero Config generates 100 hosts on 4 subnets
+// of 10.0.0.0/8 in example.com.
+type Config struct {
+	Seed     int64  // Seed for the random choices
+	Domain   string // Domain of the hosts, default example.com
+	Network  string // Network the subnets are carved from, default 10.0.0.0/8
+	Subnets  int    // Number of subnets, default 4
+	Hosts    int    // Number of hosts besides the gateways and name server, default 100
+	Services bool   // Whether to add tcp= and udp= service records
+}
+
+// Host names, numbered when they run out.
+var names = []string{
+	"anna", "bela", "cyan", "dale", "echo", "fern", "gull", "hazel",
+	"iris", "jade", "kite", "lark", "moss", "nova", "onyx", "pike",
+	"quill", "reed", "sage", "tern", "umber", "vale", "wren", "yew",
+}
+
+// Roles of hosts, with tuples hosts in them get.
+var roles = []struct {
+	name   string
+	extra  []ndb.Tuple
+	weight int
+}{
+	{"workstation", nil, 8},
+	{"server", []ndb.Tuple{{Attr: "service", Val: "http"}}, 4},
+	{"printer", []ndb.Tuple{{Attr: "service", Val: "ipp"}}, 1},
+	{"ap", nil, 1},
+	{"switch", nil, 1},
+}
+
+// Services as in /lib/ndb/common.
+var services = []ndb.Record{
+	{{Attr: "tcp", Val: "ssh"}, {Attr: "port", Val: "22"}},
+	{{Attr: "tcp", Val: "smtp"}, {Attr: "port", Val: "25"}},
+	{{Attr: "udp", Val: "dns"}, {Attr: "port", Val: "53"}},
+	{{Attr: "tcp", Val: "dns"}, {Attr: "port", Val: "53"}},
+	{{Attr: "udp", Val: "bootps"}, {Attr: "port", Val: "67"}},
+	{{Attr: "tcp", Val: "http"}, {Attr: "port", Val: "80"}},
+	{{Attr: "udp", Val: "ntp"}, {Attr: "port", Val: "123"}},
+	{{Attr: "tcp", Val: "https"}, {Attr: "port", Val: "443"}},
+	{{Attr: "tcp", Val: "ipp"}, {Attr: "port", Val: "631"}},
+	{{Attr: "tcp", Val: "9fs"}, {Attr: "port", Val: "564"}},
+}
+
+// Generate a database as c says: an ipnet record for the network
+// naming its name server, one for each subnet with its gateway, then
+// the gateways, the name server and the hosts, spread evenly over the
+// subnets, and the services last. Returns an error if the hosts don't
+// fit in the network.
+func Generate(c *Config) (ndb.RecordSet, error) {
+	cfg := Config{Domain: "example.com", Network: "10.0.0.0/8", Subnets: 4, Hosts: 100}
+	if c != nil {
+		cfg.Seed, cfg.Services = c.Seed, c.Services
+		if c.Domain != "" {
+			cfg.Domain = c.Domain
+		}
+		if c.Network != "" {
+			cfg.Network = c.Network
+		}
+		if c.Subnets > 0 {
+			cfg.Subnets = c.Subnets
+		}
+		if c.Hosts > 0 {
+			cfg.Hosts = c.Hosts
+		}
+	}
+
+	_, network, err := net.ParseCIDR(cfg.Network)
+	if err != nil {
+		return nil, fmt.Errorf("ndbgen: %s", err)
+	}
+
+	base := network.IP.To4()
+	if base == nil {
+		return nil, fmt.Errorf("ndbgen: %s is not an IPv4 network", cfg.Network)
+	}
+	netbits, _ := network.Mask.Size()
+
+	// each subnet has its network, gateway and broadcast addresses,
+	// and the first the name server too
+	per := (cfg.Hosts+cfg.Subnets-1)/cfg.Subnets + 4
+	bits := 2
+	for 1<<uint(bits) < per {
+		bits++
+	}
+
+	subbits := 0
+	for 1<<uint(subbits) < cfg.Subnets {
+		subbits++
+	}
+
+	if netbits+subbits+bits > 32 {
+		return nil, fmt.Errorf("ndbgen: %d hosts on %d subnets don't fit in %s", cfg.Hosts, cfg.Subnets, cfg.Network)
+	}
+
+	r := rand.New(rand.NewSource(cfg.Seed))
+	start := binary.BigEndian.Uint32(base)
+	mask := net.IP(net.CIDRMask(32-bits, 32)).String()
+
+	addr := func(subnet, host int) string {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, start+uint32(subnet)<<uint(bits)+uint32(host))
+		return ip.String()
+	}
+
+	ns := addr(0, 2)
+
+	recs := ndb.RecordSet{{
+		{Attr: "ipnet", Val: label(cfg.Domain)},
+		{Attr: "ip", Val: base.String()},
+		{Attr: "ipmask", Val: net.IP(network.Mask).String()},
+		{Attr: "dom", Val: cfg.Domain},
+		{Attr: "dns", Val: ns},
+		{Attr: "ntp", Val: ns},
+		{Attr: "auth", Val: "ns"},
+	}}
+
+	for i := 0; i < cfg.Subnets; i++ {
+		recs = append(recs, ndb.Record{
+			{Attr: "ipnet", Val: fmt.Sprintf("net%d", i)},
+			{Attr: "ip", Val: addr(i, 0)},
+			{Attr: "ipmask", Val: mask},
+			{Attr: "ipgw", Val: addr(i, 1)},
+		})
+	}
+
+	used := make(map[string]int)
+	nether := 0
+
+	host := func(name, ip, role string, extra []ndb.Tuple) ndb.Record {
+		nether++
+		rec := ndb.Record{
+			{Attr: "sys", Val: name},
+			{Attr: "dom", Val: name + "." + cfg.Domain},
+			{Attr: "ip", Val: ip},
+			{Attr: "ether", Val: fmt.Sprintf("00163e%06x", nether)},
+			{Attr: "role", Val: role},
+		}
+		return append(rec, extra...)
+	}
+
+	for i := 0; i < cfg.Subnets; i++ {
+		recs = append(recs, host(fmt.Sprintf("gw%d", i), addr(i, 1), "router", nil))
+	}
+	recs = append(recs, host("ns", ns, "server", []ndb.Tuple{{Attr: "service", Val: "dns"}}))
+
+	total := 0
+	for _, role := range roles {
+		total += role.weight
+	}
+
+	next := make([]int, cfg.Subnets)
+	for i := range next {
+		next[i] = 2
+	}
+	next[0] = 3
+
+	for i := 0; i < cfg.Hosts; i++ {
+		subnet := i % cfg.Subnets
+
+		name := names[r.Intn(len(names))]
+		if used[name]++; used[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, used[name])
+		}
+
+		w := r.Intn(total)
+		role := roles[0]
+		for _, role = range roles {
+			if w -= role.weight; w < 0 {
+				break
+			}
+		}
+
+		recs = append(recs, host(name, addr(subnet, next[subnet]), role.name, role.extra))
+		next[subnet]++
+	}
+
+	if cfg.Services {
+		for _, rec := range services {
+			recs = append(recs, append(ndb.Record(nil), rec...))
+		}
+	}
+
+	return recs, nil
+}
+
+// Write the database Generate returns to w as ndb text.
+func Write(w io.Writer, c *Config) error {
+	recs, err := Generate(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := recs.WriteTo(w); err != nil {
+		return fmt.Errorf("ndbgen: %s", err)
+	}
+
+	return nil
+}
+
+// Return the first label of the domain dom.
+func label(dom string) string {
+	return strings.SplitN(dom, ".", 2)[0]
+}
@@ -0,0 +1,72 @@
+package ndbgen
+
+import (
+	"bytes"
+	"github.com/mischief/ndb"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	c := &Config{Seed: 7, Subnets: 3, Hosts: 50, Services: true}
+
+	var a, b bytes.Buffer
+	if err := Write(&a, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("same config generated different databases")
+	}
+
+	db, err := ndb.Parse(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := db.Search("role", "")
+	if len(hosts) != 50+3+1 {
+		t.Errorf("got %d hosts, want %d", len(hosts), 50+3+1)
+	}
+
+	seen := make(map[string]bool)
+	for _, rec := range hosts {
+		for _, attr := range []string{"sys", "ip", "ether"} {
+			val := attr + "=" + rec.Search(attr)
+			if seen[val] {
+				t.Errorf("%s used twice", val)
+			}
+			seen[val] = true
+		}
+	}
+
+	last := hosts[len(hosts)-1]
+	info := db.Ipinfo("sys", last.Search("sys"), "ipgw", "dns", "dom")
+	if info.Search("ipgw") == "" || info.Search("dns") != "10.0.0.2" {
+		t.Errorf("ipinfo of %s: %s", last, info)
+	}
+
+	if rec := db.Search("tcp", "ssh"); len(rec) != 1 || rec[0].Search("port") != "22" {
+		t.Errorf("services: %v", rec)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []*Config{
+		&Config{Network: "10.0.0.0/30"},
+		&Config{Network: "fd00::/64"},
+		&Config{Network: "bogus"},
+	}
+
+	for _, c := range tests {
+		if _, err := Generate(c); err == nil {
+			t.Errorf("%s: no error", c.Network)
+		}
+	}
+
+	if recs, err := Generate(&Config{Network: "192.168.0.0/24", Subnets: 1, Hosts: 250}); err != nil || len(recs) != 2+1+1+250 {
+		t.Errorf("full /24: %d records, %v", len(recs), err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package ndbtest helps programs using package ndb test their records
+// against golden ndb text, reporting differences as ndb text.
+//
+// Golden files are ndb files. Run tests with -ndbtest.update to
+// rewrite them from the records the tests got.
+package ndbtest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mischief/ndb"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("ndbtest.update", false, "rewrite golden files with the records tests got")
+
+// Parse ndb text, such as a snippet in a test, into records.
+// Fails the test if the text doesn't parse.
+func Records(t testing.TB, text string) ndb.RecordSet {
+	t.Helper()
+
+	db, err := ndb.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("ndbtest: %s", err)
+	}
+
+	return db.FileRecords("")
+}
+
+// Read the records of the golden file fname. Fails the test if it
+// can't be read.
+func LoadGolden(t testing.TB, fname string) ndb.RecordSet {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("ndbtest: %s", err)
+	}
+
+	db, err := ndb.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ndbtest: %s: %s", fname, err)
+	}
+
+	return db.FileRecords("")
+}
+
+// Report an error if got and want differ, tuple for tuple in order,
+// listing the records missing from got and those it has extra as ndb
+// text.
+func AssertRecordsEqual(t testing.TB, got, want ndb.RecordSet) {
+	t.Helper()
+
+	if diff := Diff(got, want); diff != "" {
+		t.Errorf("records differ (-want +got):\n%s", diff)
+	}
+}
+
+// Compare got with the records of the golden file fname as
+// AssertRecordsEqual does, or rewrite the file with got when tests are
+// run with -ndbtest.update.
+func AssertGolden(t testing.TB, fname string, got ndb.RecordSet) {
+	t.Helper()
+
+	if *update {
+		var buf bytes.Buffer
+		if _, err := got.WriteTo(&buf); err != nil {
+			t.Fatalf("ndbtest: %s", err)
+		}
+		if err := ioutil.WriteFile(fname, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("ndbtest: %s", err)
+		}
+		return
+	}
+
+	AssertRecordsEqual(t, got, LoadGolden(t, fname))
+}
+
+// Return the differences between got and want as ndb text, with
+// records only in want marked - and those only in got marked +, in
+// order. Returns "" if they are the same.
+func Diff(got, want ndb.RecordSet) string {
+	g, w := texts(got), texts(want)
+
+	// longest common subsequence of the records
+	lcs := make([][]int, len(w)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(g)+1)
+	}
+
+	for i := len(w) - 1; i >= 0; i-- {
+		for j := len(g) - 1; j >= 0; j-- {
+			switch {
+			case w[i] == g[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	mark := func(m string, text string) {
+		for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			fmt.Fprintf(&buf, "%s %s\n", m, line)
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(w) || j < len(g) {
+		switch {
+		case i < len(w) && j < len(g) && w[i] == g[j]:
+			i++
+			j++
+		case j == len(g) || (i < len(w) && lcs[i+1][j] >= lcs[i][j+1]):
+			mark("-", w[i])
+			i++
+		default:
+			mark("+", g[j])
+			j++
+		}
+	}
+
+	return buf.String()
+}
+
+// Return each record as ndb text.
+func texts(recs ndb.RecordSet) []string {
+	var out []string
+
+	for _, rec := range recs {
+		var buf bytes.Buffer
+		if _, err := rec.WriteTo(&buf); err != nil {
+			// not writable as ndb text; show it as Go would
+			fmt.Fprintf(&buf, "%q\n", rec)
+		}
+		out = append(out, buf.String())
+	}
+
+	return out
+}
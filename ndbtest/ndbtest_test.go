@@ -0,0 +1,36 @@
+package ndbtest
+
+import (
+	"testing"
+)
+
+type DiffTest struct {
+	got, want string
+	diff      string
+}
+
+var difftests = []DiffTest{
+	DiffTest{"sys=a\nsys=b\n", "sys=a\nsys=b\n", ""},
+	DiffTest{"sys=a\nsys=c\n", "sys=a\nsys=b\n", "- sys=b\n+ sys=c\n"},
+	DiffTest{"sys=a\n", "sys=a\nsys=b ip=10.0.0.2\n", "- sys=b ip=10.0.0.2\n"},
+	DiffTest{"sys=b\nsys=a\n", "sys=a\nsys=b\n", "- sys=a\n+ sys=a\n"},
+	DiffTest{"sys=a info=\"x y\"\n", "sys=a info=x\n", "- sys=a info=x\n+ sys=a info=\"x y\"\n"},
+}
+
+func TestDiff(t *testing.T) {
+	for i, test := range difftests {
+		if diff := Diff(Records(t, test.got), Records(t, test.want)); diff != test.diff {
+			t.Errorf("test %d: got %q want %q", i, diff, test.diff)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	got := Records(t, `
+sys=anna ip=10.1.0.10 dom=anna.example.com
+sys=bob ip=10.1.0.11 info="rack 3"
+`)
+
+	AssertGolden(t, "testdata/hosts.ndb", got)
+	AssertRecordsEqual(t, got, LoadGolden(t, "testdata/hosts.ndb"))
+}
@@ -0,0 +1,241 @@
+// Package netbox imports devices and IP assignments from a NetBox
+// server (https://github.com/netbox-community/netbox) as ndb records.
+package netbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mischief/ndb"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// A NetBox API client.
+type Client struct {
+	URL   string       // Base URL, e.g. https://netbox.example.com
+	Token string       // API token
+	HTTP  *http.Client // Client to use, or nil for http.DefaultClient
+}
+
+// One page of an API list.
+type page struct {
+	Next    string            `json:"next"`
+	Results []json.RawMessage `json:"results"`
+}
+
+type ref struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type prefix struct {
+	Prefix      string `json:"prefix"`
+	Description string `json:"description"`
+}
+
+type device struct {
+	Name       string `json:"name"`
+	Role       *ref   `json:"role"`
+	DeviceRole *ref   `json:"device_role"` // before NetBox 4.0
+	Platform   *ref   `json:"platform"`
+	Site       *ref   `json:"site"`
+}
+
+type iface struct {
+	Name       string `json:"name"`
+	Device     *ref   `json:"device"`
+	MacAddress string `json:"mac_address"`
+}
+
+type ipaddress struct {
+	Address        string `json:"address"`
+	DNSName        string `json:"dns_name"`
+	AssignedObject *struct {
+		Name   string `json:"name"`
+		Device *ref   `json:"device"`
+	} `json:"assigned_object"`
+}
+
+// Fetch every page of an API list, decoding each result with fn.
+func (c *Client) list(path string, fn func(json.RawMessage) error) error {
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	next := strings.TrimSuffix(c.URL, "/") + path + "?limit=1000"
+
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Accept", "application/json")
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Token "+c.Token)
+		}
+
+		resp, err := hc.Do(req)
+		if err != nil {
+			return err
+		}
+
+		var p page
+		err = json.NewDecoder(resp.Body).Decode(&p)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("netbox: %s: %s", path, resp.Status)
+		}
+
+		if err != nil {
+			return fmt.Errorf("netbox: %s: %s", path, err)
+		}
+
+		for _, r := range p.Results {
+			if err := fn(r); err != nil {
+				return fmt.Errorf("netbox: %s: %s", path, err)
+			}
+		}
+
+		next = p.Next
+	}
+
+	return nil
+}
+
+// Import prefixes, devices and IP addresses from NetBox.
+//
+// Each prefix becomes an ipnet record, named by its description or
+// else the prefix. Each device becomes a host record with sys=, its
+// ip= and dom= from assigned addresses, ether= from interface MAC
+// addresses, and role=, os= and site= from its role, platform and
+// site. Addresses not assigned to a device become records of their own.
+func (c *Client) Import() (ndb.RecordSet, error) {
+	var recs ndb.RecordSet
+
+	err := c.list("/api/ipam/prefixes/", func(raw json.RawMessage) error {
+		var p prefix
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+
+		_, ipn, err := net.ParseCIDR(p.Prefix)
+		if err != nil {
+			return err
+		}
+
+		name := p.Description
+		if name == "" {
+			name = p.Prefix
+		}
+
+		ones, _ := ipn.Mask.Size()
+		recs = append(recs, ndb.Record{
+			ndb.Tuple{Attr: "ipnet", Val: name},
+			ndb.Tuple{Attr: "ip", Val: ipn.IP.String()},
+			ndb.Tuple{Attr: "ipmask", Val: fmt.Sprintf("/%d", ones)},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	hosts := make(map[string]ndb.Record)
+
+	err = c.list("/api/dcim/devices/", func(raw json.RawMessage) error {
+		var d device
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return err
+		}
+
+		if d.Name == "" {
+			return nil
+		}
+
+		if d.Role == nil {
+			d.Role = d.DeviceRole
+		}
+
+		rec := ndb.Record{ndb.Tuple{Attr: "sys", Val: d.Name}}
+		for _, t := range []struct {
+			attr string
+			r    *ref
+		}{{"role", d.Role}, {"os", d.Platform}, {"site", d.Site}} {
+			if t.r != nil && t.r.Slug != "" {
+				rec = append(rec, ndb.Tuple{Attr: t.attr, Val: t.r.Slug})
+			}
+		}
+
+		order = append(order, d.Name)
+		hosts[d.Name] = rec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.list("/api/dcim/interfaces/", func(raw json.RawMessage) error {
+		var i iface
+		if err := json.Unmarshal(raw, &i); err != nil {
+			return err
+		}
+
+		if i.Device == nil || i.MacAddress == "" || hosts[i.Device.Name] == nil {
+			return nil
+		}
+
+		hosts[i.Device.Name] = append(hosts[i.Device.Name], ndb.Tuple{Attr: "ether", Val: ether(i.MacAddress)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var loose ndb.RecordSet
+
+	err = c.list("/api/ipam/ip-addresses/", func(raw json.RawMessage) error {
+		var a ipaddress
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return err
+		}
+
+		ip, _, err := net.ParseCIDR(a.Address)
+		if err != nil {
+			return err
+		}
+
+		tuples := ndb.Record{ndb.Tuple{Attr: "ip", Val: ip.String()}}
+		if a.DNSName != "" {
+			tuples = append(tuples, ndb.Tuple{Attr: "dom", Val: a.DNSName})
+		}
+
+		if a.AssignedObject != nil && a.AssignedObject.Device != nil {
+			if host := hosts[a.AssignedObject.Device.Name]; host != nil {
+				hosts[a.AssignedObject.Device.Name] = append(host, tuples...)
+				return nil
+			}
+		}
+
+		loose = append(loose, tuples)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range order {
+		recs = append(recs, hosts[name])
+	}
+
+	return append(recs, loose...), nil
+}
+
+// Convert a MAC address to the 12 hex digit form used by ndb.
+func ether(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+}
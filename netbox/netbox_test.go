@@ -0,0 +1,80 @@
+package netbox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Canned API responses. Devices are split over two pages.
+var responses = map[string]string{
+	"/api/ipam/prefixes/?limit=1000": `{"next": null, "results": [
+		{"prefix": "10.1.2.0/24", "description": "lab"},
+		{"prefix": "10.9.0.0/16", "description": ""}]}`,
+	"/api/dcim/devices/?limit=1000": `{"next": "%s/api/dcim/devices/?limit=1000&offset=1", "results": [
+		{"name": "fir", "role": {"slug": "web"}, "platform": {"slug": "9front"}, "site": {"slug": "nyc"}}]}`,
+	"/api/dcim/devices/?limit=1000&offset=1": `{"next": null, "results": [
+		{"name": "oak", "device_role": {"slug": "db"}, "platform": null, "site": null},
+		{"name": ""}]}`,
+	"/api/dcim/interfaces/?limit=1000": `{"next": null, "results": [
+		{"name": "eth0", "device": {"name": "fir"}, "mac_address": "00:11:22:AA:BB:CC"},
+		{"name": "eth1", "device": {"name": "fir"}, "mac_address": null}]}`,
+	"/api/ipam/ip-addresses/?limit=1000": `{"next": null, "results": [
+		{"address": "10.1.2.10/24", "dns_name": "fir.example.com", "assigned_object": {"name": "eth0", "device": {"name": "fir"}}},
+		{"address": "10.1.2.11/24", "dns_name": "", "assigned_object": {"name": "eth0", "device": {"name": "oak"}}},
+		{"address": "10.9.0.5/16", "dns_name": "vip.example.com", "assigned_object": null}]}`,
+}
+
+func TestImport(t *testing.T) {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, body, srv.URL)
+	}))
+
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, Token: "secret"}
+
+	recs, err := c.Import()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ipnet=lab ip=10.1.2.0 ipmask=/24",
+		"ipnet=10.9.0.0/16 ip=10.9.0.0 ipmask=/16",
+		"sys=fir role=web os=9front site=nyc ether=001122aabbcc ip=10.1.2.10 dom=fir.example.com",
+		"sys=oak role=db ip=10.1.2.11",
+		"ip=10.9.0.5 dom=vip.example.com",
+	}
+
+	if len(recs) != len(want) {
+		t.Fatalf("expected %d records got %d: %v", len(want), len(recs), recs)
+	}
+
+	for i := range want {
+		if recs[i].String() != want[i] {
+			t.Errorf("record %d: expected %q got %q", i, want[i], recs[i].String())
+		}
+	}
+
+	c.Token = "wrong"
+
+	if _, err := c.Import(); err == nil {
+		t.Errorf("expected error with bad token")
+	}
+}
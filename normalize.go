@@ -0,0 +1,93 @@
+package ndb
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Match values of attr after putting both the stored value and the
+// value searched for through fn, so lookups survive differences in
+// formatting such as case or leading zeros. Records keep their values
+// as written. A later normalizer for the same attribute replaces an
+// earlier one. NormalizeDom, NormalizeIP and NormalizeEther suit the
+// dom=, ip= and ether= attributes.
+func WithNormalizer(attr string, fn func(string) string) Option {
+	return func(o *options) {
+		if o.normalizers == nil {
+			o.normalizers = make(map[string]func(string) string)
+		}
+		o.normalizers[attr] = fn
+	}
+}
+
+// Whether the stored value of attr matches the value searched for.
+func (o *options) sameval(attr, stored, val string) bool {
+	if stored == val {
+		return true
+	}
+
+	if o == nil {
+		return false
+	}
+
+	if fn, ok := o.normalizers[attr]; ok {
+		return fn(stored) == fn(val)
+	}
+
+	return false
+}
+
+// Normalize a value of attr, if it has a normalizer.
+func (o *options) normalize(attr, val string) string {
+	if fn, ok := o.normalizers[attr]; ok {
+		return fn(val)
+	}
+
+	return val
+}
+
+// Lower-case a domain name and drop any trailing dot.
+func NormalizeDom(s string) string {
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+// Put an IP address in the form net.IP prints it, after removing
+// leading zeros from the octets of an IPv4 address, so 010.1.2.003 and
+// 10.1.2.3 match, as do differently written IPv6 addresses. Other
+// values are returned unchanged.
+func NormalizeIP(s string) string {
+	if parts := strings.Split(s, "."); len(parts) == 4 {
+		for i, p := range parts {
+			n, err := strconv.ParseUint(p, 10, 8)
+			if err != nil {
+				return s
+			}
+			parts[i] = strconv.FormatUint(n, 10)
+		}
+		s = strings.Join(parts, ".")
+	}
+
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+
+	return s
+}
+
+// Reduce an Ethernet address to lower-case hex digits, as ndb writes
+// them, so 00:11:22:AA:BB:CC, 00-11-22-aa-bb-cc, 0011.22aa.bbcc and
+// 0:11:22:aa:bb:cc, with leading zeros dropped as BSD arp(8) prints
+// them, all match 001122aabbcc.
+func NormalizeEther(s string) string {
+	if parts := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' }); len(parts) == 6 {
+		for i, p := range parts {
+			if len(p) == 1 {
+				parts[i] = "0" + p
+			}
+		}
+		s = strings.Join(parts, "")
+	}
+
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(s))
+}
@@ -0,0 +1,70 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type NormalizeTest struct {
+	fn       func(string) string
+	in, want string
+}
+
+var normalizetests = []NormalizeTest{
+	{NormalizeDom, "Fir.Example.COM.", "fir.example.com"},
+	{NormalizeIP, "010.001.002.003", "10.1.2.3"},
+	{NormalizeIP, "2001:DB8:0:0::1", "2001:db8::1"},
+	{NormalizeIP, "10.1.2.300", "10.1.2.300"},
+	{NormalizeIP, "fir", "fir"},
+	{NormalizeEther, "00:11:22:AA:BB:CC", "001122aabbcc"},
+	{NormalizeEther, "0011.22aa.bbcc", "001122aabbcc"},
+	{NormalizeEther, "0:11:22:aa:b:CC", "001122aa0bcc"},
+}
+
+func TestNormalizers(t *testing.T) {
+	for _, nt := range normalizetests {
+		if got := nt.fn(nt.in); got != nt.want {
+			t.Errorf("%q: expected %q got %q", nt.in, nt.want, got)
+		}
+	}
+}
+
+func TestWithNormalizer(t *testing.T) {
+	plain, err := Open("testndb/report")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open("testndb/report", WithNormalizer("dom", NormalizeDom), WithNormalizer("ip", NormalizeIP), WithNormalizer("ether", NormalizeEther))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []Tuple{
+		{"dom", "GW.example.com."},
+		{"ip", "010.1.2.001"},
+		{"ether", "00:00:00:00:00:01"},
+	}
+
+	for _, q := range queries {
+		if recs := plain.Search(q.Attr, q.Val); recs != nil {
+			t.Errorf("%s=%s: matched without normalizers", q.Attr, q.Val)
+		}
+
+		recs := db.Search(q.Attr, q.Val)
+		if len(recs) != 1 || recs[0].Search("sys") != "gw" {
+			t.Errorf("%s=%s: expected gw, got %v", q.Attr, q.Val, recs)
+			continue
+		}
+
+		// values are returned as written
+		if recs[0].Search("dom") != "gw.example.com" {
+			t.Errorf("stored value changed: %v", recs[0])
+		}
+	}
+
+	if ipgw := db.Ipinfo("ip", "10.1.2.01", "ipgw"); len(ipgw) != 1 {
+		t.Errorf("expected ipinfo through a normalized ip, got %v", ipgw)
+	}
+}
@@ -0,0 +1,20 @@
+package ndb
+
+import (
+	"strconv"
+)
+
+// Search for records with an attr= whose value is an integer between
+// lo and hi inclusive, such as vlan=100 to vlan=199. Values that are
+// not decimal integers never match. Returns no records (nil) if none
+// match.
+func (n *Ndb) SearchRange(attr string, lo, hi int64) RecordSet {
+	return n.match(func(rec Record) bool {
+		for _, tuple := range rec.find(attr) {
+			if v, err := strconv.ParseInt(tuple.Val, 10, 64); err == nil && v >= lo && v <= hi {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,47 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type RangeTest struct {
+	attr   string
+	lo, hi int64
+	expect []string
+}
+
+var rangetests = []RangeTest{
+	{"vlan", 100, 199, []string{"sw1", "a", "b"}},
+	{"vlan", 150, 150, []string{"sw1", "b"}},
+	{"vlan", 200, 299, []string{"c"}},
+	{"vlan", 300, 400, nil},
+	{"asn", 64512, 65534, []string{"c"}},
+	{"port", 1, 10, nil},
+}
+
+func TestSearchRange(t *testing.T) {
+	db, err := Open("testndb/numeric")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rt := range rangetests {
+		var got []string
+		for _, rec := range db.SearchRange(rt.attr, rt.lo, rt.hi) {
+			got = append(got, rec.Search("sys"))
+		}
+
+		if len(got) != len(rt.expect) {
+			t.Errorf("%s %d-%d: expected %v got %v", rt.attr, rt.lo, rt.hi, rt.expect, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != rt.expect[i] {
+				t.Errorf("%s %d-%d: expected %v got %v", rt.attr, rt.lo, rt.hi, rt.expect, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package ndb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// An option changing how Open loads the database.
+// Options apply to every file in the database, including those
+// added later by Cat, and are kept by Reopen.
+type Option func(*options)
+
+type options struct {
+	selectors   []Tuple             // Only keep records matching these, see WithSelector
+	loadfilters []func(Record) bool // Only keep records passing these, see WithLoadFilter
+	keepattrs   map[string]bool     // Only keep tuples with these attributes, see WithAttrs
+
+	maxrecords int // Limits on the whole database, see WithLimits
+	maxtuples  int
+
+	single bool // Ignore database= records, see Single
+
+	normalizers map[string]func(string) string // See WithNormalizer
+
+	bloomattrs map[string]bool // See WithBloom
+
+	parallel int // Database size to search in parallel, see WithParallelSearch
+
+	lazy bool // Parse chained files when first needed, see Lazy
+
+	files filesys // Where files are read from, see OpenFS; nil for the os
+
+	timeout time.Duration // Longest a search may take, see WithSearchTimeout
+
+	readonly bool // Refuse writes and share results, see ReadOnly
+}
+
+// A source of database files other than the operating system.
+type filesys interface {
+	Open(name string) (dbfile, error)
+	Stat(name string) (os.FileInfo, error)
+}
+
+// An open database file, as returned by os.Open and fs.FS.
+type dbfile interface {
+	io.Reader
+	Stat() (os.FileInfo, error)
+	Close() error
+}
+
+// Open a database file.
+func (o *options) open(name string) (dbfile, error) {
+	if o.files != nil {
+		return o.files.Open(name)
+	}
+
+	return os.Open(name)
+}
+
+// Return information about a database file.
+func (o *options) stat(name string) (os.FileInfo, error) {
+	if o.files != nil {
+		return o.files.Stat(name)
+	}
+
+	return os.Stat(name)
+}
+
+// Return the first of names naming the same file as name, such as
+// through a symbolic link or another path to it, or "" if none does.
+func (o *options) samefile(names []string, name string) string {
+	fi, err := o.stat(name)
+
+	for _, other := range names {
+		if filepath.Clean(other) == filepath.Clean(name) {
+			return other
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if ofi, err := o.stat(other); err == nil && os.SameFile(fi, ofi) {
+			return other
+		}
+	}
+
+	return ""
+}
+
+// Open only the named file, without the files its database= record
+// lists, for tools that work on one file at a time.
+func Single() Option {
+	return func(o *options) {
+		o.single = true
+	}
+}
+
+// Error returned when a database is bigger than allowed by WithLimits.
+type LimitError struct {
+	What  string // "records" or "tuples"
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("open: more than %d %s", e.Limit, e.What)
+}
+
+// Limit the database to at most records records and tuples tuples in
+// all its files, so a program pointed at a huge file fails with a
+// LimitError instead of using all its memory. Zero means no limit.
+// Records dropped by WithSelector are not counted. A file is parsed
+// only until it alone passes a limit.
+func WithLimits(records, tuples int) Option {
+	return func(o *options) {
+		o.maxrecords = records
+		o.maxtuples = tuples
+	}
+}
+
+// Check the files together are within the limits.
+func (o *options) check(dbs []*Ndb) error {
+	nrec, ntup := 0, 0
+
+	for _, db := range dbs {
+		nrec += len(db.records)
+		for _, rec := range db.records {
+			ntup += len(rec)
+		}
+	}
+
+	return o.limit(nrec, ntup)
+}
+
+// Check nrec records of ntup tuples are within the limits.
+func (o *options) limit(nrec, ntup int) error {
+	if o.maxrecords > 0 && nrec > o.maxrecords {
+		return &LimitError{"records", o.maxrecords}
+	}
+
+	if o.maxtuples > 0 && ntup > o.maxtuples {
+		return &LimitError{"tuples", o.maxtuples}
+	}
+
+	return nil
+}
+
+// Load only the records meant for attr=val, such as a site or
+// environment. Records with no attr tuple are shared and always kept;
+// records with attr tuples are kept only if one of them has the value
+// val. With several selectors, a record must pass each of them.
+func WithSelector(attr, val string) Option {
+	return func(o *options) {
+		o.selectors = append(o.selectors, Tuple{attr, val})
+	}
+}
+
+// Whether a record passes the selectors.
+func (o *options) selected(rec Record) bool {
+	for _, sel := range o.selectors {
+		found := rec.find(sel.Attr)
+		if found == nil {
+			continue
+		}
+
+		ok := false
+		for _, tuple := range found {
+			if tuple.Val == sel.Val {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Load only the records for which keep returns true, such as those
+// with an ip= for a DNS server, to save memory on records a program
+// never looks at. Records are given to keep as they are in the file,
+// without template tuples. database= records are always kept, so the
+// other files are still found. With several filters, a record must
+// pass each of them.
+func WithLoadFilter(keep func(Record) bool) Option {
+	return func(o *options) {
+		o.loadfilters = append(o.loadfilters, keep)
+	}
+}
+
+// Whether a record passes the load filters.
+func (o *options) kept(rec Record) bool {
+	if rec.find("database") != nil {
+		return true
+	}
+
+	for _, keep := range o.loadfilters {
+		if !keep(rec) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Load only the tuples with attributes in attrs, such as ip, dom and
+// ether for a program that needs nothing else from a large shared
+// database. Records left with no tuples are dropped. database= and
+// file= tuples are always kept, so the other files are still found.
+// Selectors and load filters see whole records.
+func WithAttrs(attrs ...string) Option {
+	return func(o *options) {
+		if o.keepattrs == nil {
+			o.keepattrs = map[string]bool{"database": true, "file": true}
+		}
+		for _, attr := range attrs {
+			o.keepattrs[attr] = true
+		}
+	}
+}
+
+// Return the tuples of rec WithAttrs keeps, and the index of the first
+// tuple of each of its lines that still has tuples.
+func (o *options) project(rec Record, brk []int) (Record, []int) {
+	if o.keepattrs == nil {
+		return rec, brk
+	}
+
+	var out Record
+	var outbrk []int
+
+	line, lastline := -1, -1
+	for i, tuple := range rec {
+		for line+1 < len(brk) && brk[line+1] <= i {
+			line++
+		}
+
+		if !o.keepattrs[tuple.Attr] {
+			continue
+		}
+
+		if line != lastline {
+			outbrk = append(outbrk, len(out))
+			lastline = line
+		}
+		out = append(out, tuple)
+	}
+
+	return out, outbrk
+}
+
+// Drop the records of db not wanted by the options.
+func (o *options) filter(db *Ndb) {
+	if len(o.selectors) == 0 && len(o.loadfilters) == 0 && o.keepattrs == nil {
+		return
+	}
+
+	var records RecordSet
+	var lines []int
+	var breaks [][]int
+
+	for i, rec := range db.records {
+		rec, brk, ok := o.keep(rec, db.breaks[i])
+		if !ok {
+			continue
+		}
+
+		records = append(records, rec)
+		lines = append(lines, db.lines[i])
+		breaks = append(breaks, brk)
+	}
+
+	db.records = records
+	db.lines = lines
+	db.breaks = breaks
+}
+
+// Return the part of rec, whose lines break at brk, the options keep,
+// or false if they drop it.
+func (o *options) keep(rec Record, brk []int) (Record, []int, bool) {
+	if !o.selected(rec) || !o.kept(rec) {
+		return nil, nil, false
+	}
+
+	rec, brk = o.project(rec, brk)
+	return rec, brk, len(rec) > 0
+}
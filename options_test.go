@@ -0,0 +1,229 @@
+package ndb
+
+import (
+	"bytes"
+	"testing"
+)
+
+const (
+	testsites = "testndb/sites"
+)
+
+type SelectorTest struct {
+	opts []Option
+	sys  []string
+}
+
+var selectortests = []SelectorTest{
+	SelectorTest{nil, []string{"auth", "printer", "printer", "backup", "scratch"}},
+	SelectorTest{[]Option{WithSelector("site", "nyc")}, []string{"auth", "printer", "backup", "scratch"}},
+	SelectorTest{[]Option{WithSelector("site", "sfo")}, []string{"auth", "printer", "backup"}},
+	SelectorTest{[]Option{WithSelector("site", "nyc"), WithSelector("env", "prod")}, []string{"auth", "printer", "backup"}},
+	SelectorTest{[]Option{WithSelector("site", "lon")}, []string{"auth"}},
+	SelectorTest{[]Option{WithLoadFilter(hasip)}, []string{"printer", "printer", "backup", "scratch"}},
+	SelectorTest{[]Option{WithLoadFilter(hasip), WithSelector("site", "sfo")}, []string{"printer", "backup"}},
+	SelectorTest{[]Option{WithLoadFilter(hasip), WithLoadFilter(func(rec Record) bool { return rec.Search("env") != "dev" })}, []string{"printer", "printer", "backup"}},
+}
+
+func hasip(rec Record) bool {
+	return rec.Search("ip") != ""
+}
+
+func TestWithLoadFilter(t *testing.T) {
+	db, err := Open(testndb, WithLoadFilter(func(rec Record) bool { return rec.Search("dom") != "" }))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := db.Files(); len(files) != 2 {
+		t.Errorf("database= record dropped, files %q", files)
+	}
+
+	db.Walk(func(rec Record, pos Pos) bool {
+		if rec.Search("dom") == "" && rec.find("database") == nil {
+			t.Errorf("%s: record without dom= loaded", pos)
+		}
+		return true
+	})
+
+	if recs := db.Search("dom", "A.ROOT-SERVERS.NET"); len(recs) != 1 {
+		t.Errorf("record in chained file not found: %v", recs)
+	}
+}
+
+func TestWithSelector(t *testing.T) {
+	for i, st := range selectortests {
+		db, err := Open(testsites, st.opts...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sys []string
+		for _, rec := range db.Search("sys", "") {
+			sys = append(sys, rec.Search("sys"))
+		}
+
+		if len(sys) != len(st.sys) {
+			t.Errorf("%d: expected %q got %q", i, st.sys, sys)
+			continue
+		}
+
+		for j := range sys {
+			if sys[j] != st.sys[j] {
+				t.Errorf("%d: expected %q got %q", i, st.sys, sys)
+				break
+			}
+		}
+	}
+
+	db, err := Open(testsites, WithSelector("site", "sfo"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gw := db.Ipinfo("sys", "printer", "ipgw"); len(gw) != 1 || gw[0].Val != "10.2.0.1" {
+		t.Errorf("expected sfo gateway, got %v", gw)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("site", "nyc"); len(recs) != 1 || recs[0].Search("sys") != "backup" {
+		t.Errorf("selector not kept by Reopen: %v", recs)
+	}
+}
+
+type LimitTest struct {
+	records, tuples int
+	what            string
+}
+
+var limittests = []LimitTest{
+	LimitTest{0, 0, ""},
+	LimitTest{100, 1000, ""},
+	LimitTest{10, 0, "records"},
+	LimitTest{0, 100, "tuples"},
+}
+
+func TestWithLimits(t *testing.T) {
+	// testndb/local chains to testndb/common; the limits cover both
+	for _, lt := range limittests {
+		_, err := Open(testndb, WithLimits(lt.records, lt.tuples))
+
+		if lt.what == "" {
+			if err != nil {
+				t.Errorf("%d/%d: %v", lt.records, lt.tuples, err)
+			}
+			continue
+		}
+
+		if lerr, ok := err.(*LimitError); !ok || lerr.What != lt.what {
+			t.Errorf("%d/%d: expected %s LimitError, got %v", lt.records, lt.tuples, lt.what, err)
+		}
+	}
+
+	db, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nrec := 0
+	db.Walk(func(Record, Pos) bool {
+		nrec++
+		return true
+	})
+
+	// exactly at the limit is allowed
+	if db, err = Open(testndb, WithLimits(nrec, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Cat(testplan9); err == nil {
+		t.Errorf("Cat exceeded limit without error")
+	}
+
+	// parsing stops at the first record past the limit
+	big := &Ndb{data: bytes.NewReader(bytes.Repeat([]byte("sys=a ip=1.1.1.1\n"), 1000)), opts: &options{maxtuples: 5}}
+	recs, _, _, err := parserec(big)
+	if _, ok := err.(*LimitError); !ok || len(recs) != 3 {
+		t.Errorf("got %d records and %v", len(recs), err)
+	}
+}
+
+func TestSingle(t *testing.T) {
+	db, err := Open(testndb, Single())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := db.Files(); len(files) != 1 || files[0] != testndb {
+		t.Errorf("expected only %s, got %q", testndb, files)
+	}
+}
+
+func TestWithAttrs(t *testing.T) {
+	db, err := Open(testndb, WithAttrs("ip", "dom"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := db.Files(); len(files) != 2 {
+		t.Errorf("database= record dropped, files %q", files)
+	}
+
+	for d := db; d != nil; d = d.next {
+		for i, rec := range d.records {
+			for _, tuple := range rec {
+				if !map[string]bool{"ip": true, "dom": true, "database": true, "file": true}[tuple.Attr] {
+					t.Errorf("%s:%d: %s= kept", d.filename, d.lines[i], tuple.Attr)
+				}
+			}
+
+			brk := d.breaks[i]
+			if len(brk) == 0 || brk[0] != 0 {
+				t.Errorf("%s:%d: bad line breaks %v", d.filename, d.lines[i], brk)
+			}
+			for j := 1; j < len(brk); j++ {
+				if brk[j] <= brk[j-1] || brk[j] >= len(rec) {
+					t.Errorf("%s:%d: bad line breaks %v", d.filename, d.lines[i], brk)
+				}
+			}
+		}
+	}
+
+	if recs := db.Search("dom", "A.ROOT-SERVERS.NET"); len(recs) != 1 || recs[0].Search("ip") != "198.41.0.4" {
+		t.Errorf("dom=A.ROOT-SERVERS.NET got %v", recs)
+	}
+
+	if recs := db.Search("sys", ""); recs != nil {
+		t.Errorf("sys= kept: %v", recs)
+	}
+
+	if recs := db.Search("database", ""); recs == nil {
+		t.Errorf("database= record dropped")
+	}
+}
+
+func TestProject(t *testing.T) {
+	o := &options{}
+	WithAttrs("ip")(o)
+
+	rec := Record{{"sys", "a"}, {"ip", "1"}, {"dom", "a"}, {"sys", "b"}, {"ip", "2"}, {"ip", "3"}}
+
+	out, brk := o.project(rec, []int{0, 2, 3, 5})
+
+	if len(out) != 3 || out[0].Val != "1" || out[2].Val != "3" {
+		t.Errorf("projected %v", out)
+	}
+
+	if len(brk) != 3 || brk[0] != 0 || brk[1] != 1 || brk[2] != 2 {
+		t.Errorf("line breaks %v, want [0 1 2]", brk)
+	}
+}
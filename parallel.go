@@ -0,0 +1,70 @@
+package ndb
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Search databases of at least minrecords records by splitting them
+// into shards searched concurrently, by as many workers as GOMAXPROCS.
+// Results are merged back into search order, so they are the same as
+// a sequential search gives. This pays off for very large databases;
+// for small ones the sequential search is faster.
+func WithParallelSearch(minrecords int) Option {
+	return func(o *options) {
+		o.parallel = minrecords
+	}
+}
+
+// Records per shard, to keep workers busy without much overhead.
+const shardsize = 4096
+
+// Return the records of sets, in order, for which match is true,
+// running match on shards of them concurrently.
+func parallelmatch(sets []RecordSet, match func(Record) bool) RecordSet {
+	// runs of records from one file
+	var shards []RecordSet
+
+	for _, set := range sets {
+		for lo := 0; lo < len(set); lo += shardsize {
+			hi := lo + shardsize
+			if hi > len(set) {
+				hi = len(set)
+			}
+			shards = append(shards, set[lo:hi])
+		}
+	}
+
+	results := make([]RecordSet, len(shards))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				for _, record := range shards[i] {
+					if match(record) {
+						results[i] = append(results[i], record)
+					}
+				}
+			}
+		}()
+	}
+
+	for i := range shards {
+		next <- i
+	}
+	close(next)
+
+	wg.Wait()
+
+	var out RecordSet
+	for _, r := range results {
+		out = append(out, r...)
+	}
+
+	return out
+}
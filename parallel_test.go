@@ -0,0 +1,116 @@
+package ndb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Write a database of count hosts spread over two chained files.
+func bigdb(t testing.TB, dir string, count int) string {
+	r := rand.New(rand.NewSource(1))
+
+	var local, common strings.Builder
+
+	local.WriteString("database=\n\tfile=" + filepath.Join(dir, "local") + "\n\tfile=" + filepath.Join(dir, "common") + "\n")
+
+	for i := 0; i < count; i++ {
+		w := &local
+		if i%2 == 1 {
+			w = &common
+		}
+		fmt.Fprintf(w, "sys=h%d ip=10.%d.%d.%d role=r%d\n", i, i>>16&0xff, i>>8&0xff, i&0xff, r.Intn(50))
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "local"), []byte(local.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "common"), []byte(common.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "local")
+}
+
+func TestParallelSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := bigdb(t, dir, 20000)
+
+	seq, err := Open(fname)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	par, err := Open(fname, WithParallelSearch(1))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []Tuple{{"role", "r7"}, {"sys", "h19999"}, {"role", ""}, {"sys", "nope"}}
+
+	for _, q := range queries {
+		a, b := seq.Search(q.Attr, q.Val), par.Search(q.Attr, q.Val)
+
+		if len(a) != len(b) {
+			t.Errorf("%s=%s: %d records sequentially, %d in parallel", q.Attr, q.Val, len(a), len(b))
+			continue
+		}
+
+		for i := range a {
+			if a[i].String() != b[i].String() {
+				t.Errorf("%s=%s: record %d differs: %s vs %s", q.Attr, q.Val, i, a[i], b[i])
+				break
+			}
+		}
+	}
+
+	res := par.SearchResult("role", "", 10)
+	if len(res.Records) != 10 || !res.Truncated || res.Records[0].Search("sys") != "h0" {
+		t.Errorf("bad truncated result: %d records, truncated %v", len(res.Records), res.Truncated)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := bigdb(b, dir, 200000)
+
+	for _, parallel := range []bool{false, true} {
+		var opts []Option
+		if parallel {
+			opts = append(opts, WithParallelSearch(1))
+		}
+
+		db, err := Open(fname, opts...)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("parallel=%v", parallel), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				db.Search("role", "r7")
+			}
+		})
+	}
+}
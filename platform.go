@@ -0,0 +1,66 @@
+package ndb
+
+// Platform qualifiers. On a line of an ipnet record, an os= or arch=
+// tuple restricts the other tuples on that line to hosts with the same
+// os= or arch=, so a network can give each kind of machine its own
+// boot file:
+//
+//	ipnet=lab ip=10.0.0.0 ipmask=255.255.255.0
+//		bootf=/386/9bootpxe
+//		bootf=/386/9pc os=9front arch=386
+//		bootf=/amd64/9pc64 arch=amd64
+//
+// IpinfoPlatform takes an attribute from a network record as follows.
+// Lines whose qualifiers don't all match the host are ignored. Of the
+// remaining lines with the attribute, those with the most qualifiers
+// win, so os= and arch= together beat either alone, which beats an
+// unqualified line; lines tied for most qualifiers all contribute, in
+// order. The host's own tuples are never qualified and, as with
+// Ipinfo, take precedence over any network's, and a more specific
+// network takes precedence over a less specific one however its lines
+// are qualified.
+var platformAttrs = []string{"os", "arch"}
+
+// Look up the entry matching attr=val and return the values of rattrs
+// for it as Ipinfo does, choosing among the network records' tuples by
+// the entry's os= and arch=. See platformAttrs for the rules.
+// Results are not cached.
+func (n *Ndb) IpinfoPlatform(attr, val string, rattrs ...string) Record {
+	statIpinfos.Add(1)
+
+	result, _ := n.ipinfo(attr, val, rattrs, n.platformfind)
+	return result
+}
+
+// Find attr in the network record nw for the host entry.
+func (n *Ndb) platformfind(nw, entry Record, attr string) []Tuple {
+	var found []Tuple
+	best := -1
+
+	for _, line := range n.Lines(nw) {
+		quals := 0
+		match := true
+
+		for _, pattr := range platformAttrs {
+			for _, tuple := range line.find(pattr) {
+				quals++
+				if entry.Search(pattr) != tuple.Val {
+					match = false
+				}
+			}
+		}
+
+		have := line.find(attr)
+		if !match || have == nil || quals < best {
+			continue
+		}
+
+		if quals > best {
+			found, best = nil, quals
+		}
+
+		found = append(found, have...)
+	}
+
+	return found
+}
@@ -0,0 +1,58 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type PlatformTest struct {
+	sys    string
+	rattr  string
+	expect []string
+}
+
+var platformtests = []PlatformTest{
+	// os and arch beat arch alone, which beats no qualifier
+	{"term", "bootf", []string{"/386/9pc"}},
+	{"cpu", "bootf", []string{"/amd64/9pc64", "/amd64/9pc64.debug"}},
+	{"box", "bootf", []string{"/amd64/9pc64", "/amd64/9pc64.debug"}},
+	{"old", "bootf", []string{"/386/9bootpxe"}},
+	// the host's own tuples come first
+	{"mine", "bootf", []string{"/usr/glenda/9pc64"}},
+	{"box", "dns", []string{"10.0.0.53"}},
+	{"term", "dns", []string{"10.0.0.2"}},
+	// a more specific network wins only if a line there applies
+	{"inside", "fs", nil},
+	{"inside", "dns", []string{"10.0.0.53"}},
+}
+
+func TestIpinfoPlatform(t *testing.T) {
+	db, err := Open("testndb/platform")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pt := range platformtests {
+		var got []string
+		for _, tuple := range db.IpinfoPlatform("sys", pt.sys, pt.rattr) {
+			got = append(got, tuple.Val)
+		}
+
+		if len(got) != len(pt.expect) {
+			t.Errorf("%s %s: expected %v got %v", pt.sys, pt.rattr, pt.expect, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != pt.expect[i] {
+				t.Errorf("%s %s: expected %v got %v", pt.sys, pt.rattr, pt.expect, got)
+				break
+			}
+		}
+	}
+
+	// plain Ipinfo ignores qualifiers
+	if bootf := db.Ipinfo("sys", "term", "bootf"); len(bootf) != 4 {
+		t.Errorf("expected Ipinfo to return all 4 bootf, got %v", bootf)
+	}
+}
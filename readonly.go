@@ -0,0 +1,44 @@
+package ndb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error returned by functions that write to a database opened with
+// ReadOnly.
+var ErrReadOnly = errors.New("database is read-only")
+
+// Promise the database will not be changed through this package:
+// functions that write to it, such as AddRecord, Register and
+// WriteFile, return ErrReadOnly. In return, results are shared with
+// the database rather than copied, so Ipinfo returns its cached
+// records as they are; callers must not modify records they are given.
+// Reopen still rereads files changed by others.
+func ReadOnly() Option {
+	return func(o *options) {
+		o.readonly = true
+	}
+}
+
+// Return ErrReadOnly if the database was opened with ReadOnly, or an
+// error if any of its files isn't one on disk, such as one read by
+// Parse or from OpenFS's fs.FS, which functions that write to the
+// files would otherwise find by the same name in the operating system.
+func (n *Ndb) writable() error {
+	if n.opts != nil && n.opts.readonly {
+		return ErrReadOnly
+	}
+
+	if n.opts != nil && n.opts.files != nil {
+		return fmt.Errorf("write: database is not read from the operating system's files")
+	}
+
+	for db := n; db != nil; db = db.next {
+		if db.filename == "" {
+			return fmt.Errorf("write: database has no file")
+		}
+	}
+
+	return nil
+}
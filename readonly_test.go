@@ -0,0 +1,107 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	text := "ipnet=lan ip=10.0.0.0 ipmask=255.255.255.0 dns=10.0.0.1\nsys=a ip=10.0.0.2\n"
+
+	if err := ioutil.WriteFile(fname, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname, ReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]func() error{
+		"AddRecord":     func() error { return db.AddRecord(Record{{"sys", "b"}}) },
+		"ReplaceRecord": func() error { return db.ReplaceRecord(Record{{"sys", "a"}}, Record{{"sys", "b"}}) },
+		"DeleteWhere":   func() error { _, err := db.DeleteWhere("sys", "a"); return err },
+		"WriteFile":     func() error { return db.WriteFile() },
+		"Register":      func() error { return db.Register(fname, Record{{"sys", "b"}}) },
+		"AddHosts":      func() error { return db.AddHosts(fname, RecordSet{{{"sys", "b"}}}) },
+		"MarkSeen":      func() error { return db.MarkSeen(time.Now(), func(Record) bool { return true }) },
+		"AllocateHost": func() error {
+			_, err := db.AllocateHost("10.0.0.0/24", Record{{"sys", "b"}})
+			return err
+		},
+		"Renumber": func() error {
+			plan, err := db.Renumber("10.0.0.0/24", "10.1.0.0/24")
+			if err != nil {
+				return err
+			}
+			return plan.Apply()
+		},
+	}
+
+	for name, write := range writes {
+		if err := write(); err != ErrReadOnly {
+			t.Errorf("%s: got %v, want ErrReadOnly", name, err)
+		}
+	}
+
+	if data, _ := ioutil.ReadFile(fname); string(data) != text {
+		t.Errorf("read-only database written: %q", data)
+	}
+
+	// cached results are shared, not copied
+	db.SetIpinfoCache(8)
+
+	first := db.Ipinfo("sys", "a", "dns")
+	second := db.Ipinfo("sys", "a", "dns")
+
+	if len(first) == 0 || &first[0] != &second[0] {
+		t.Errorf("ipinfo results copied: %v %v", first, second)
+	}
+}
+
+func TestWritableParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	db, err := Parse(strings.NewReader("sys=a ip=10.0.0.2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddRecord(Record{{"sys", "b"}}); err == nil {
+		t.Errorf("AddRecord succeeded")
+	}
+
+	if err := db.MarkSeen(time.Now(), func(Record) bool { return true }); err == nil {
+		t.Errorf("MarkSeen succeeded")
+	}
+
+	if files, _ := ioutil.ReadDir("."); len(files) != 0 {
+		t.Errorf("files written: %v", files)
+	}
+}
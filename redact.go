@@ -0,0 +1,78 @@
+package ndb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// How an attribute's value is treated when records leave the program.
+type Redaction int
+
+const (
+	Pass Redaction = iota // Leave the tuple alone
+	Hide                  // Drop the tuple
+	Hash                  // Replace the value with a hash of it
+)
+
+// A redaction policy, giving the treatment of each attribute's value.
+// Attributes not in the policy are passed through.
+type RedactPolicy map[string]Redaction
+
+// The policy applied by Record.String and the exporters.
+// Empty by default, so nothing is redacted.
+var Redact = RedactPolicy{}
+
+// Parse a policy such as "password=hide,psk=hash".
+func ParseRedactPolicy(s string) (RedactPolicy, error) {
+	p := RedactPolicy{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item == "" {
+			continue
+		}
+
+		spl := strings.SplitN(item, "=", 2)
+		if len(spl) != 2 {
+			return nil, fmt.Errorf("redact: invalid policy %q", item)
+		}
+
+		switch spl[1] {
+		case "pass":
+			p[spl[0]] = Pass
+		case "hide":
+			p[spl[0]] = Hide
+		case "hash":
+			p[spl[0]] = Hash
+		default:
+			return nil, fmt.Errorf("redact: unknown treatment %q", spl[1])
+		}
+	}
+
+	return p, nil
+}
+
+// Return a copy of rec with the policy applied.
+// Hashed values become "sha256:" and the first 16 hex digits of the
+// SHA-256 of the value, so equal values can still be recognized.
+func (p RedactPolicy) Apply(rec Record) Record {
+	if len(p) == 0 {
+		return rec
+	}
+
+	out := make(Record, 0, len(rec))
+
+	for _, tuple := range rec {
+		switch p[tuple.Attr] {
+		case Hide:
+			continue
+		case Hash:
+			sum := sha256.Sum256([]byte(tuple.Val))
+			tuple.Val = "sha256:" + hex.EncodeToString(sum[:8])
+		}
+		out = append(out, tuple)
+	}
+
+	return out
+}
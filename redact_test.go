@@ -0,0 +1,48 @@
+package ndb
+
+import (
+	"strings"
+	"testing"
+)
+
+type RedactTest struct {
+	policy string
+	want   string
+}
+
+var redacttests = []RedactTest{
+	{"", `sys=fir psk=hunter2 ip=10.0.0.9`},
+	{"psk=hide", `sys=fir ip=10.0.0.9`},
+	{"psk=hash,ip=pass", `sys=fir psk=sha256:f52fbd32b2b3b86f ip=10.0.0.9`},
+}
+
+func TestRedact(t *testing.T) {
+	rec := Record{Tuple{"sys", "fir"}, Tuple{"psk", "hunter2"}, Tuple{"ip", "10.0.0.9"}}
+
+	defer func(p RedactPolicy) { Redact = p }(Redact)
+
+	for _, rt := range redacttests {
+		policy, err := ParseRedactPolicy(rt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		Redact = policy
+
+		if s := rec.String(); s != rt.want {
+			t.Errorf("%q: expected %q got %q", rt.policy, rt.want, s)
+		}
+	}
+
+	if rec[1].Val != "hunter2" {
+		t.Errorf("record modified: %v", rec)
+	}
+}
+
+func TestParseRedactPolicyError(t *testing.T) {
+	for _, s := range []string{"psk", "psk=shred"} {
+		if _, err := ParseRedactPolicy(s); err == nil || !strings.HasPrefix(err.Error(), "redact:") {
+			t.Errorf("%q: expected error, got %v", s, err)
+		}
+	}
+}
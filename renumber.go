@@ -0,0 +1,286 @@
+package ndb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+)
+
+// A plan to move every address in one network into another, made by
+// Renumber. Review it with Patch, then write it with Apply.
+type Renumbering struct {
+	From, To  *net.IPNet
+	Changes   []Change     // Tuples to rewrite, in file order
+	Conflicts []Diagnostic // Problems that stop the plan being applied
+	files     []renumfile
+	db        *Ndb
+}
+
+// A tuple value rewritten by a Renumbering.
+type Change struct {
+	Pos      Pos
+	Attr     string
+	Old, New string
+}
+
+// A database file and its text before and after renumbering.
+type renumfile struct {
+	name     string
+	old, new []string
+}
+
+// Plan moving every address in the network from into the network to,
+// both in CIDR form, keeping each address's offset in its network.
+// Any tuple whose value is an address in from is rewritten, as is the
+// ipmask= of ipnet records for from itself when the prefix lengths
+// differ. An address that does not fit in to, or that is already used
+// by an ip= outside from, is a conflict. Only IPv4 is supported. The
+// database files are not changed until the plan is applied.
+func (n *Ndb) Renumber(from, to string) (*Renumbering, error) {
+	_, fnet, err := net.ParseCIDR(from)
+	if err != nil {
+		return nil, fmt.Errorf("renumber: %s", err)
+	}
+
+	_, tnet, err := net.ParseCIDR(to)
+	if err != nil {
+		return nil, fmt.Errorf("renumber: %s", err)
+	}
+
+	if fnet.IP.To4() == nil || tnet.IP.To4() == nil {
+		return nil, fmt.Errorf("renumber: only IPv4 networks are supported")
+	}
+
+	r := &Renumbering{From: fnet, To: tnet, db: n}
+
+	// addresses that stay put, and who has them
+	used := make(map[string]Record)
+
+	for db := n; db != nil; db = db.next {
+		for _, rec := range db.recs() {
+			for _, ip := range rec.find("ip") {
+				if a := net.ParseIP(ip.Val); a != nil && !fnet.Contains(a) {
+					used[a.String()] = rec
+				}
+			}
+		}
+	}
+
+	for db := n; db != nil; db = db.next {
+		data, err := ioutil.ReadFile(db.filename)
+		if err != nil {
+			return nil, fmt.Errorf("renumber: %s", err)
+		}
+
+		f := renumfile{name: db.filename, old: strings.Split(string(data), "\n")}
+		f.new = append([]string(nil), f.old...)
+
+		for _, rec := range splitrecords(f.old) {
+			r.renumrecord(&f, rec, used)
+		}
+
+		r.files = append(r.files, f)
+	}
+
+	return r, nil
+}
+
+// Split the lines of a file into the line indexes of each record,
+// leaving out comments and ending records at empty lines as parserec
+// does.
+func splitrecords(lines []string) [][]int {
+	var recs [][]int
+	inrec := false // Whether lines continue the last record
+
+	for i, line := range lines {
+		if line == "" {
+			inrec = false
+			continue
+		}
+
+		if line[0] == '#' {
+			continue
+		}
+
+		if !iswhite(line[0]) {
+			recs = append(recs, nil)
+			inrec = true
+		} else if !inrec {
+			// white space after an empty line begins a record, if
+			// it has tuples
+			if tuples, _ := parseline(line); len(tuples) == 0 {
+				continue
+			}
+			recs = append(recs, nil)
+			inrec = true
+		}
+
+		recs[len(recs)-1] = append(recs[len(recs)-1], i)
+	}
+
+	return recs
+}
+
+// Rewrite the addresses in one record, whose lines of f are given.
+func (r *Renumbering) renumrecord(f *renumfile, lines []int, used map[string]Record) {
+	var rec Record
+	for _, i := range lines {
+		tuples, _ := parseline(f.old[i])
+		rec = append(rec, tuples...)
+	}
+
+	// ipnet records for the whole network get a new mask
+	var newmask net.IPMask
+	if rec.Search("ipnet") != "" {
+		if in, err := parseipnet(rec); err == nil && in.net.String() == r.From.String() && in.prefix() != prefixlen(r.To) {
+			newmask = r.To.Mask
+			if len(rec.find("ipmask")) == 0 {
+				r.conflict(f.name, lines[0], "ipnet=%s has no ipmask= to change to %s", rec.Search("ipnet"), net.IP(newmask))
+			}
+		}
+	}
+
+	for _, i := range lines {
+		tuples, _ := parseline(f.old[i])
+		pos := Pos{f.name, i + 1}
+
+		for _, tuple := range tuples {
+			var val string
+
+			if tuple.Attr == "ipmask" && newmask != nil {
+				val = net.IP(newmask).String()
+				if strings.HasPrefix(tuple.Val, "/") {
+					val = fmt.Sprintf("/%d", prefixlen(r.To))
+				}
+			} else if ip := net.ParseIP(tuple.Val); ip != nil && ip.To4() != nil && r.From.Contains(ip) {
+				to, ok := r.mapaddr(ip)
+				if !ok {
+					r.conflict(f.name, i+1, "%s=%s does not fit in %s", tuple.Attr, tuple.Val, r.To)
+					continue
+				}
+				if other, ok := used[to.String()]; ok && tuple.Attr == "ip" {
+					key := other.Key()
+					r.conflict(f.name, i+1, "%s=%s would become %s, already used by %s=%s", tuple.Attr, tuple.Val, to, key.Attr, key.Val)
+				}
+				val = to.String()
+			} else {
+				continue
+			}
+
+			if val == tuple.Val {
+				continue
+			}
+
+			f.new[i] = replacetuple(f.new[i], tuple.Attr, tuple.Val, val)
+			r.Changes = append(r.Changes, Change{pos, tuple.Attr, tuple.Val, val})
+		}
+	}
+}
+
+// Return the address at the same offset in To as ip has in From.
+func (r *Renumbering) mapaddr(ip net.IP) (net.IP, bool) {
+	off := binary.BigEndian.Uint32(ip.To4()) &^ binary.BigEndian.Uint32(r.From.Mask)
+	if off&binary.BigEndian.Uint32(r.To.Mask) != 0 {
+		return nil, false
+	}
+
+	return uint32ip(binary.BigEndian.Uint32(r.To.IP.To4()) | off), true
+}
+
+// Record a conflict at file:line.
+func (r *Renumbering) conflict(file string, line int, format string, args ...interface{}) {
+	r.Conflicts = append(r.Conflicts, Diagnostic{file, line, SeverityError, fmt.Sprintf(format, args...)})
+}
+
+// Prefix length of n.
+func prefixlen(n *net.IPNet) int {
+	ones, _ := n.Mask.Size()
+	return ones
+}
+
+// Replace the first attr=old tuple in line with attr=new, leaving the
+// rest of the line as it is.
+func replacetuple(line, attr, old, new string) string {
+	tok := attr + "=" + old
+
+	for i := 0; i+len(tok) <= len(line); i++ {
+		if !strings.HasPrefix(line[i:], tok) || (i > 0 && !iswhite(line[i-1])) {
+			continue
+		}
+		if end := i + len(tok); end == len(line) || iswhite(line[end]) || line[end] == '#' {
+			return line[:i] + attr + "=" + new + line[end:]
+		}
+	}
+
+	return line
+}
+
+// Return the plan as a unified diff of the database files, for review
+// or for applying with patch(1).
+func (r *Renumbering) Patch() string {
+	var buf strings.Builder
+
+	for _, f := range r.files {
+		buf.WriteString(linediff(f.name, f.old, f.new))
+	}
+
+	return buf.String()
+}
+
+// Write the plan to the database files and reread the database. Fails
+// without writing anything if the plan has conflicts or a file has
+// changed since the plan was made. Each file is locked (see lockdb)
+// until all are written, and replaced whole.
+func (r *Renumbering) Apply() error {
+	if err := r.db.writable(); err != nil {
+		return err
+	}
+
+	if len(r.Conflicts) > 0 {
+		return fmt.Errorf("renumber: %d conflicts", len(r.Conflicts))
+	}
+
+	var locks []*os.File
+	defer func() {
+		for _, lock := range locks {
+			unlockdb(lock)
+		}
+	}()
+
+	var writes []renumfile
+
+	for _, rf := range r.files {
+		if strings.Join(rf.old, "\n") == strings.Join(rf.new, "\n") {
+			continue
+		}
+
+		lock, err := lockdb(rf.name)
+		if err != nil {
+			return fmt.Errorf("renumber: %s", err)
+		}
+
+		locks = append(locks, lock)
+
+		data, err := ioutil.ReadFile(rf.name)
+		if err != nil {
+			return fmt.Errorf("renumber: %s", err)
+		}
+
+		if string(data) != strings.Join(rf.old, "\n") {
+			return fmt.Errorf("renumber: %s changed since the plan was made", rf.name)
+		}
+
+		writes = append(writes, rf)
+	}
+
+	for _, rf := range writes {
+		if err := writefile(rf.name, []byte(strings.Join(rf.new, "\n"))); err != nil {
+			return fmt.Errorf("renumber: %s", err)
+		}
+	}
+
+	return r.db.Reopen()
+}
@@ -0,0 +1,90 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenumber(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	data := "# lab\nipnet=lab ip=10.1.2.0 ipmask=255.255.255.0\n\tipgw=10.1.2.1\n\nip=10.1.2.1 sys=gw # router\nip=10.1.2.5 sys=oak\n\tdns=10.1.2.1\nip=192.168.0.1 sys=far"
+
+	if err := ioutil.WriteFile(fname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := db.Renumber("10.1.2.0/24", "10.9.0.0/16")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %v", plan.Conflicts)
+	}
+
+	if len(plan.Changes) != 6 {
+		t.Errorf("expected 6 changes, got %d: %v", len(plan.Changes), plan.Changes)
+	}
+
+	patch := plan.Patch()
+
+	for _, line := range []string{"-ip=10.1.2.1 sys=gw # router", "+ip=10.9.0.1 sys=gw # router", "+\tdns=10.9.0.1", "+ipnet=lab ip=10.9.0.0 ipmask=255.255.0.0"} {
+		if !strings.Contains(patch, line+"\n") {
+			t.Errorf("patch missing %q:\n%s", line, patch)
+		}
+	}
+
+	// nothing is written before Apply
+	if after, _ := ioutil.ReadFile(fname); string(after) != data {
+		t.Errorf("Renumber changed the file")
+	}
+
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := db.Search("sys", "oak"); len(rec) != 1 || rec[0].Search("ip") != "10.9.0.5" {
+		t.Errorf("oak not renumbered: %v", rec)
+	}
+
+	if ipgw := db.Ipinfo("sys", "oak", "ipgw").Search("ipgw"); ipgw != "10.9.0.1" {
+		t.Errorf("expected ipgw 10.9.0.1, got %q", ipgw)
+	}
+
+	if after, _ := ioutil.ReadFile(fname); !strings.HasPrefix(string(after), "# lab\n") || !strings.HasSuffix(string(after), "ip=192.168.0.1 sys=far") {
+		t.Errorf("formatting not preserved:\n%s", after)
+	}
+
+	// into a smaller network, and onto an address in use
+	plan, err = db.Renumber("10.9.0.0/16", "192.168.0.0/30")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Conflicts) != 2 {
+		t.Errorf("expected 2 conflicts, got %v", plan.Conflicts)
+	}
+
+	if err := plan.Apply(); err == nil {
+		t.Errorf("expected Apply to refuse a plan with conflicts")
+	}
+}
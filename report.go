@@ -0,0 +1,161 @@
+package ndb
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+)
+
+// Attributes that should be unique to one host.
+var reportUnique = []string{"ip", "ether", "dom"}
+
+// A summary of the hosts in a database, for finding mistakes
+// and free addresses.
+type Report struct {
+	Hosts        int          // Records with an ip address, excluding ipnet records
+	Subnets      []SubnetInfo // One for each ipnet record, in search order
+	MissingEther []HostRef    // Hosts without an ether address
+	MissingDom   []HostRef    // Hosts without a domain name
+	Duplicates   []Duplicate  // ip, ether or dom values used by more than one host
+}
+
+// Hosts on one ipnet.
+type SubnetInfo struct {
+	Name  string    // Value of the ipnet tuple
+	Net   string    // Network in CIDR notation
+	Hosts int       // Hosts with an address on the network
+	Free  []IPRange // Unassigned IPv4 addresses, excluding network and broadcast
+}
+
+// An inclusive range of addresses.
+type IPRange struct {
+	First, Last net.IP
+}
+
+// A host mentioned in a report.
+type HostRef struct {
+	Key Tuple // Identifying tuple, see Record.Key
+	Pos Pos   // Where the record begins
+}
+
+// A tuple shared by several hosts.
+type Duplicate struct {
+	Tuple Tuple
+	Hosts []HostRef
+}
+
+// Build a report summarizing the hosts in the database.
+func (n *Ndb) Report() *Report {
+	rep := &Report{}
+
+	dups := make(map[Tuple][]HostRef)
+	var order []Tuple
+
+	n.Walk(func(rec Record, pos Pos) bool {
+		if rec.find("ipnet") != nil || rec.find("ip") == nil {
+			return true
+		}
+
+		ref := HostRef{rec.Key(), pos}
+		rep.Hosts++
+
+		if rec.find("ether") == nil {
+			rep.MissingEther = append(rep.MissingEther, ref)
+		}
+
+		if rec.find("dom") == nil {
+			rep.MissingDom = append(rep.MissingDom, ref)
+		}
+
+		seen := make(map[Tuple]bool)
+		for _, attr := range reportUnique {
+			for _, tuple := range rec.find(attr) {
+				if tuple.Val == "" || seen[tuple] {
+					continue
+				}
+				seen[tuple] = true
+
+				if dups[tuple] == nil {
+					order = append(order, tuple)
+				}
+				dups[tuple] = append(dups[tuple], ref)
+			}
+		}
+
+		return true
+	})
+
+	for _, tuple := range order {
+		if len(dups[tuple]) > 1 {
+			rep.Duplicates = append(rep.Duplicates, Duplicate{tuple, dups[tuple]})
+		}
+	}
+
+	for _, nw := range n.Networks() {
+		rep.Subnets = append(rep.Subnets, SubnetInfo{
+			Name:  nw.Record.Search("ipnet"),
+			Net:   nw.Net.String(),
+			Hosts: len(nw.Hosts),
+			Free:  freeranges(nw),
+		})
+	}
+
+	return rep
+}
+
+// Find the unassigned ranges of an IPv4 network.
+// Returns nil for IPv6 networks.
+func freeranges(nw Network) []IPRange {
+	ip4 := nw.Net.IP.To4()
+	ones, bits := nw.Net.Mask.Size()
+
+	if ip4 == nil || bits != 32 {
+		return nil
+	}
+
+	first := binary.BigEndian.Uint32(ip4)
+	last := first | ^binary.BigEndian.Uint32(net.IP(nw.Net.Mask).To4())
+
+	// network and broadcast addresses can't be assigned
+	if ones < 31 {
+		first++
+		last--
+	}
+
+	var used []uint32
+	for _, host := range nw.Hosts {
+		for _, tuple := range host.find("ip") {
+			if ip := net.ParseIP(tuple.Val).To4(); ip != nil {
+				if a := binary.BigEndian.Uint32(ip); a >= first && a <= last {
+					used = append(used, a)
+				}
+			}
+		}
+	}
+
+	sort.Slice(used, func(i, j int) bool { return used[i] < used[j] })
+
+	var free []IPRange
+	next := uint64(first)
+
+	for _, a := range used {
+		if uint64(a) > next {
+			free = append(free, IPRange{uint32ip(uint32(next)), uint32ip(a - 1)})
+		}
+		if uint64(a) >= next {
+			next = uint64(a) + 1
+		}
+	}
+
+	if next <= uint64(last) {
+		free = append(free, IPRange{uint32ip(uint32(next)), uint32ip(last)})
+	}
+
+	return free
+}
+
+func uint32ip(a uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, a)
+	return ip
+}
@@ -0,0 +1,61 @@
+package ndb
+
+import (
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	db, err := Open("testndb/report")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep := db.Report()
+
+	if rep.Hosts != 5 {
+		t.Errorf("expected 5 hosts, got %d", rep.Hosts)
+	}
+
+	if len(rep.MissingEther) != 1 || rep.MissingEther[0].Key != (Tuple{"ip", "10.1.2.2"}) {
+		t.Errorf("wrong hosts missing ether: %+v", rep.MissingEther)
+	}
+
+	if len(rep.MissingDom) != 1 || rep.MissingDom[0].Pos != (Pos{"testndb/report", 6}) {
+		t.Errorf("wrong hosts missing dom: %+v", rep.MissingDom)
+	}
+
+	dups := []Tuple{Tuple{"dom", "fir.example.com"}, Tuple{"ip", "10.1.2.5"}}
+
+	if len(rep.Duplicates) != len(dups) {
+		t.Fatalf("expected %d duplicates, got %+v", len(dups), rep.Duplicates)
+	}
+
+	for i, tuple := range dups {
+		if rep.Duplicates[i].Tuple != tuple || len(rep.Duplicates[i].Hosts) != 2 {
+			t.Errorf("duplicate %d: expected %+v twice, got %+v", i, tuple, rep.Duplicates[i])
+		}
+	}
+
+	if len(rep.Subnets) != 1 {
+		t.Fatalf("expected 1 subnet, got %d", len(rep.Subnets))
+	}
+
+	sn := rep.Subnets[0]
+
+	if sn.Name != "lab" || sn.Net != "10.1.2.0/24" || sn.Hosts != 5 {
+		t.Errorf("wrong subnet: %+v", sn)
+	}
+
+	free := [][2]string{{"10.1.2.3", "10.1.2.4"}, {"10.1.2.6", "10.1.2.253"}}
+
+	if len(sn.Free) != len(free) {
+		t.Fatalf("expected %d free ranges, got %v", len(free), sn.Free)
+	}
+
+	for i, r := range free {
+		if sn.Free[i].First.String() != r[0] || sn.Free[i].Last.String() != r[1] {
+			t.Errorf("free range %d: expected %s-%s got %s-%s", i, r[0], r[1], sn.Free[i].First, sn.Free[i].Last)
+		}
+	}
+}
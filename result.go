@@ -0,0 +1,142 @@
+package ndb
+
+import (
+	"context"
+	"time"
+)
+
+// Result of a search, along with information about how it was answered.
+type Result struct {
+	Records   RecordSet     // Matching records
+	Elapsed   time.Duration // Time spent searching
+	Files     []string      // Database files consulted, in order
+	Truncated bool          // More records matched than were returned
+	Err       error         // Why the search stopped early, see SearchContext
+}
+
+// Search for a record set with the given attr=val, like Search, but
+// return a Result describing the query. Records are matched on their
+// own tuples, not those inherited from templates.
+// At most max records are returned; if max <= 0 there is no limit.
+func (n *Ndb) SearchResult(attr, val string, max int) *Result {
+	return n.SearchContext(context.Background(), attr, val, max)
+}
+
+// Search for records whose primary tuple (see Record.Primary) is
+// attr=val, as Plan 9 tools that take the first tuple as the record's
+// name do. Returns no records (nil) if not found.
+func (n *Ndb) SearchPrimary(attr, val string) RecordSet {
+	ctx, cancel := n.opts.searchcontext(context.Background())
+	defer cancel()
+
+	return n.search(ctx, attr, val, 0, true).Records
+}
+
+// Search, looking only at each record's first tuple if primary is set,
+// until ctx is done.
+func (n *Ndb) search(ctx context.Context, attr, val string, max int, primary bool) *Result {
+	res := &Result{}
+	start := time.Now()
+	done := ctx.Done()
+
+	statSearches.Add(1)
+
+	defer func() {
+		if res.Err = ctx.Err(); res.Err != nil {
+			res.Truncated = true
+		}
+		res.Elapsed = time.Since(start)
+	}()
+
+	match := func(record Record) bool {
+		return !stopped(done) && n.searchmatch(record, attr, val, primary, start)
+	}
+
+	for db := n; db != nil; db = db.next {
+		res.Files = append(res.Files, db.filename)
+	}
+
+	if n.opts != nil && n.opts.parallel > 0 {
+		// check each db file the bloom filter doesn't rule out
+		var sets []RecordSet
+		total := 0
+
+		for db := n; db != nil; db = db.next {
+			if recs := db.recs(); !db.bloomskip(attr, val) {
+				sets = append(sets, recs)
+				total += len(recs)
+			}
+		}
+
+		if total >= n.opts.parallel {
+			for _, record := range parallelmatch(sets, match) {
+				if max > 0 && len(res.Records) == max {
+					res.Truncated = true
+					break
+				}
+				res.Records = append(res.Records, n.Expand(record))
+			}
+			return res
+		}
+	}
+
+	// check each record, only parsing files put off by Lazy
+	// when the search gets to them, or only those a hash file
+	// says may match
+	for db := n; db != nil; db = db.next {
+		recs := db.recs()
+		if db.bloomskip(attr, val) {
+			continue
+		}
+
+		if hashed, ok := db.hashed(attr, val); ok {
+			recs = hashed
+		}
+
+		for _, record := range recs {
+			if stopped(done) {
+				return res
+			}
+
+			if !match(record) {
+				continue
+			}
+
+			if max > 0 && len(res.Records) == max {
+				res.Truncated = true
+				return res
+			}
+
+			res.Records = append(res.Records, n.Expand(record))
+		}
+	}
+
+	return res
+}
+
+// Whether an unexpired record has a tuple attr=val, looking only at
+// its first tuple if primary is set.
+func (n *Ndb) searchmatch(record Record, attr, val string, primary bool, now time.Time) bool {
+	if record.Expired(now) {
+		return false
+	}
+
+	tuples := record
+	if primary && len(tuples) > 0 {
+		tuples = tuples[:1]
+	}
+
+	// each each tuple!
+	for _, tuple := range tuples {
+		if tuple.Attr != attr {
+			continue
+		}
+
+		// if val is "" we don't care what it is
+		if val == "" || n.opts.sameval(attr, tuple.Val, val) {
+			return true
+		}
+	}
+
+	return false
+}
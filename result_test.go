@@ -0,0 +1,70 @@
+package ndb
+
+import (
+	"testing"
+)
+
+func TestSearchResult(t *testing.T) {
+	ndb, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := ndb.SearchResult("tcp", "", 0)
+
+	if len(res.Records) == 0 || res.Truncated {
+		t.Fatalf("search for tcp: got %d records, truncated %v", len(res.Records), res.Truncated)
+	}
+
+	if len(res.Files) != 2 || res.Files[0] != "testndb/local" || res.Files[1] != "testndb/common" {
+		t.Errorf("wrong files consulted: %q", res.Files)
+	}
+
+	if n := len(ndb.Search("tcp", "")); n != len(res.Records) {
+		t.Errorf("Search returned %d records, SearchResult %d", n, len(res.Records))
+	}
+
+	res = ndb.SearchResult("tcp", "", 3)
+
+	if len(res.Records) != 3 || !res.Truncated {
+		t.Errorf("limited search: got %d records, truncated %v", len(res.Records), res.Truncated)
+	}
+
+	res = ndb.SearchResult("nonexistent", "", 0)
+
+	if res.Records != nil || res.Truncated {
+		t.Errorf("expected no records, got %+v", res)
+	}
+}
+
+func TestSearchPrimary(t *testing.T) {
+	ndb, err := Open(testplan9)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// anna's record begins with ip=, helix's with sys=
+	if recs := ndb.SearchPrimary("sys", "anna"); recs != nil {
+		t.Errorf("matched a non-primary tuple: %v", recs)
+	}
+
+	if recs := ndb.SearchPrimary("sys", "helix"); len(recs) != 1 || recs[0].Primary() != (Tuple{"sys", "helix"}) {
+		t.Errorf("expected helix, got %v", recs)
+	}
+
+	// the rfc1918 zone record is named by its first dom=, not the
+	// others; split's dom= after an empty line is a record of its own
+	if n := len(ndb.SearchPrimary("dom", "")); n != 13+13+1+1 {
+		t.Errorf("expected 28 records named by dom, got %d", n)
+	}
+
+	if recs := ndb.SearchPrimary("dom", "0.in-addr.arpa"); recs != nil {
+		t.Errorf("matched a non-primary tuple: %v", recs)
+	}
+
+	if p := (Record{}).Primary(); p != (Tuple{}) {
+		t.Errorf("expected empty tuple, got %+v", p)
+	}
+}
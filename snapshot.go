@@ -0,0 +1,321 @@
+package ndb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A snapshot holds a parsed database, so a server can save it on
+// shutdown and load it on startup without parsing the text again.
+//
+// It begins with snapmagic and a version number, then has each file
+// in search order: its name, modification time, records, the line
+// where each record begins and where its lines break, and its bloom
+// filter if it has one. Numbers are varints and strings are a length
+// followed by the bytes. The format changes only with snapversion.
+const (
+	snapmagic   = "ndbsnap\n"
+	snapversion = 1
+)
+
+// Error returned by ReadSnapshot when a file has changed since the
+// snapshot was written.
+var ErrStaleSnapshot = errors.New("snapshot: database changed since snapshot was written")
+
+// Write a snapshot of the database to w, parsing any files put off by
+// Lazy first. Records are saved as loaded, so read the snapshot back
+// with the same WithSelector, WithLoadFilter and WithAttrs options.
+func (n *Ndb) WriteSnapshot(w io.Writer) error {
+	if err := n.Load(); err != nil {
+		return fmt.Errorf("snapshot: %s", err)
+	}
+
+	sw := &snapwriter{w: bufio.NewWriter(w)}
+	sw.string(snapmagic)
+	sw.uint(snapversion)
+
+	nfiles := 0
+	for db := n; db != nil; db = db.next {
+		nfiles++
+	}
+	sw.uint(uint64(nfiles))
+
+	for db := n; db != nil; db = db.next {
+		sw.string(db.filename)
+		// files from an embed.FS have no modification time
+		if db.mtime.IsZero() {
+			sw.int(0)
+		} else {
+			sw.int(db.mtime.UnixNano())
+		}
+
+		sw.uint(uint64(len(db.records)))
+		for i, rec := range db.records {
+			sw.uint(uint64(db.lines[i]))
+
+			sw.uint(uint64(len(db.breaks[i])))
+			for _, b := range db.breaks[i] {
+				sw.uint(uint64(b))
+			}
+
+			sw.uint(uint64(len(rec)))
+			for _, tuple := range rec {
+				sw.string(tuple.Attr)
+				sw.string(tuple.Val)
+			}
+		}
+
+		if db.bloom == nil {
+			sw.uint(0)
+			continue
+		}
+
+		sw.string(bloomattrs(db.opts))
+		sw.uint(uint64(len(db.bloom.bits)))
+		for _, word := range db.bloom.bits {
+			sw.uint(word)
+		}
+	}
+
+	if sw.err != nil {
+		return fmt.Errorf("snapshot: %s", sw.err)
+	}
+
+	if err := sw.w.Flush(); err != nil {
+		return fmt.Errorf("snapshot: %s", err)
+	}
+
+	return nil
+}
+
+// Write a snapshot of the database to the file fname, replacing it
+// only once the whole snapshot is written.
+func (n *Ndb) SaveSnapshot(fname string) error {
+	f, err := ioutil.TempFile(filepath.Dir(fname), filepath.Base(fname)+".tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot: %s", err)
+	}
+
+	if err := n.WriteSnapshot(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("snapshot: %s", err)
+	}
+
+	if err := os.Rename(f.Name(), fname); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("snapshot: %s", err)
+	}
+
+	return nil
+}
+
+// Read a snapshot written by WriteSnapshot. Returns ErrStaleSnapshot
+// if any of its files is missing or has been modified since. Records
+// from Parse, which have no file, are never stale. Files are looked
+// for where opts says, so pass WithFS for a database from OpenFS. Bloom
+// filters are rebuilt if opts asks for different attributes than the
+// snapshot has.
+func ReadSnapshot(r io.Reader, opts ...Option) (*Ndb, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	sr := &snapreader{r: bufio.NewReader(r)}
+
+	if magic := sr.string(); sr.err == nil && magic != snapmagic {
+		return nil, fmt.Errorf("snapshot: not a snapshot")
+	}
+
+	if v := sr.uint(); sr.err == nil && v != snapversion {
+		return nil, fmt.Errorf("snapshot: unsupported version %d", v)
+	}
+
+	var first, last *Ndb
+
+	for nfiles := sr.uint(); nfiles > 0 && sr.err == nil; nfiles-- {
+		db := &Ndb{filename: sr.string(), opts: o}
+		if ns := sr.int(); ns != 0 {
+			db.mtime = time.Unix(0, ns)
+		}
+
+		for nrec := sr.uint(); nrec > 0 && sr.err == nil; nrec-- {
+			db.lines = append(db.lines, int(sr.uint()))
+
+			var breaks []int
+			for nb := sr.uint(); nb > 0 && sr.err == nil; nb-- {
+				breaks = append(breaks, int(sr.uint()))
+			}
+			db.breaks = append(db.breaks, breaks)
+
+			var rec Record
+			for nt := sr.uint(); nt > 0 && sr.err == nil; nt-- {
+				rec = append(rec, Tuple{sr.string(), sr.string()})
+			}
+			db.records = append(db.records, rec)
+		}
+
+		if attrs := sr.string(); attrs != "" {
+			b := &bloom{}
+			for nw := sr.uint(); nw > 0 && sr.err == nil; nw-- {
+				b.bits = append(b.bits, sr.uint())
+			}
+			if attrs == bloomattrs(o) {
+				db.bloom = b
+			}
+		}
+
+		if sr.err != nil {
+			break
+		}
+
+		// a database from Parse has no file to go stale
+		if db.filename != "" {
+			fi, err := o.stat(db.filename)
+			if err != nil || !fi.ModTime().Equal(db.mtime) {
+				return nil, ErrStaleSnapshot
+			}
+		}
+
+		o.filter(db)
+		if db.bloom == nil {
+			o.buildbloom(db)
+		}
+
+		if first == nil {
+			first = db
+		} else {
+			last.next = db
+		}
+		last = db
+	}
+
+	if sr.err != nil {
+		return nil, fmt.Errorf("snapshot: %s", sr.err)
+	}
+
+	if first == nil {
+		return nil, fmt.Errorf("snapshot: no files")
+	}
+
+	statSnapshotLoads.Add(1)
+	first.loaded = time.Now()
+
+	return first, nil
+}
+
+// Open the database fname from the snapshot file snap, or by parsing
+// its files as Open does if the snapshot is missing, unreadable,
+// stale, or of another database.
+func OpenSnapshot(fname, snap string, opts ...Option) (*Ndb, error) {
+	if f, err := os.Open(snap); err == nil {
+		db, err := ReadSnapshot(f, opts...)
+		f.Close()
+		if err == nil && (fname == "" || db.filename == fname) {
+			return db, nil
+		}
+	}
+
+	return Open(fname, opts...)
+}
+
+// The attributes given to WithBloom, sorted and joined, to tell whether
+// a saved bloom filter can be used with o.
+func bloomattrs(o *options) string {
+	var attrs []string
+	for attr := range o.bloomattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	return strings.Join(attrs, " ")
+}
+
+// Writes the parts of a snapshot, keeping the first error.
+type snapwriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (sw *snapwriter) uint(v uint64) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+	}
+}
+
+func (sw *snapwriter) int(v int64) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(sw.buf[:binary.PutVarint(sw.buf[:], v)])
+	}
+}
+
+func (sw *snapwriter) string(s string) {
+	sw.uint(uint64(len(s)))
+	if sw.err == nil {
+		_, sw.err = sw.w.WriteString(s)
+	}
+}
+
+// Reads the parts of a snapshot, keeping the first error. After an
+// error, reads return zero values.
+type snapreader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (sr *snapreader) uint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(sr.r)
+	sr.fail(err)
+	return v
+}
+
+func (sr *snapreader) int() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(sr.r)
+	sr.fail(err)
+	return v
+}
+
+func (sr *snapreader) string() string {
+	n := sr.uint()
+	if sr.err != nil {
+		return ""
+	}
+	if n > 1<<30 {
+		sr.err = fmt.Errorf("string of %d bytes", n)
+		return ""
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(sr.r, buf)
+	sr.fail(err)
+	return string(buf)
+}
+
+func (sr *snapreader) fail(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if sr.err == nil {
+		sr.err = err
+	}
+}
@@ -0,0 +1,148 @@
+package ndb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	db, err := Open(testndb, WithBloom("sys", "dom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := db.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	before := statParses.Value()
+
+	snap, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), WithBloom("sys", "dom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := statParses.Value() - before; n != 0 {
+		t.Errorf("parsed %d files reading a snapshot", n)
+	}
+
+	if !reflect.DeepEqual(snap.Files(), db.Files()) {
+		t.Errorf("files %q, want %q", snap.Files(), db.Files())
+	}
+
+	for a, b := snap, db; a != nil; a, b = a.next, b.next {
+		if !reflect.DeepEqual(a.records, b.records) || !reflect.DeepEqual(a.lines, b.lines) || !reflect.DeepEqual(a.breaks, b.breaks) {
+			t.Errorf("%s: snapshot differs from parsed file", a.filename)
+		}
+		if !reflect.DeepEqual(a.bloom, b.bloom) {
+			t.Errorf("%s: bloom filter differs", a.filename)
+		}
+	}
+
+	if changed, err := snap.Changed(); err != nil || changed {
+		t.Errorf("Changed = %v, %v", changed, err)
+	}
+
+	// a different bloom attribute rebuilds the filter
+	snap, err = ReadSnapshot(bytes.NewReader(buf.Bytes()), WithBloom("ip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := snap.Search("ip", "127.0.0.1"); len(recs) == 0 {
+		t.Errorf("ip=127.0.0.1 not found with rebuilt filter")
+	}
+
+	// truncated and foreign data are errors
+	if _, err := ReadSnapshot(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Errorf("no error reading a truncated snapshot")
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader([]byte("sys=a\n"))); err == nil {
+		t.Errorf("no error reading a text file as a snapshot")
+	}
+
+	// a database from Parse has no file to compare against
+	parsed, err := Parse(bytes.NewReader([]byte("sys=a\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := parsed.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if snap, err = ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Errorf("snapshot of Parse: %v", err)
+	} else if recs := snap.Search("sys", "a"); len(recs) != 1 {
+		t.Errorf("snapshot of Parse: got %v", recs)
+	}
+}
+
+func TestSnapshotStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	snapname := filepath.Join(dir, "local.snap")
+
+	if err := ioutil.WriteFile(fname, []byte("sys=a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.SaveSnapshot(snapname); err != nil {
+		t.Fatal(err)
+	}
+
+	loads := statSnapshotLoads.Value()
+
+	if db, err = OpenSnapshot(fname, snapname); err != nil {
+		t.Fatal(err)
+	} else if statSnapshotLoads.Value() != loads+1 {
+		t.Errorf("current snapshot not used")
+	}
+
+	if err := ioutil.WriteFile(fname, []byte("sys=a\nsys=b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fname, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(snapname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := ReadSnapshot(f); err != ErrStaleSnapshot {
+		t.Errorf("stale snapshot read with error %v", err)
+	}
+
+	if db, err = OpenSnapshot(fname, snapname); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "b"); len(recs) != 1 {
+		t.Errorf("stale snapshot used: %v", recs)
+	}
+}
@@ -0,0 +1,78 @@
+package ndb
+
+import (
+	"sort"
+)
+
+// Return values of attr in the database close to val, for suggesting
+// what was meant when a search finds nothing. Values within an edit
+// distance of max are returned, closest first and then in sorted
+// order; if max <= 0, a third of the length of val, and at least one,
+// is used. Case differences count as edits of one.
+func (n *Ndb) Suggest(attr, val string, max int) []string {
+	if max <= 0 {
+		max = len(val) / 3
+		if max < 1 {
+			max = 1
+		}
+	}
+
+	type near struct {
+		val  string
+		dist int
+	}
+
+	var found []near
+
+	for _, v := range n.Vals(attr) {
+		if v == val {
+			continue
+		}
+		if d := levenshtein(v, val); d <= max {
+			found = append(found, near{v, d})
+		}
+	}
+
+	// Vals is sorted, so ties stay in order
+	sort.SliceStable(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+
+	var vals []string
+	for _, f := range found {
+		vals = append(vals, f.val)
+	}
+
+	return vals
+}
+
+// Edit distance between a and b, counting insertions, deletions and
+// substitutions of runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,55 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type LevenshteinTest struct {
+	a, b string
+	dist int
+}
+
+var levenshteintests = []LevenshteinTest{
+	{"", "", 0},
+	{"fir", "fir", 0},
+	{"fir", "", 3},
+	{"fir", "fri", 2},
+	{"kitten", "sitting", 3},
+	{"ash", "Ash", 1},
+	{"héllo", "hello", 1},
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, lt := range levenshteintests {
+		if d := levenshtein(lt.a, lt.b); d != lt.dist {
+			t.Errorf("%q %q: expected %d got %d", lt.a, lt.b, lt.dist, d)
+		}
+		if d := levenshtein(lt.b, lt.a); d != lt.dist {
+			t.Errorf("%q %q: expected %d got %d", lt.b, lt.a, lt.dist, d)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	db, err := Open("testndb/report")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := db.Suggest("sys", "fur", 0); len(s) != 1 || s[0] != "fir" {
+		t.Errorf("expected [fir] got %v", s)
+	}
+
+	if s := db.Suggest("sys", "ok", 1); len(s) != 1 || s[0] != "oak" {
+		t.Errorf("expected [oak] got %v", s)
+	}
+
+	if s := db.Suggest("dom", "elm.example.org", 0); len(s) != 1 || s[0] != "elm.example.com" {
+		t.Errorf("expected [elm.example.com] got %v", s)
+	}
+
+	if s := db.Suggest("sys", "zzzzzz", 0); s != nil {
+		t.Errorf("expected no suggestions, got %v", s)
+	}
+}
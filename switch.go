@@ -0,0 +1,113 @@
+package ndb
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A host interface plugged into a switch, from the switch= and port=
+// tuples of a host record. On a multi-homed host (see Interfaces)
+// each interface line carries its own switch=, port= and vlan=.
+type SwitchPort struct {
+	Switch string   // Value of switch=, naming the switch's sys=
+	Port   string   // Value of port=
+	Vlans  []string // Values of vlan=; more than one makes a trunk
+	Host   Record   // The interface's tuples
+	Pos    Pos      // Where the host record begins
+}
+
+// Return the host interfaces on the switch named sw, or on every
+// switch if sw is "", in search order. Interfaces with a switch= but
+// no port= are included with an empty Port.
+func (n *Ndb) SwitchPorts(sw string) []SwitchPort {
+	var ports []SwitchPort
+
+	n.Walk(func(rec Record, pos Pos) bool {
+		for _, ifc := range n.Interfaces(rec) {
+			name := ifc.Search("switch")
+			if name == "" || (sw != "" && name != sw) {
+				continue
+			}
+
+			var vlans []string
+			for _, tuple := range ifc.find("vlan") {
+				vlans = append(vlans, tuple.Val)
+			}
+
+			ports = append(ports, SwitchPort{name, ifc.Search("port"), vlans, ifc, pos})
+		}
+		return true
+	})
+
+	return ports
+}
+
+// Check the vlan=, switch= and port= tuples in the database: vlan ids
+// must be between 1 and 4094, switch= must name a record's sys= and
+// come with a port=, no two interfaces may share a port, and a host's
+// vlans must be among the switch record's vlan= tuples if it has any.
+func (n *Ndb) CheckSwitches() []Diagnostic {
+	var diags []Diagnostic
+
+	report := func(pos Pos, sev string, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{pos.File, pos.Line, sev, fmt.Sprintf(format, args...)})
+	}
+
+	n.Walk(func(rec Record, pos Pos) bool {
+		for _, tuple := range rec.find("vlan") {
+			if id, err := strconv.Atoi(tuple.Val); err != nil || id < 1 || id > 4094 {
+				report(pos, SeverityError, "vlan=%s is not a vlan id", tuple.Val)
+			}
+		}
+
+		for _, ifc := range n.Interfaces(rec) {
+			if ifc.find("port") != nil && ifc.find("switch") == nil {
+				key := rec.Key()
+				report(pos, SeverityWarning, "host %s=%s has port=%s but no switch=", key.Attr, key.Val, ifc.Search("port"))
+			}
+		}
+
+		return true
+	})
+
+	used := make(map[[2]string]SwitchPort)
+
+	for _, sp := range n.SwitchPorts("") {
+		key := sp.Host.Key()
+
+		sw := n.Search("sys", sp.Switch)
+		if sw == nil {
+			report(sp.Pos, SeverityError, "switch=%s is not in the database", sp.Switch)
+		}
+
+		if sp.Port == "" {
+			report(sp.Pos, SeverityWarning, "host %s=%s has switch=%s but no port=", key.Attr, key.Val, sp.Switch)
+			continue
+		}
+
+		if other, ok := used[[2]string{sp.Switch, sp.Port}]; ok {
+			okey := other.Host.Key()
+			report(sp.Pos, SeverityWarning, "switch=%s port=%s is also used by %s=%s", sp.Switch, sp.Port, okey.Attr, okey.Val)
+		} else {
+			used[[2]string{sp.Switch, sp.Port}] = sp
+		}
+
+		if sw == nil || sw[0].find("vlan") == nil {
+			continue
+		}
+
+		for _, vlan := range sp.Vlans {
+			carried := false
+			for _, tuple := range sw[0].find("vlan") {
+				if tuple.Val == vlan {
+					carried = true
+				}
+			}
+			if !carried {
+				report(sp.Pos, SeverityWarning, "host %s=%s is on vlan=%s, which switch=%s does not carry", key.Attr, key.Val, vlan, sp.Switch)
+			}
+		}
+	}
+
+	return diags
+}
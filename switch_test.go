@@ -0,0 +1,60 @@
+package ndb
+
+import (
+	"testing"
+)
+
+func TestSwitchPorts(t *testing.T) {
+	db, err := Open("testndb/switch")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ports := db.SwitchPorts("sw1")
+
+	if len(ports) != 3 {
+		t.Fatalf("expected 3 ports on sw1, got %+v", ports)
+	}
+
+	if ports[0].Port != "ge-0/0/1" || ports[0].Host.Search("ip") != "10.0.1.1" || ports[0].Host.Search("dom") != "fir.example.com" {
+		t.Errorf("bad first port: %+v", ports[0])
+	}
+
+	if ports := db.SwitchPorts("sw2"); len(ports) != 2 || len(ports[0].Vlans) != 2 {
+		t.Errorf("expected fir's trunk and yew on sw2, got %+v", ports)
+	}
+
+	if ports := db.SwitchPorts(""); len(ports) != 6 {
+		t.Errorf("expected 6 ports in all, got %d", len(ports))
+	}
+}
+
+func TestCheckSwitches(t *testing.T) {
+	db, err := Open("testndb/switch")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{
+		"vlan=5000 is not a vlan id",
+		"host sys=bay has port=7 but no switch=",
+		"host sys=oak is on vlan=300, which switch=sw1 does not carry",
+		"switch=sw1 port=ge-0/0/2 is also used by sys=oak",
+		"switch=sw9 is not in the database",
+		"host sys=yew has switch=sw2 but no port=",
+	}
+
+	diags := db.CheckSwitches()
+
+	if len(diags) != len(expect) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(expect), diags)
+	}
+
+	for i, d := range diags {
+		if d.Message != expect[i] {
+			t.Errorf("diagnostic %d: expected %q got %q", i, expect[i], d.Message)
+		}
+	}
+}
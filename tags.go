@@ -0,0 +1,107 @@
+package ndb
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Attribute whose values are sets of space separated tags, so that
+// tags="web prod" tags=dc1 gives a record the tags web, prod and dc1.
+var TagAttr = "tags"
+
+// Return the record's tags, from all of its TagAttr tuples, in order
+// and without duplicates.
+func (r Record) Tags() []string {
+	var tags []string
+	seen := make(map[string]bool)
+
+	for _, tuple := range r.find(TagAttr) {
+		for _, tag := range strings.Fields(tuple.Val) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags
+}
+
+// Whether the record has tag.
+func (r Record) HasTag(tag string) bool {
+	for _, t := range r.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Search for records that have all of tags. Returns no records (nil)
+// if none match.
+func (n *Ndb) SearchTags(tags ...string) RecordSet {
+	return n.match(func(rec Record) bool {
+		for _, tag := range tags {
+			if !rec.HasTag(tag) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Search for records that have at least one of tags. Returns no
+// records (nil) if none match.
+func (n *Ndb) SearchAnyTag(tags ...string) RecordSet {
+	return n.match(func(rec Record) bool {
+		for _, tag := range tags {
+			if rec.HasTag(tag) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Return the unexpired records for which fn is true, in search order,
+// expanded as Search does. Records are matched on their own tuples.
+func (n *Ndb) match(fn func(Record) bool) RecordSet {
+	ctx, cancel := n.opts.searchcontext(context.Background())
+	defer cancel()
+
+	return n.matchcontext(ctx, fn).Records
+}
+
+// Match like match, until ctx is done.
+func (n *Ndb) matchcontext(ctx context.Context, fn func(Record) bool) *Result {
+	res := &Result{}
+	now := time.Now()
+	done := ctx.Done()
+
+	statSearches.Add(1)
+
+	defer func() {
+		if res.Err = ctx.Err(); res.Err != nil {
+			res.Truncated = true
+		}
+		res.Elapsed = time.Since(now)
+	}()
+
+	for db := n; db != nil; db = db.next {
+		res.Files = append(res.Files, db.filename)
+
+		for _, record := range db.recs() {
+			if stopped(done) {
+				return res
+			}
+
+			if !record.Expired(now) && fn(record) {
+				res.Records = append(res.Records, n.Expand(record))
+			}
+		}
+	}
+
+	return res
+}
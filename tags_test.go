@@ -0,0 +1,60 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type TagTest struct {
+	all    bool
+	tags   []string
+	expect []string
+}
+
+var tagtests = []TagTest{
+	{true, []string{"web"}, []string{"a", "b"}},
+	{true, []string{"web", "prod"}, []string{"a"}},
+	{true, []string{"dc1"}, []string{"a", "c"}},
+	{true, []string{"web", "db"}, nil},
+	{false, []string{"db", "prod"}, []string{"a", "c"}},
+	{false, []string{"nope"}, nil},
+	{true, []string{"we"}, nil},
+}
+
+func TestTags(t *testing.T) {
+	db, err := Open("testndb/tags")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tagtests {
+		var recs RecordSet
+		if tt.all {
+			recs = db.SearchTags(tt.tags...)
+		} else {
+			recs = db.SearchAnyTag(tt.tags...)
+		}
+
+		var got []string
+		for _, rec := range recs {
+			got = append(got, rec.Search("sys"))
+		}
+
+		if len(got) != len(tt.expect) {
+			t.Errorf("%v all=%v: expected %v got %v", tt.tags, tt.all, tt.expect, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.expect[i] {
+				t.Errorf("%v all=%v: expected %v got %v", tt.tags, tt.all, tt.expect, got)
+				break
+			}
+		}
+	}
+
+	tags := db.Search("sys", "a")[0].Tags()
+	if len(tags) != 3 || tags[0] != "web" || tags[1] != "prod" || tags[2] != "dc1" {
+		t.Errorf("expected [web prod dc1] got %v", tags)
+	}
+}
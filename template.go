@@ -0,0 +1,77 @@
+package ndb
+
+// Return rec with the tuples it inherits from templates appended.
+// Returns rec unchanged if it names no templates. A template is a
+// record with a template= tuple naming it; a record with inherit=name
+// gets the template's tuples, other than template=, for every
+// attribute it does not have itself, so any of them can be
+// overridden. Templates may inherit from other templates, and a record
+// may name several, the first taking precedence. Search and Ipinfo
+// return expanded records.
+func (n *Ndb) Expand(rec Record) Record {
+	return n.expand(rec, make(map[string]bool))
+}
+
+func (n *Ndb) expand(rec Record, seen map[string]bool) Record {
+	if rec.find("inherit") == nil {
+		return rec
+	}
+
+	out := append(Record(nil), rec...)
+
+	for _, inherit := range rec.find("inherit") {
+		if seen[inherit.Val] {
+			continue
+		}
+		seen[inherit.Val] = true
+
+		tmpl := n.template(inherit.Val)
+		if tmpl == nil {
+			continue
+		}
+
+		have := make(map[string]bool)
+		for _, tuple := range out {
+			have[tuple.Attr] = true
+		}
+
+		for _, tuple := range n.expand(tmpl, seen) {
+			if !have[tuple.Attr] && tuple.Attr != "template" {
+				out = append(out, tuple)
+			}
+		}
+	}
+
+	return out
+}
+
+// Find the template record with the given name, the first in search
+// order. The templates are indexed by name when first needed, so
+// expanding records needn't read the whole database for each
+// inherit=.
+func (n *Ndb) template(name string) Record {
+	n.tmplmu.Lock()
+	defer n.tmplmu.Unlock()
+
+	if n.templates == nil {
+		n.templates = make(map[string]Record)
+		for db := n; db != nil; db = db.next {
+			for _, record := range db.recs() {
+				for _, tuple := range record {
+					if _, ok := n.templates[tuple.Val]; tuple.Attr == "template" && !ok {
+						n.templates[tuple.Val] = record
+					}
+				}
+			}
+		}
+	}
+
+	return n.templates[name]
+}
+
+// Forget the template index, after the records change.
+func (n *Ndb) resettemplates() {
+	n.tmplmu.Lock()
+	n.templates = nil
+	n.tmplmu.Unlock()
+}
@@ -0,0 +1,125 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testtemplate = "testndb/template"
+)
+
+type TemplateTest struct {
+	sys    string
+	tuples []Tuple
+}
+
+var templatetests = []TemplateTest{
+	// two levels of templates
+	TemplateTest{"fir", []Tuple{Tuple{"sys", "fir"}, Tuple{"ip", "10.0.0.9"}, Tuple{"inherit", "workstation"},
+		Tuple{"dns", "10.0.0.2"}, Tuple{"dns", "10.0.0.3"}, Tuple{"fs", "fs.example.com"},
+		Tuple{"ntp", "ntp.example.com"}, Tuple{"auth", "auth.example.com"}}},
+	// own tuples override all of an attribute's values
+	TemplateTest{"pine", []Tuple{Tuple{"sys", "pine"}, Tuple{"ip", "10.0.0.10"}, Tuple{"inherit", "workstation"},
+		Tuple{"fs", "pinefs"}, Tuple{"dns", "10.0.0.4"},
+		Tuple{"ntp", "ntp.example.com"}, Tuple{"auth", "auth.example.com"}}},
+	// a template inheriting from itself, then a second template
+	TemplateTest{"spruce", []Tuple{Tuple{"sys", "spruce"}, Tuple{"inherit", "loop"}, Tuple{"inherit", "base"},
+		Tuple{"fs", "loopfs"}, Tuple{"ntp", "ntp.example.com"}, Tuple{"auth", "auth.example.com"}}},
+	// no such template
+	TemplateTest{"oak", []Tuple{Tuple{"sys", "oak"}, Tuple{"inherit", "missing"}}},
+}
+
+func TestTemplate(t *testing.T) {
+	db, err := Open(testtemplate)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range templatetests {
+		recs := db.Search("sys", tt.sys)
+
+		if len(recs) != 1 {
+			t.Errorf("%s: expected 1 record, got %d", tt.sys, len(recs))
+			continue
+		}
+
+		if len(recs[0]) != len(tt.tuples) {
+			t.Errorf("%s: expected %v got %v", tt.sys, tt.tuples, recs[0])
+			continue
+		}
+
+		for i := range tt.tuples {
+			if recs[0][i] != tt.tuples[i] {
+				t.Errorf("%s: expected %v got %v", tt.sys, tt.tuples, recs[0])
+				break
+			}
+		}
+	}
+
+	if fs := db.Ipinfo("sys", "fir", "fs"); len(fs) != 1 || fs[0].Val != "fs.example.com" {
+		t.Errorf("ipinfo did not inherit: %v", fs)
+	}
+
+	// templates only add to results, not matches
+	if recs := db.Search("ntp", "ntp.example.com"); len(recs) != 1 {
+		t.Errorf("expected only the template to match, got %v", recs)
+	}
+
+	// the database itself is unchanged
+	if recs := db.FileRecords(testtemplate); len(recs[3]) != 3 {
+		t.Errorf("record modified: %v", recs[3])
+	}
+}
+
+func TestTemplateReset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "local")
+	if err := ioutil.WriteFile(fname, []byte("template=ws ntp=a\nsys=fir inherit=ws\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ntp := func() string {
+		return db.Search("sys", "fir")[0].Search("ntp")
+	}
+
+	if got := ntp(); got != "a" {
+		t.Fatalf("ntp=%s", got)
+	}
+
+	// an edit is seen at once
+	tmpl := db.Search("template", "ws")[0]
+	if err := db.ReplaceRecord(tmpl, Record{{"template", "ws"}, {"ntp", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ntp(); got != "b" {
+		t.Errorf("after edit ntp=%s", got)
+	}
+
+	// and a change to the file after Reopen
+	if err := ioutil.WriteFile(fname, []byte("template=ws ntp=c\nsys=fir inherit=ws\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ntp(); got != "c" {
+		t.Errorf("after Reopen ntp=%s", got)
+	}
+}
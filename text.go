@@ -0,0 +1,72 @@
+package ndb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Search for records with a tuple whose attribute or value contains
+// term, ignoring case, for when it isn't known which attribute holds
+// a string. Returns no records (nil) if none match.
+func (n *Ndb) SearchText(term string) RecordSet {
+	return n.match(textmatch(term))
+}
+
+// Return a matcher for records with term in an attribute or value.
+func textmatch(term string) func(Record) bool {
+	term = strings.ToLower(term)
+
+	return func(rec Record) bool {
+		for _, tuple := range rec {
+			if strings.Contains(strings.ToLower(tuple.Attr), term) || strings.Contains(strings.ToLower(tuple.Val), term) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Search like SearchText, but also match records with an attribute,
+// a value, or a word of a value within an edit distance of max of
+// term, ignoring case, to find what was misspelled. Words are runs of
+// letters and digits. max <= 0 picks a distance as Suggest does.
+// Returns no records (nil) if none match.
+func (n *Ndb) SearchTextFuzzy(term string, max int) RecordSet {
+	return n.match(fuzzymatch(term, max))
+}
+
+// Return a matcher for records near term, as SearchTextFuzzy finds.
+func fuzzymatch(term string, max int) func(Record) bool {
+	term = strings.ToLower(term)
+
+	if max <= 0 {
+		max = len(term) / 3
+		if max < 1 {
+			max = 1
+		}
+	}
+
+	near := func(s string) bool {
+		return strings.Contains(s, term) || levenshtein(s, term) <= max
+	}
+
+	return func(rec Record) bool {
+		for _, tuple := range rec {
+			attr, val := strings.ToLower(tuple.Attr), strings.ToLower(tuple.Val)
+			if near(attr) || near(val) {
+				return true
+			}
+
+			words := strings.FieldsFunc(val, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+
+			for _, word := range words {
+				if near(word) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
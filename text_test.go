@@ -0,0 +1,57 @@
+package ndb
+
+import (
+	"testing"
+)
+
+type TextTest struct {
+	term   string
+	fuzzy  bool
+	expect []string
+}
+
+var texttests = []TextTest{
+	{"EXAMPLE", false, []string{"gw", "fir", "elm", "ash"}},
+	{"elm", false, []string{"elm"}},
+	{"ether", false, []string{"gw", "oak", "elm", "ash"}},
+	{"0000fe", false, []string{"ash"}},
+	{"exmaple", false, nil},
+	{"nothing", false, nil},
+	{"exmaple", true, []string{"gw", "fir", "elm", "ash"}},
+	{"ekm", true, []string{"elm"}},
+	{"zzzzz", true, nil},
+}
+
+func TestSearchText(t *testing.T) {
+	db, err := Open("testndb/report")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range texttests {
+		var recs RecordSet
+		if tt.fuzzy {
+			recs = db.SearchTextFuzzy(tt.term, 0)
+		} else {
+			recs = db.SearchText(tt.term)
+		}
+
+		var got []string
+		for _, rec := range recs {
+			got = append(got, rec.Search("sys"))
+		}
+
+		if len(got) != len(tt.expect) {
+			t.Errorf("%q fuzzy=%v: expected %v got %v", tt.term, tt.fuzzy, tt.expect, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.expect[i] {
+				t.Errorf("%q fuzzy=%v: expected %v got %v", tt.term, tt.fuzzy, tt.expect, got)
+				break
+			}
+		}
+	}
+}
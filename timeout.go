@@ -0,0 +1,62 @@
+package ndb
+
+import (
+	"context"
+	"time"
+)
+
+// Stop each search after d, returning the records found so far, so a
+// pathological query can't tie up an interactive server. Searches
+// returning a Result mark it Truncated and set Err; others just return
+// fewer records. Zero means no limit.
+func WithSearchTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// Return ctx with the deadline given to WithSearchTimeout, if any.
+func (o *options) searchcontext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o == nil || o.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, o.timeout)
+}
+
+// Whether done is closed, without waiting.
+func stopped(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Search like SearchResult, stopping when ctx is done. The Result then
+// holds the records found so far, is Truncated, and has ctx's error.
+func (n *Ndb) SearchContext(ctx context.Context, attr, val string, max int) *Result {
+	ctx, cancel := n.opts.searchcontext(ctx)
+	defer cancel()
+
+	return n.search(ctx, attr, val, max, false)
+}
+
+// Search like SearchText, stopping when ctx is done as SearchContext
+// does.
+func (n *Ndb) SearchTextContext(ctx context.Context, term string) *Result {
+	ctx, cancel := n.opts.searchcontext(ctx)
+	defer cancel()
+
+	return n.matchcontext(ctx, textmatch(term))
+}
+
+// Search like SearchTextFuzzy, stopping when ctx is done as
+// SearchContext does.
+func (n *Ndb) SearchTextFuzzyContext(ctx context.Context, term string, max int) *Result {
+	ctx, cancel := n.opts.searchcontext(ctx)
+	defer cancel()
+
+	return n.matchcontext(ctx, fuzzymatch(term, max))
+}
@@ -0,0 +1,63 @@
+package ndb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchContext(t *testing.T) {
+	db, err := Open(testndb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := db.SearchContext(context.Background(), "sys", "localhost", 0)
+	if len(res.Records) == 0 || res.Truncated || res.Err != nil {
+		t.Errorf("uncanceled search got %d records, truncated %v, err %v", len(res.Records), res.Truncated, res.Err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res = db.SearchContext(ctx, "sys", "localhost", 0)
+	if len(res.Records) != 0 || !res.Truncated || res.Err != context.Canceled {
+		t.Errorf("canceled search got %d records, truncated %v, err %v", len(res.Records), res.Truncated, res.Err)
+	}
+
+	res = db.SearchTextContext(ctx, "localhost")
+	if len(res.Records) != 0 || !res.Truncated || res.Err != context.Canceled {
+		t.Errorf("canceled text search got %d records, truncated %v, err %v", len(res.Records), res.Truncated, res.Err)
+	}
+
+	if res = db.SearchTextFuzzyContext(context.Background(), "lcoalhost", 0); len(res.Records) == 0 || res.Err != nil {
+		t.Errorf("fuzzy search got %d records, err %v", len(res.Records), res.Err)
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	for _, opt := range []Option{Single(), WithParallelSearch(1)} {
+		db, err := Open(testndb, opt, WithSearchTimeout(time.Nanosecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := db.SearchResult("sys", "localhost", 0)
+		if len(res.Records) != 0 || !res.Truncated || res.Err != context.DeadlineExceeded {
+			t.Errorf("timed out search got %d records, truncated %v, err %v", len(res.Records), res.Truncated, res.Err)
+		}
+
+		if recs := db.SearchText("localhost"); len(recs) != 0 {
+			t.Errorf("timed out text search got %v", recs)
+		}
+	}
+
+	db, err := Open(testndb, WithSearchTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := db.Search("sys", "localhost"); len(recs) == 0 {
+		t.Errorf("search with a long timeout found nothing")
+	}
+}
@@ -0,0 +1,190 @@
+package ndb
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// How a Watcher notices changes to the database files.
+type WatchMode int
+
+const (
+	WatchAuto   WatchMode = iota // Notifications where supported, else polling
+	WatchNotify                  // Kernel notifications: inotify, kqueue or ReadDirectoryChangesW
+	WatchPoll                    // Stat the files periodically, which works on any filesystem
+)
+
+// Default time between checks when polling.
+const DefaultPollInterval = 5 * time.Second
+
+var errnonotify = errors.New("watch: file notifications not supported on this system")
+
+// A Watcher reports changes to the files of a database.
+// It does not reload the database itself; the receiver of C
+// should call Reopen, so reloads happen where queries do.
+type Watcher struct {
+	// Receives a value when any database file has changed.
+	// Changes made before the value is received are coalesced.
+	C <-chan struct{}
+
+	c     chan struct{}
+	raw   chan struct{} // Changes to debounce, if debouncing
+	files []string
+	done  chan struct{}
+	once  sync.Once
+	close func() error // Stops the notification source, if any
+}
+
+// Watch the database's files for changes. Notifications come from
+// inotify on Linux, kqueue on BSD and macOS, and ReadDirectoryChangesW
+// on Windows. Polling uses interval, or DefaultPollInterval if it is
+// zero. Polling is the choice for network filesystems, where
+// notifications are often not delivered. Fails for a database not
+// read from files on disk, such as one from Parse or OpenFS, whose
+// files can't change.
+//
+// If debounce is not zero, a change is only reported once the files
+// have been quiet for that long, so files rewritten in quick succession,
+// as by rsync, cause one reload rather than several of partial states.
+func (n *Ndb) Watch(mode WatchMode, interval, debounce time.Duration) (*Watcher, error) {
+	if n.opts != nil && n.opts.files != nil {
+		return nil, errors.New("watch: database is not read from the operating system's files")
+	}
+
+	for db := n; db != nil; db = db.next {
+		if db.filename == "" {
+			return nil, errors.New("watch: database has no file")
+		}
+	}
+
+	c := make(chan struct{}, 1)
+	w := &Watcher{C: c, c: c, files: n.Files(), done: make(chan struct{})}
+
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	// made before the watching starts, so no change is missed, but
+	// read only once it has, so a failure leaves no goroutine behind
+	if debounce > 0 {
+		w.raw = make(chan struct{}, 1)
+	}
+
+	switch mode {
+	case WatchAuto:
+		if err := w.notify(); err != nil {
+			w.poll(interval)
+		}
+	case WatchNotify:
+		if err := w.notify(); err != nil {
+			return nil, err
+		}
+	case WatchPoll:
+		w.poll(interval)
+	default:
+		return nil, errors.New("watch: unknown mode")
+	}
+
+	if debounce > 0 {
+		go w.debounce(debounce)
+	}
+
+	return w, nil
+}
+
+// Stop watching. C is not closed.
+func (w *Watcher) Close() error {
+	var err error
+
+	w.once.Do(func() {
+		close(w.done)
+		if w.close != nil {
+			err = w.close()
+		}
+	})
+
+	return err
+}
+
+// Report a change, unless one is already waiting.
+func (w *Watcher) changed() {
+	c := w.c
+	if w.raw != nil {
+		c = w.raw
+	}
+
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Pass changes on to C once there have been none for window.
+func (w *Watcher) debounce(window time.Duration) {
+	timer := time.NewTimer(window)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case <-w.raw:
+			timer.Stop()
+			timer.Reset(window)
+		case <-timer.C:
+			select {
+			case w.c <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// The state of a file as seen by poll.
+type filestate struct {
+	mtime time.Time
+	size  int64
+	ok    bool
+}
+
+func statfile(fname string) filestate {
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return filestate{}
+	}
+
+	return filestate{fi.ModTime(), fi.Size(), true}
+}
+
+// Stat the files now, and then every interval until closed.
+func (w *Watcher) poll(interval time.Duration) {
+	last := make([]filestate, len(w.files))
+	for i, fname := range w.files {
+		last[i] = statfile(fname)
+	}
+
+	go w.pollloop(interval, last)
+}
+
+func (w *Watcher) pollloop(interval time.Duration, last []filestate) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick.C:
+		}
+
+		for i, fname := range w.files {
+			if st := statfile(fname); st != last[i] {
+				last[i] = st
+				w.changed()
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package ndb
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+// Events that mean a file was written or removed, or for a directory,
+// that an entry was added, removed or renamed.
+const kqueuenotes = syscall.NOTE_WRITE | syscall.NOTE_EXTEND | syscall.NOTE_ATTRIB |
+	syscall.NOTE_DELETE | syscall.NOTE_RENAME
+
+// Watch the files with kqueue, and the directories holding them, since
+// editors and tools like rsync replace files rather than writing them
+// in place. kqueue watches open files, so a file replaced that way is
+// only seen as a change to its directory; then the files are compared
+// by stat, as poll does, and the new ones watched.
+func (w *Watcher) notify() error {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return err
+	}
+	syscall.CloseOnExec(kq)
+
+	// closing the write end wakes Kevent to stop
+	var stop [2]int
+	if err := syscall.Pipe(stop[:]); err != nil {
+		syscall.Close(kq)
+		return err
+	}
+	syscall.CloseOnExec(stop[0])
+	syscall.CloseOnExec(stop[1])
+
+	fds := make([]int, len(w.files))
+	last := make([]filestate, len(w.files))
+	dirs := make(map[string]int)
+
+	cleanup := func() {
+		for _, fd := range fds {
+			if fd >= 0 {
+				syscall.Close(fd)
+			}
+		}
+		for _, fd := range dirs {
+			syscall.Close(fd)
+		}
+		syscall.Close(stop[0])
+		syscall.Close(kq)
+	}
+
+	// watch the file at fname, returning -1 if it can't be opened
+	watch := func(fname string) int {
+		fd, err := syscall.Open(fname, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return -1
+		}
+		if kwatch(kq, fd, kqueuenotes) != nil {
+			syscall.Close(fd)
+			return -1
+		}
+		return fd
+	}
+
+	for i, fname := range w.files {
+		abs, err := filepath.Abs(fname)
+		if err != nil {
+			syscall.Close(stop[1])
+			cleanup()
+			return err
+		}
+
+		dir := filepath.Dir(abs)
+		if _, ok := dirs[dir]; !ok {
+			fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+			if err == nil {
+				dirs[dir] = fd
+				err = kwatch(kq, fd, syscall.NOTE_WRITE)
+			}
+			if err != nil {
+				syscall.Close(stop[1])
+				cleanup()
+				return err
+			}
+		}
+
+		fds[i] = watch(fname)
+		last[i] = statfile(fname)
+	}
+
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, stop[0], syscall.EVFILT_READ, syscall.EV_ADD)
+	if _, err := syscall.Kevent(kq, []syscall.Kevent_t{ev}, nil, nil); err != nil {
+		syscall.Close(stop[1])
+		cleanup()
+		return err
+	}
+
+	w.close = func() error {
+		return syscall.Close(stop[1])
+	}
+
+	go func() {
+		defer cleanup()
+
+		events := make([]syscall.Kevent_t, 16)
+
+		for {
+			n, err := syscall.Kevent(kq, nil, events, nil)
+			if err == syscall.EINTR {
+				continue
+			} else if err != nil {
+				return
+			}
+
+			// a file written, or removed and so to be watched
+			// afresh in case it was replaced
+			changed := false
+			rewatch := make(map[int]bool)
+			for _, ev := range events[:n] {
+				if int(ev.Ident) == stop[0] {
+					return
+				}
+				for i, fd := range fds {
+					if int(ev.Ident) == fd {
+						changed = true
+						if ev.Fflags&(syscall.NOTE_DELETE|syscall.NOTE_RENAME) != 0 {
+							rewatch[i] = true
+						}
+					}
+				}
+			}
+
+			// a file replaced by a rename into its directory
+			for i, fname := range w.files {
+				st := statfile(fname)
+				if st != last[i] {
+					last[i] = st
+					changed = true
+					rewatch[i] = true
+				}
+
+				if rewatch[i] || (fds[i] < 0 && st.ok) {
+					if fds[i] >= 0 {
+						syscall.Close(fds[i])
+					}
+					fds[i] = watch(fname)
+				}
+			}
+
+			if changed {
+				w.changed()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Add the open file or directory fd to the kqueue kq, for the events
+// in notes.
+func kwatch(kq, fd int, notes uint32) error {
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_CLEAR)
+	ev.Fflags = notes
+
+	_, err := syscall.Kevent(kq, []syscall.Kevent_t{ev}, nil, nil)
+	return err
+}
@@ -0,0 +1,81 @@
+package ndb
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Events that mean a file was written, replaced or removed.
+const inotifymask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_ATTRIB
+
+// Watch the directories holding the files with inotify, since editors
+// and tools like rsync replace files rather than writing them in place.
+func (w *Watcher) notify() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	// a nonblocking descriptor uses the runtime poller,
+	// so closing f interrupts Read
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	names := make(map[string]bool)
+	dirs := make(map[string]bool)
+	wds := make(map[int32]string)
+
+	for _, fname := range w.files {
+		abs, err := filepath.Abs(fname)
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		names[abs] = true
+
+		dir := filepath.Dir(abs)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+
+		wd, err := syscall.InotifyAddWatch(fd, dir, inotifymask)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		wds[int32(wd)] = dir
+	}
+
+	w.close = f.Close
+
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+				off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+				for len(name) > 0 && name[len(name)-1] == 0 {
+					name = name[:len(name)-1]
+				}
+
+				if names[filepath.Join(wds[ev.Wd], string(name))] {
+					w.changed()
+				}
+			}
+		}
+	}()
+
+	return nil
+}
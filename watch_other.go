@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!windows,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package ndb
+
+// Kernel notifications are only used on Linux, Windows, BSD and macOS;
+// WatchAuto polls elsewhere.
+func (w *Watcher) notify() error {
+	return errnonotify
+}
@@ -0,0 +1,159 @@
+package ndb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	for _, mode := range []WatchMode{WatchAuto, WatchPoll} {
+		dir, err := ioutil.TempDir("", "ndb")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.RemoveAll(dir)
+
+		local := filepath.Join(dir, "local")
+
+		if err := ioutil.WriteFile(local, []byte("sys=fir\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := Open(local)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w, err := db.Watch(mode, 10*time.Millisecond, 0)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a file written elsewhere and renamed into place,
+		// with a different size so polling notices within the mtime's granularity
+		tmp := filepath.Join(dir, "local.tmp")
+
+		if err := ioutil.WriteFile(tmp, []byte("sys=spruce\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Rename(tmp, local); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-w.C:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("mode %d: no change reported", mode)
+		}
+
+		if err := db.Reopen(); err != nil {
+			t.Fatal(err)
+		}
+
+		if db.Search("sys", "spruce") == nil {
+			t.Errorf("mode %d: new records not loaded", mode)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local")
+
+	if err := ioutil.WriteFile(local, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(local)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := db.Watch(WatchAuto, 10*time.Millisecond, 200*time.Millisecond)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	// several writes, closer together than the window
+	start := time.Now()
+	data := ""
+
+	for i := 0; i < 5; i++ {
+		data += "sys=fir\n"
+		if err := ioutil.WriteFile(local, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	select {
+	case <-w.C:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("change reported before the writes settled, after %v", d)
+	}
+
+	select {
+	case <-w.C:
+		t.Errorf("writes reported more than once")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatchError(t *testing.T) {
+	db, err := Open(testndb)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		if _, err := db.Watch(WatchMode(-1), 0, time.Second); err == nil {
+			t.Fatal("no error for an unknown mode")
+		}
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("failed watches left %d goroutines running", after-before)
+	}
+
+	// a parsed database has no files to watch
+	parsed, err := Parse(strings.NewReader("sys=a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parsed.Watch(WatchAuto, 0, 0); err == nil {
+		t.Errorf("no error watching a parsed database")
+	}
+}
@@ -0,0 +1,157 @@
+package ndb
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Changes that mean a file was written, replaced or removed.
+const dirnotifymask = syscall.FILE_NOTIFY_CHANGE_FILE_NAME | syscall.FILE_NOTIFY_CHANGE_ATTRIBUTES |
+	syscall.FILE_NOTIFY_CHANGE_SIZE | syscall.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+// A directory watched with ReadDirectoryChangesW.
+type dirwatch struct {
+	h   syscall.Handle
+	ov  syscall.Overlapped
+	buf [64 * 1024]byte
+}
+
+// Start reading the next changes to the directory.
+func (d *dirwatch) read() error {
+	return syscall.ReadDirectoryChanges(d.h, &d.buf[0], uint32(len(d.buf)), false, dirnotifymask, nil, &d.ov, 0)
+}
+
+// Watch the directories holding the files with ReadDirectoryChangesW,
+// since editors and tools like rsync replace files rather than writing
+// them in place. The results are collected from an I/O completion port.
+func (w *Watcher) notify() error {
+	port, err := syscall.CreateIoCompletionPort(syscall.InvalidHandle, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	seen := make(map[string]bool)
+	var dirs []*dirwatch
+	var dirnames []string
+
+	cleanup := func() {
+		for _, d := range dirs {
+			syscall.CloseHandle(d.h)
+		}
+		syscall.CloseHandle(port)
+	}
+
+	for _, fname := range w.files {
+		abs, err := filepath.Abs(fname)
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		// names on Windows are compared without case
+		names[strings.ToLower(abs)] = true
+
+		dir := filepath.Dir(abs)
+		if seen[strings.ToLower(dir)] {
+			continue
+		}
+		seen[strings.ToLower(dir)] = true
+
+		p, err := syscall.UTF16PtrFromString(dir)
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		h, err := syscall.CreateFile(p, syscall.FILE_LIST_DIRECTORY,
+			syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+			nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED, 0)
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		d := &dirwatch{h: h}
+		dirs = append(dirs, d)
+		dirnames = append(dirnames, dir)
+
+		// the key is the directory's index, plus one so a key of
+		// zero can't be mistaken for a directory
+		if _, err := syscall.CreateIoCompletionPort(h, port, uint32(len(dirs)), 0); err != nil {
+			cleanup()
+			return err
+		}
+
+		if err := d.read(); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	// Close wakes the loop with a completion for no directory
+	w.close = func() error {
+		return syscall.PostQueuedCompletionStatus(port, 0, 0, nil)
+	}
+
+	go func() {
+		defer cleanup()
+
+		for {
+			var n, key uint32
+			var ov *syscall.Overlapped
+
+			err := syscall.GetQueuedCompletionStatus(port, &n, &key, &ov, syscall.INFINITE)
+			if key == 0 || int(key) > len(dirs) {
+				return
+			}
+
+			d := dirs[key-1]
+
+			// no changes recorded means they overflowed the buffer
+			if err != nil || n == 0 {
+				w.changed()
+			} else if changes(d.buf[:n], dirnames[key-1], names) {
+				w.changed()
+			}
+
+			if d.read() != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Whether the FILE_NOTIFY_INFORMATION records in buf, for changes in
+// dir, name any of the files in names.
+func changes(buf []byte, dir string, names map[string]bool) bool {
+	for off := 0; off+int(unsafe.Offsetof(syscall.FileNotifyInformation{}.FileName)) <= len(buf); {
+		info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[off]))
+
+		start := off + int(unsafe.Offsetof(info.FileName))
+		end := start + int(info.FileNameLength)
+		if end > len(buf) {
+			return true
+		}
+
+		name := make([]uint16, info.FileNameLength/2)
+		for i := range name {
+			name[i] = uint16(buf[start+2*i]) | uint16(buf[start+2*i+1])<<8
+		}
+
+		if names[strings.ToLower(filepath.Join(dir, syscall.UTF16ToString(name)))] {
+			return true
+		}
+
+		if info.NextEntryOffset == 0 {
+			break
+		}
+		off += int(info.NextEntryOffset)
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package ndb
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Return the zones the database is authoritative for, as in Plan 9:
+// the dom= values of records that have an soa= tuple. Sorted, lower case.
+func (n *Ndb) Zones() []string {
+	seen := make(map[string]bool)
+
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			if record.find("soa") == nil {
+				continue
+			}
+
+			for _, tuple := range record.find("dom") {
+				seen[strings.ToLower(tuple.Val)] = true
+			}
+		}
+	}
+
+	return sortedkeys(seen)
+}
+
+// Return the most specific zone containing the domain dom,
+// or "" if the database is not authoritative for it.
+func (n *Ndb) Zone(dom string) string {
+	return zonefor(n.Zones(), dom)
+}
+
+// Find the longest zone that dom is in.
+func zonefor(zones []string, dom string) string {
+	dom = strings.TrimSuffix(strings.ToLower(dom), ".")
+	best := ""
+
+	for _, zone := range zones {
+		if dom == zone || strings.HasSuffix(dom, "."+zone) {
+			if len(zone) > len(best) {
+				best = zone
+			}
+		}
+	}
+
+	return best
+}
+
+// Return the records belonging to zone: those with a dom= in the zone
+// and not in a more specific zone the database also serves. Expired
+// records are skipped. Returns no records (nil) if zone is not one of
+// Zones.
+func (n *Ndb) ZoneRecords(zone string) RecordSet {
+	var results RecordSet
+
+	zones := n.Zones()
+	zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+	now := time.Now()
+
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			if record.Expired(now) {
+				continue
+			}
+
+			for _, tuple := range record.find("dom") {
+				if zonefor(zones, tuple.Val) == zone {
+					results = append(results, record)
+					break
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// Defaults for SOA fields not given in the zone's record, in seconds.
+const (
+	DefaultRefresh = 3600
+	DefaultRetry   = 600
+	DefaultExpire  = 86400
+	DefaultMinimum = 3600
+)
+
+// Start of authority parameters for a zone, from the tuples
+// of the record declaring it.
+type SOA struct {
+	Zone    string   // The zone's domain
+	NS      []string // Name servers, from ns=
+	Mbox    string   // Responsible mailbox, from mb=
+	Serial  uint32   // From serial=, or else the database file's modification time
+	Refresh uint32   // From refresh=, or DefaultRefresh
+	Retry   uint32   // From retry=, or DefaultRetry
+	Expire  uint32   // From expire=, or DefaultExpire
+	Minimum uint32   // From minimum=, or DefaultMinimum
+	TTL     uint32   // From ttl=, or else Minimum
+}
+
+// Return the start of authority parameters for zone, or nil if the
+// database is not authoritative for it.
+func (n *Ndb) SOA(zone string) *SOA {
+	zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+
+	for db := n; db != nil; db = db.next {
+		for _, record := range db.recs() {
+			if record.find("soa") == nil {
+				continue
+			}
+
+			for _, tuple := range record.find("dom") {
+				if strings.ToLower(tuple.Val) == zone {
+					return parsesoa(zone, record, db.mtime)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func parsesoa(zone string, record Record, mtime time.Time) *SOA {
+	soa := &SOA{
+		Zone:    zone,
+		Mbox:    record.Search("mb"),
+		Serial:  uint32(mtime.Unix()),
+		Refresh: DefaultRefresh,
+		Retry:   DefaultRetry,
+		Expire:  DefaultExpire,
+		Minimum: DefaultMinimum,
+	}
+
+	for _, tuple := range record.find("ns") {
+		soa.NS = append(soa.NS, tuple.Val)
+	}
+
+	fields := []struct {
+		attr string
+		val  *uint32
+	}{
+		{"serial", &soa.Serial},
+		{"refresh", &soa.Refresh},
+		{"retry", &soa.Retry},
+		{"expire", &soa.Expire},
+		{"minimum", &soa.Minimum},
+	}
+
+	for _, f := range fields {
+		if v, err := strconv.ParseUint(record.Search(f.attr), 10, 32); err == nil {
+			*f.val = uint32(v)
+		}
+	}
+
+	soa.TTL = soa.Minimum
+	if v, err := strconv.ParseUint(record.Search("ttl"), 10, 32); err == nil {
+		soa.TTL = uint32(v)
+	}
+
+	return soa
+}
@@ -0,0 +1,111 @@
+package ndb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZones(t *testing.T) {
+	db, err := Open("testndb/zones")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zones := db.Zones()
+	want := []string{"example.com", "example.org", "lab.example.com"}
+
+	if len(zones) != len(want) {
+		t.Fatalf("expected zones %q got %q", want, zones)
+	}
+
+	for i := range want {
+		if zones[i] != want[i] {
+			t.Errorf("expected zones %q got %q", want, zones)
+		}
+	}
+
+	doms := map[string]string{
+		"www.example.com":       "example.com",
+		"WWW.Example.COM.":      "example.com",
+		"bench.lab.example.com": "lab.example.com",
+		"lab.example.com":       "lab.example.com",
+		"notexample.com":        "",
+		"example.net":           "",
+	}
+
+	for dom, zone := range doms {
+		if got := db.Zone(dom); got != zone {
+			t.Errorf("%s: expected zone %q got %q", dom, zone, got)
+		}
+	}
+
+	syss := map[string][]string{
+		"example.com":     []string{"www", "mail"},
+		"lab.example.com": []string{"bench"},
+		"example.org":     []string{"mail"},
+		"example.net":     nil,
+	}
+
+	for zone, hosts := range syss {
+		var got []string
+		for _, rec := range db.ZoneRecords(zone) {
+			if sys := rec.Search("sys"); sys != "" {
+				got = append(got, sys)
+			}
+		}
+
+		if len(got) != len(hosts) {
+			t.Errorf("%s: expected hosts %q got %q", zone, hosts, got)
+			continue
+		}
+
+		for i := range hosts {
+			if got[i] != hosts[i] {
+				t.Errorf("%s: expected hosts %q got %q", zone, hosts, got)
+			}
+		}
+	}
+}
+
+func TestSOA(t *testing.T) {
+	db, err := Open("testndb/zones")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	soa := db.SOA("Example.COM.")
+
+	if soa == nil {
+		t.Fatal("no soa for example.com")
+	}
+
+	want := SOA{Zone: "example.com", Mbox: "hostmaster@example.com", Serial: 2024010101,
+		Refresh: 7200, Retry: 900, Expire: 604800, Minimum: 300, TTL: 300}
+
+	if len(soa.NS) != 2 || soa.NS[0] != "ns1.example.com" || soa.NS[1] != "ns2.example.com" {
+		t.Errorf("wrong name servers: %q", soa.NS)
+	}
+
+	soa.NS = nil
+
+	if !reflect.DeepEqual(*soa, want) {
+		t.Errorf("expected %+v got %+v", want, *soa)
+	}
+
+	soa = db.SOA("example.org")
+
+	if soa == nil {
+		t.Fatal("no soa for example.org")
+	}
+
+	if soa.Refresh != DefaultRefresh || soa.Retry != DefaultRetry || soa.Expire != DefaultExpire ||
+		soa.Minimum != DefaultMinimum || soa.TTL != DefaultMinimum || soa.Serial == 0 {
+		t.Errorf("expected defaults, got %+v", *soa)
+	}
+
+	if soa := db.SOA("example.net"); soa != nil {
+		t.Errorf("expected no soa, got %+v", *soa)
+	}
+}